@@ -0,0 +1,9 @@
+package api
+
+import "embed"
+
+// Specs contains OpenAPI specifications of every api version. Specifications refer to each other
+// by file names, so they are kept in a single file system.
+//
+//go:embed openapi.yaml openapi.v2.yaml
+var Specs embed.FS
@@ -0,0 +1,189 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: reservations/v1/reservations.proto
+
+package reservationsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ReservationService_CreateReservations_FullMethodName = "/reservations.v1.ReservationService/CreateReservations"
+	ReservationService_DeleteReservations_FullMethodName = "/reservations.v1.ReservationService/DeleteReservations"
+	ReservationService_GetStocks_FullMethodName          = "/reservations.v1.ReservationService/GetStocks"
+)
+
+// ReservationServiceClient is the client API for ReservationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReservationServiceClient interface {
+	// CreateReservations reserves all stocks from request in a single transaction.
+	CreateReservations(ctx context.Context, in *CreateReservationsRequest, opts ...grpc.CallOption) (*CreateReservationsResponse, error)
+	// DeleteReservations releases all reservations from request in a single transaction.
+	DeleteReservations(ctx context.Context, in *DeleteReservationsRequest, opts ...grpc.CallOption) (*DeleteReservationsResponse, error)
+	// GetStocks returns stocks with necessary filters.
+	GetStocks(ctx context.Context, in *GetStocksRequest, opts ...grpc.CallOption) (*GetStocksResponse, error)
+}
+
+type reservationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReservationServiceClient(cc grpc.ClientConnInterface) ReservationServiceClient {
+	return &reservationServiceClient{cc}
+}
+
+func (c *reservationServiceClient) CreateReservations(ctx context.Context, in *CreateReservationsRequest, opts ...grpc.CallOption) (*CreateReservationsResponse, error) {
+	out := new(CreateReservationsResponse)
+	err := c.cc.Invoke(ctx, ReservationService_CreateReservations_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reservationServiceClient) DeleteReservations(ctx context.Context, in *DeleteReservationsRequest, opts ...grpc.CallOption) (*DeleteReservationsResponse, error) {
+	out := new(DeleteReservationsResponse)
+	err := c.cc.Invoke(ctx, ReservationService_DeleteReservations_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reservationServiceClient) GetStocks(ctx context.Context, in *GetStocksRequest, opts ...grpc.CallOption) (*GetStocksResponse, error) {
+	out := new(GetStocksResponse)
+	err := c.cc.Invoke(ctx, ReservationService_GetStocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReservationServiceServer is the server API for ReservationService service.
+// All implementations must embed UnimplementedReservationServiceServer
+// for forward compatibility
+type ReservationServiceServer interface {
+	// CreateReservations reserves all stocks from request in a single transaction.
+	CreateReservations(context.Context, *CreateReservationsRequest) (*CreateReservationsResponse, error)
+	// DeleteReservations releases all reservations from request in a single transaction.
+	DeleteReservations(context.Context, *DeleteReservationsRequest) (*DeleteReservationsResponse, error)
+	// GetStocks returns stocks with necessary filters.
+	GetStocks(context.Context, *GetStocksRequest) (*GetStocksResponse, error)
+	mustEmbedUnimplementedReservationServiceServer()
+}
+
+// UnimplementedReservationServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedReservationServiceServer struct {
+}
+
+func (UnimplementedReservationServiceServer) CreateReservations(context.Context, *CreateReservationsRequest) (*CreateReservationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateReservations not implemented")
+}
+func (UnimplementedReservationServiceServer) DeleteReservations(context.Context, *DeleteReservationsRequest) (*DeleteReservationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteReservations not implemented")
+}
+func (UnimplementedReservationServiceServer) GetStocks(context.Context, *GetStocksRequest) (*GetStocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStocks not implemented")
+}
+func (UnimplementedReservationServiceServer) mustEmbedUnimplementedReservationServiceServer() {}
+
+// UnsafeReservationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReservationServiceServer will
+// result in compilation errors.
+type UnsafeReservationServiceServer interface {
+	mustEmbedUnimplementedReservationServiceServer()
+}
+
+func RegisterReservationServiceServer(s grpc.ServiceRegistrar, srv ReservationServiceServer) {
+	s.RegisterService(&ReservationService_ServiceDesc, srv)
+}
+
+func _ReservationService_CreateReservations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateReservationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReservationServiceServer).CreateReservations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReservationService_CreateReservations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReservationServiceServer).CreateReservations(ctx, req.(*CreateReservationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReservationService_DeleteReservations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteReservationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReservationServiceServer).DeleteReservations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReservationService_DeleteReservations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReservationServiceServer).DeleteReservations(ctx, req.(*DeleteReservationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReservationService_GetStocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReservationServiceServer).GetStocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReservationService_GetStocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReservationServiceServer).GetStocks(ctx, req.(*GetStocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ReservationService_ServiceDesc is the grpc.ServiceDesc for ReservationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReservationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "reservations.v1.ReservationService",
+	HandlerType: (*ReservationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateReservations",
+			Handler:    _ReservationService_CreateReservations_Handler,
+		},
+		{
+			MethodName: "DeleteReservations",
+			Handler:    _ReservationService_DeleteReservations_Handler,
+		},
+		{
+			MethodName: "GetStocks",
+			Handler:    _ReservationService_GetStocks_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "reservations/v1/reservations.proto",
+}
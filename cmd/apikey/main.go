@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/config"
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/Saaghh/lamoda-hr/internal/service"
+	"github.com/Saaghh/lamoda-hr/internal/store"
+	"github.com/google/uuid"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+const usage = `Usage:
+  apikey create -name <name> -scopes <scope,...>
+  apikey list
+  apikey revoke -id <id>
+
+Scopes: %s
+Database is configured with the same environment variables as apiserver.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cfg := config.New()
+
+	pgStore, err := store.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("store.New(ctx, cfg): %w", err)
+	}
+
+	if err = pgStore.Migrate(migrate.Up); err != nil {
+		return fmt.Errorf("pgStore.Migrate(migrate.Up): %w", err)
+	}
+
+	serviceLayer := service.New(pgStore)
+
+	switch command {
+	case "create":
+		return create(ctx, serviceLayer, args)
+	case "list":
+		return list(ctx, serviceLayer)
+	case "revoke":
+		return revoke(ctx, serviceLayer, args)
+	default:
+		printUsage()
+
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func create(ctx context.Context, serviceLayer *service.Service, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "client name, shown in listings and logs")
+	scopesList := flags.String("scopes", "", "comma separated scopes")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("flags.Parse(args): %w", err)
+	}
+
+	scopes := make([]model.Scope, 0)
+
+	for _, value := range strings.Split(*scopesList, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			scopes = append(scopes, model.Scope(value))
+		}
+	}
+
+	key, secret, err := serviceLayer.CreateAPIKey(ctx, *name, scopes)
+	if err != nil {
+		return fmt.Errorf("serviceLayer.CreateAPIKey(ctx, name, scopes): %w", err)
+	}
+
+	fmt.Printf("id: %s\nkey: %s\n", key.ID, secret)
+	fmt.Println("key is shown only once, save it now")
+
+	return nil
+}
+
+func list(ctx context.Context, serviceLayer *service.Service) error {
+	keys, err := serviceLayer.GetAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("serviceLayer.GetAPIKeys(ctx): %w", err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tREVOKED")
+
+	for _, key := range *keys {
+		scopes := make([]string, 0, len(key.Scopes))
+		for _, scope := range key.Scopes {
+			scopes = append(scopes, string(scope))
+		}
+
+		revoked := "-"
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, key.Prefix, strings.Join(scopes, ","), key.CreatedAt.Format(time.RFC3339), revoked)
+	}
+
+	if err = writer.Flush(); err != nil {
+		return fmt.Errorf("writer.Flush(): %w", err)
+	}
+
+	return nil
+}
+
+func revoke(ctx context.Context, serviceLayer *service.Service, args []string) error {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	idValue := flags.String("id", "", "id of key")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("flags.Parse(args): %w", err)
+	}
+
+	id, err := uuid.Parse(*idValue)
+	if err != nil {
+		return fmt.Errorf("uuid.Parse(%s): %w", *idValue, err)
+	}
+
+	if err = serviceLayer.RevokeAPIKey(ctx, id); err != nil {
+		return fmt.Errorf("serviceLayer.RevokeAPIKey(ctx, id): %w", err)
+	}
+
+	fmt.Printf("key %s revoked\n", id)
+
+	return nil
+}
+
+func printUsage() {
+	scopes := make([]string, 0, len(model.Scopes))
+	for _, scope := range model.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	fmt.Fprintf(os.Stderr, usage, strings.Join(scopes, ", "))
+}
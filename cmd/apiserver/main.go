@@ -8,8 +8,13 @@ import (
 	"time"
 
 	"github.com/Saaghh/lamoda-hr/internal/apiserver"
+	"github.com/Saaghh/lamoda-hr/internal/auth"
 	"github.com/Saaghh/lamoda-hr/internal/config"
+	"github.com/Saaghh/lamoda-hr/internal/grpcserver"
 	"github.com/Saaghh/lamoda-hr/internal/logger"
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/Saaghh/lamoda-hr/internal/notifier"
+	"github.com/Saaghh/lamoda-hr/internal/ratelimit"
 	"github.com/Saaghh/lamoda-hr/internal/service"
 	"github.com/Saaghh/lamoda-hr/internal/store"
 	migrate "github.com/rubenv/sql-migrate"
@@ -40,13 +45,51 @@ func main() {
 	}
 
 	zap.L().Info("successful migration")
+	zap.L().Info("starting instance", zap.String("instanceId", cfg.InstanceID))
 
 	serviceLayer := service.New(pgStore)
+
+	if cfg.JWTSecret != "" || cfg.JWTJWKSFile != "" {
+		verifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+			Secret:   cfg.JWTSecret,
+			JWKSFile: cfg.JWTJWKSFile,
+			Issuer:   cfg.JWTIssuer,
+			Audience: cfg.JWTAudience,
+		})
+		if err != nil {
+			zap.L().With(zap.Error(err)).Panic("main/auth.NewJWTVerifier(cfg)")
+		}
+
+		serviceLayer.SetTokenVerifier(verifier)
+	}
+
+	serviceLayer.AllowPrivateWebhookHosts(cfg.WebhooksAllowPrivateHosts)
+
+	shutdownDelay, err := time.ParseDuration(cfg.ShutdownDelay)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Panic("main/time.ParseDuration(cfg.ShutdownDelay)")
+	}
+
 	server := apiserver.New(
-		apiserver.Config{BindAddress: cfg.BindAddress},
+		apiserver.Config{BindAddress: cfg.BindAddress, AuthEnabled: cfg.AuthEnabled, ShutdownDelay: shutdownDelay},
+		serviceLayer,
+	)
+
+	grpcServer := grpcserver.New(
+		grpcserver.Config{BindAddress: cfg.GRPCBindAddress, AuthEnabled: cfg.AuthEnabled},
 		serviceLayer,
 	)
 
+	limiter, err := newRateLimiter(cfg, pgStore)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Panic("main/newRateLimiter(cfg, pgStore)")
+	}
+
+	if limiter != nil {
+		server.SetRateLimiter(limiter)
+		grpcServer.SetRateLimiter(limiter)
+	}
+
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.Go(func() error {
 		if err = server.Run(ctx); err != nil {
@@ -56,14 +99,143 @@ func main() {
 		return nil
 	})
 
+	eg.Go(func() error {
+		if err := grpcServer.Run(ctx); err != nil {
+			return fmt.Errorf("grpcServer.Run(ctx): %w", err)
+		}
+
+		return nil
+	})
+
 	eg.Go(func() error {
 		period, err := time.ParseDuration(cfg.DeactivatorPeriod)
 		if err != nil {
 			return fmt.Errorf("time.ParseDuration(cfg.DeactivatorPeriod): %w", err)
 		}
 
-		if err = serviceLayer.RunReservationsDeactivations(ctx, period); err != nil {
-			return fmt.Errorf("serviceLayer.RunReservationsDeactivations(ctx, period): %w", err)
+		var leaseTTL time.Duration
+
+		if cfg.DeactivatorLeaseTTL != "" {
+			leaseTTL, err = time.ParseDuration(cfg.DeactivatorLeaseTTL)
+			if err != nil {
+				return fmt.Errorf("time.ParseDuration(cfg.DeactivatorLeaseTTL): %w", err)
+			}
+		}
+
+		err = serviceLayer.RunReservationsDeactivations(ctx, service.DeactivatorConfig{
+			Period:     period,
+			BatchSize:  cfg.DeactivatorBatchSize,
+			InstanceID: cfg.InstanceID,
+			LeaseTTL:   leaseTTL,
+		})
+		if err != nil {
+			return fmt.Errorf("serviceLayer.RunReservationsDeactivations(ctx, cfg): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		period, err := time.ParseDuration(cfg.ArchiverPeriod)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.ArchiverPeriod): %w", err)
+		}
+
+		retentionAge, err := time.ParseDuration(cfg.ArchiverRetentionAge)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.ArchiverRetentionAge): %w", err)
+		}
+
+		eventsRetentionAge, err := time.ParseDuration(cfg.StockEventsRetentionAge)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.StockEventsRetentionAge): %w", err)
+		}
+
+		deliveriesRetentionAge, err := time.ParseDuration(cfg.WebhooksDeliveriesRetentionAge)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.WebhooksDeliveriesRetentionAge): %w", err)
+		}
+
+		err = serviceLayer.RunReservationsArchivation(ctx, service.ArchiverConfig{
+			Period:                 period,
+			RetentionAge:           retentionAge,
+			EventsRetentionAge:     eventsRetentionAge,
+			DeliveriesRetentionAge: deliveriesRetentionAge,
+			BatchSize:              cfg.ArchiverBatchSize,
+			InstanceID:             cfg.InstanceID,
+		})
+		if err != nil {
+			return fmt.Errorf("serviceLayer.RunReservationsArchivation(ctx, cfg): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		if err := serviceLayer.RunStockEvents(ctx); err != nil {
+			return fmt.Errorf("serviceLayer.RunStockEvents(ctx): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		period, err := time.ParseDuration(cfg.WebhooksPeriod)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.WebhooksPeriod): %w", err)
+		}
+
+		backoff, err := time.ParseDuration(cfg.WebhooksBackoff)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.WebhooksBackoff): %w", err)
+		}
+
+		maxBackoff, err := time.ParseDuration(cfg.WebhooksMaxBackoff)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.WebhooksMaxBackoff): %w", err)
+		}
+
+		timeout, err := time.ParseDuration(cfg.WebhooksTimeout)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.WebhooksTimeout): %w", err)
+		}
+
+		err = serviceLayer.RunWebhooks(ctx, service.WebhooksConfig{
+			Period:      period,
+			MaxAttempts: cfg.WebhooksMaxAttempts,
+			Backoff:     backoff,
+			MaxBackoff:  maxBackoff,
+			InstanceID:  cfg.InstanceID,
+		}, notifier.NewDeliverySender(timeout, cfg.WebhooksAllowPrivateHosts))
+		if err != nil {
+			return fmt.Errorf("serviceLayer.RunWebhooks(ctx, cfg, sender): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		if cfg.NotifierSink == notifier.SinkNone {
+			return nil
+		}
+
+		period, err := time.ParseDuration(cfg.NotifierPeriod)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.NotifierPeriod): %w", err)
+		}
+
+		leadTime, err := time.ParseDuration(cfg.NotifierLeadTime)
+		if err != nil {
+			return fmt.Errorf("time.ParseDuration(cfg.NotifierLeadTime): %w", err)
+		}
+
+		sink, err := notifier.New(notifier.Config{Sink: cfg.NotifierSink, WebhookURL: cfg.NotifierWebhookURL})
+		if err != nil {
+			return fmt.Errorf("notifier.New(notifier.Config): %w", err)
+		}
+
+		if err = serviceLayer.RunExpiryNotifications(ctx, period, leadTime, sink); err != nil {
+			return fmt.Errorf("serviceLayer.RunExpiryNotifications(ctx, period, leadTime, sink): %w", err)
 		}
 
 		return nil
@@ -73,3 +245,30 @@ func main() {
 		zap.L().With(zap.Error(err)).Panic("main/eg.Wait()")
 	}
 }
+
+func newRateLimiter(cfg *config.Config, pgStore *store.Postgres) (*ratelimit.Limiter, error) {
+	limit, err := model.ParseRateLimit(cfg.RateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("model.ParseRateLimit(cfg.RateLimit): %w", err)
+	}
+
+	routes := make(map[string]model.RateLimit, len(cfg.RateLimitRoutes))
+
+	for route, value := range cfg.RateLimitRoutes {
+		if routes[route], err = model.ParseRateLimit(value); err != nil {
+			return nil, fmt.Errorf("model.ParseRateLimit(%s): %w", route, err)
+		}
+	}
+
+	limiter, err := ratelimit.New(ratelimit.Config{
+		Backend:       cfg.RateLimitBackend,
+		Limit:         limit,
+		Routes:        routes,
+		MaxConcurrent: cfg.RateLimitMaxConcurrent,
+	}, pgStore)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit.New(cfg): %w", err)
+	}
+
+	return limiter, nil
+}
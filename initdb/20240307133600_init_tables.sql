@@ -17,7 +17,8 @@ CREATE TABLE stocks (
     warehouse_id uuid not null references warehouses(id),
     product_id varchar (12) references products(sku),
     quantity int not null check ( quantity >= 0 ),
-    reserved_quantity int not null check ( reserved_quantity <= stocks.quantity ) check ( reserved_quantity >= 0 ),
+    -- reserved quantity may exceed quantity within overbooking allowance, which is checked by the service
+    reserved_quantity int not null constraint stocks_reserved_quantity_check check ( reserved_quantity >= 0 ),
     created_at timestamp with time zone not null default now(),
     modified_at timestamp with time zone not null default now()
 );
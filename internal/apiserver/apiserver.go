@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/Saaghh/lamoda-hr/internal/model"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -16,16 +18,29 @@ type APIServer struct {
 	cfg     Config
 	server  *http.Server
 	service service
+	limiter rateLimiter
+	// shuttingDown makes readiness fail, so load balancers stop sending requests before server closes
+	shuttingDown atomic.Bool
+	// streamsDone is closed when server shutdown starts. Shutdown doesn't wait for streams to end by themselves,
+	// so streams are stopped by it.
+	streamsDone chan struct{}
 }
 
 type Config struct {
 	BindAddress string
+	// ValidateResponses makes server check responses against api specification. It is meant for tests,
+	// mismatching response is replaced with internal error.
+	ValidateResponses bool
+	// AuthEnabled makes server require api key with scope of called method
+	AuthEnabled bool
+	// ShutdownDelay is the time server keeps serving requests after shutdown started, while readiness reports 503.
+	ShutdownDelay time.Duration
 }
 
 func New(cfg Config, service service) *APIServer {
 	router := chi.NewRouter()
 
-	return &APIServer{
+	s := &APIServer{
 		cfg:     cfg,
 		service: service,
 		router:  router,
@@ -34,28 +49,39 @@ func New(cfg Config, service service) *APIServer {
 			ReadHeaderTimeout: 5 * time.Second,
 			Handler:           router,
 		},
+		streamsDone: make(chan struct{}),
 	}
+
+	s.server.RegisterOnShutdown(func() { close(s.streamsDone) })
+
+	return s
 }
 
+// Run serves requests until ctx is done. Then it waits for requests in flight and returns error of shutdown.
 func (s *APIServer) Run(ctx context.Context) error {
 	defer zap.L().Info("server stopped")
 
-	s.configRouter()
+	if err := s.configRouter(); err != nil {
+		return fmt.Errorf("s.configRouter(): %w", err)
+	}
+
+	shutdownErr := make(chan error, 1)
 
 	go func() {
 		<-ctx.Done()
 
+		s.shuttingDown.Store(true)
+
+		// load balancers notice failing readiness and stop sending requests during the delay
+		time.Sleep(s.cfg.ShutdownDelay)
+
 		gfCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
 		zap.L().Debug("attempting graceful shutdown")
 
 		//nolint: contextcheck
-		if err := s.server.Shutdown(gfCtx); err != nil {
-			zap.L().With(zap.Error(err)).Warn("failed to gracefully shutdown server")
-
-			return
-		}
+		shutdownErr <- s.server.Shutdown(gfCtx)
 	}()
 
 	zap.L().Info("sever starting", zap.String("port", s.cfg.BindAddress))
@@ -64,16 +90,60 @@ func (s *APIServer) Run(ctx context.Context) error {
 		return fmt.Errorf("s.server.ListenAndServe(): %w", err)
 	}
 
+	// ListenAndServe returns as soon as shutdown starts, requests in flight are waited for by Shutdown
+	if err := <-shutdownErr; err != nil {
+		return fmt.Errorf("s.server.Shutdown(ctx): %w", err)
+	}
+
 	return nil
 }
 
-func (s *APIServer) configRouter() {
+func (s *APIServer) configRouter() error {
+	validatorV1, err := newValidator("openapi.yaml", "/api/v1", s.cfg.ValidateResponses)
+	if err != nil {
+		return fmt.Errorf("newValidator(openapi.yaml): %w", err)
+	}
+
+	validatorV2, err := newValidator("openapi.v2.yaml", "/api/v2", s.cfg.ValidateResponses)
+	if err != nil {
+		return fmt.Errorf("newValidator(openapi.v2.yaml): %w", err)
+	}
+
+	s.router.Get("/healthz", s.healthz)
+	s.router.Get("/readyz", s.readyz)
+
 	s.router.Route("/api", func(r chi.Router) {
+		r.Use(s.authenticate)
+		r.Use(s.rateLimit)
+
 		r.Route("/v1", func(r chi.Router) {
-			r.Post("/createReservations", s.createReservations)
-			r.Post("/deleteReservations", s.deleteReservations)
+			r.Use(validatorV1.middleware)
+
+			r.With(s.requireScope(model.ScopeReservationsWrite)).Post("/createReservations", s.createReservations)
+			r.With(s.requireScope(model.ScopeReservationsWrite)).Post("/deleteReservations", s.deleteReservations)
+			r.With(s.requireScope(model.ScopeReservationsRead)).Post("/getReservations", s.getReservations)
+
+			r.With(s.requireScope(model.ScopeStocksRead)).Post("/getStocks", s.getStocks)
+			r.With(s.requireScope(model.ScopeStocksRead)).Post("/getAvailability", s.getAvailability)
+
+			r.With(s.requireScope(model.ScopeAdmin)).Post("/setOverbookingAllowance", s.setOverbookingAllowance)
+			r.With(s.requireScope(model.ScopeStocksRead)).Post("/getOverbookedStocks", s.getOverbookedStocks)
+
+			r.With(s.requireScope(model.ScopeAdmin)).Post("/getWorkerLeases", s.getWorkerLeases)
 
-			r.Post("/getStocks", s.getStocks)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(s.requireScope(model.ScopeAdmin))
+
+				r.Post("/deactivateDueReservations", s.deactivateDueReservations)
+			})
+		})
+
+		r.Route("/v2", func(r chi.Router) {
+			r.Use(validatorV2.middleware)
+
+			s.configRouterV2(r)
 		})
 	})
+
+	return nil
 }
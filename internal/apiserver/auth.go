@@ -0,0 +1,76 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// authenticate puts client, which owns api key or bearer token of request, into request context.
+// Api key is taken from X-API-Key header, api key or token - from bearer Authorization header.
+func (s *APIServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.cfg.AuthEnabled {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		client, err := s.service.Authenticate(r.Context(), credentialsFromRequest(r))
+
+		switch {
+		case errors.Is(err, model.ErrUnauthorized):
+			writeErrorResponse(w, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid credentials")
+
+			return
+		case err != nil:
+			zap.L().With(zap.Error(err)).Warn("authenticate/s.service.Authenticate(r.Context(), key)")
+
+			writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(model.ContextWithClient(r.Context(), client)))
+	})
+}
+
+// requireScope rejects requests of clients without scope. It does nothing if authentication is disabled.
+func (s *APIServer) requireScope(scope model.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.cfg.AuthEnabled {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			client, ok := model.ClientFromContext(r.Context())
+			if !ok || !client.HasScope(scope) {
+				writeErrorResponse(w, http.StatusForbidden, CodeForbidden, "client has no "+string(scope)+" scope")
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func credentialsFromRequest(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+
+	key, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+
+	return strings.TrimSpace(key)
+}
@@ -0,0 +1,144 @@
+package apiserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+)
+
+// etagLength is amount of hash hex digits kept in etag.
+const etagLength = 16
+
+// stockVersion describes stock for etag. Any change of stock updates its modified_at.
+func stockVersion(stock model.Stock) string {
+	return fmt.Sprintf("%s/%s@%d", stock.WarehouseID, stock.ProductID, stock.ModifiedAt.UnixNano())
+}
+
+// stockETag is strong etag of a single stock, e.g. "3". It is stock version, which grows with every change
+// of stock, so it can be sent back in If-Match of stock change.
+func stockETag(stock model.Stock) string {
+	return fmt.Sprintf(`"%d"`, stock.Version)
+}
+
+// stocksETag identifies state of stocks list. Page meta is included, because stocks appeared or removed
+// outside of page change total and next page without changing stocks of page.
+func stocksETag(page *model.Page[model.Stock]) (string, time.Time) {
+	versions := make([]string, 0, len(page.Items)+1)
+
+	var lastModified time.Time
+
+	for _, stock := range page.Items {
+		versions = append(versions, stockVersion(stock))
+
+		if stock.ModifiedAt.After(lastModified) {
+			lastModified = stock.ModifiedAt
+		}
+	}
+
+	total := int64(-1)
+	if page.Total != nil {
+		total = *page.Total
+	}
+
+	versions = append(versions, fmt.Sprintf("total=%d,next=%t", total, page.HasMore))
+
+	return newETag(versions...), lastModified
+}
+
+// availabilityETag identifies state of products availability. Amount of breakdown items is included,
+// because warehouses without stocks changes, like deactivated ones, leave modified_at of stocks as is.
+func availabilityETag(products []model.ProductAvailability) (string, time.Time) {
+	versions := make([]string, 0, len(products))
+
+	var lastModified time.Time
+
+	for _, product := range products {
+		version := fmt.Sprintf("%s:%d", product.ProductID, len(product.Breakdown))
+
+		if product.ModifiedAt != nil {
+			version += fmt.Sprintf("@%d", product.ModifiedAt.UnixNano())
+
+			if product.ModifiedAt.After(lastModified) {
+				lastModified = *product.ModifiedAt
+			}
+		}
+
+		versions = append(versions, version)
+	}
+
+	return newETag(versions...), lastModified
+}
+
+// newETag returns weak etag, because the same state may be serialized differently, e.g. by v1 and v2.
+func newETag(versions ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(versions, "\n")))
+
+	return `W/"` + hex.EncodeToString(sum[:])[:etagLength] + `"`
+}
+
+// notModified sets ETag and Last-Modified headers of GET response and answers with 304 if client already has
+// this state. If-None-Match takes precedence over If-Modified-Since. Zero lastModified isn't sent.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	w.Header().Set("ETag", etag)
+
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatches(match, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// etagMatches compares etags weakly, as required for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+
+		if value == "*" || strings.TrimPrefix(value, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expectedVersion parses If-Match header of stock change. It carries stock etag as it is returned by GET,
+// which is stock version, e.g. "3". Absent header and "*" don't restrict version.
+// Weak etags can't be used in If-Match.
+func expectedVersion(r *http.Request) (*uint, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil //nolint:nilnil
+	}
+
+	value, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 0)
+	if err != nil {
+		return nil, errInvalidQuery
+	}
+
+	version := uint(value)
+
+	return &version, nil
+}
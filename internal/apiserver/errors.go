@@ -0,0 +1,120 @@
+package apiserver
+
+import (
+	"errors"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+)
+
+// ErrorCode is a stable identifier of error. Clients should rely on it instead of error message.
+type ErrorCode string
+
+const (
+	CodeInvalidBody          ErrorCode = "INVALID_BODY"
+	CodeInvalidParams        ErrorCode = "INVALID_PARAMS"
+	CodeInvalidUUID          ErrorCode = "INVALID_UUID"
+	CodeInvalidSKU           ErrorCode = "INVALID_SKU"
+	CodeInvalidQuantity      ErrorCode = "INVALID_QUANTITY"
+	CodeInvalidDueDate       ErrorCode = "INVALID_DUE_DATE"
+	CodeInvalidLimit         ErrorCode = "INVALID_LIMIT"
+	CodeInvalidCursor        ErrorCode = "INVALID_CURSOR"
+	CodeInvalidSortField     ErrorCode = "INVALID_SORT_FIELD"
+	CodeInvalidOverbooking   ErrorCode = "INVALID_OVERBOOKING"
+	CodeInvalidRegion        ErrorCode = "INVALID_REGION"
+	CodeInvalidAsOf          ErrorCode = "INVALID_AS_OF"
+	CodeDuplicateReservation ErrorCode = "DUPLICATE_RESERVATION"
+	CodeStockNotFound        ErrorCode = "STOCK_NOT_FOUND"
+	CodeWarehouseNotFound    ErrorCode = "WAREHOUSE_NOT_FOUND"
+	CodeProductNotFound      ErrorCode = "PRODUCT_NOT_FOUND"
+	CodeReservationNotFound  ErrorCode = "RESERVATION_NOT_FOUND"
+	CodeNotEnoughQuantity    ErrorCode = "NOT_ENOUGH_QUANTITY"
+	CodeNegativeStock        ErrorCode = "NEGATIVE_STOCK"
+	CodeVersionConflict      ErrorCode = "VERSION_CONFLICT"
+	CodeBelowReserved        ErrorCode = "BELOW_RESERVED"
+	CodeInvalidWebhook       ErrorCode = "INVALID_WEBHOOK"
+	CodeWebhookNotFound      ErrorCode = "WEBHOOK_NOT_FOUND"
+	CodeDeliveryNotFound     ErrorCode = "DELIVERY_NOT_FOUND"
+	CodeUnauthorized         ErrorCode = "UNAUTHORIZED"
+	CodeForbidden            ErrorCode = "FORBIDDEN"
+	CodeRateLimited          ErrorCode = "RATE_LIMITED"
+	CodeInternal             ErrorCode = "INTERNAL"
+)
+
+type APIError struct {
+	Code    ErrorCode     `json:"code"`
+	Message string        `json:"message"`
+	Details *ErrorDetails `json:"details,omitempty"`
+}
+
+// ErrorDetails contains fields of typed errors. Only fields related to the error are set.
+type ErrorDetails struct {
+	// ItemIndex points at the failing item of batch request
+	ItemIndex         *int       `json:"itemIndex,omitempty"`
+	ReservationID     *uuid.UUID `json:"reservationId,omitempty"`
+	WarehouseID       *uuid.UUID `json:"warehouseId,omitempty"`
+	ProductID         string     `json:"productId,omitempty"`
+	RequestedQuantity *uint      `json:"requestedQuantity,omitempty"`
+	AvailableQuantity *uint      `json:"availableQuantity,omitempty"`
+	MinQuantity       *uint      `json:"minQuantity,omitempty"`
+	Field             string     `json:"field,omitempty"`
+	AllowedValues     []string   `json:"allowedValues,omitempty"`
+}
+
+// errorDetails collects fields of typed errors in err chain. It returns nil if there is nothing to report.
+func errorDetails(err error) *ErrorDetails {
+	var (
+		details ErrorDetails
+		found   bool
+
+		errItem                 *model.ItemError
+		errDuplicateReservation *model.DuplicateReservationError
+		errStockNotFound        *model.StockNotFoundError
+		errNotEnoughQuantity    *model.NotEnoughQuantityError
+		errReservationNotFound  *model.ReservationNotFoundError
+		errWarehouseNotFound    *model.WarehouseNotFoundError
+		errProductNotFound      *model.ProductNotFoundError
+		errInvalidSortField     *model.InvalidSortFieldError
+		errWarehouseForbidden   *model.WarehouseForbiddenError
+		errVersionConflict      *model.StockVersionConflictError
+		errBelowReserved        *model.StockBelowReservedError
+	)
+
+	if errors.As(err, &errItem) {
+		details.ItemIndex, found = &errItem.Index, true
+	}
+
+	switch {
+	case errors.As(err, &errDuplicateReservation):
+		details.ReservationID, found = &errDuplicateReservation.ReservationID, true
+	case errors.As(err, &errStockNotFound):
+		details.WarehouseID, details.ProductID, found = &errStockNotFound.WarehouseID, errStockNotFound.SKU, true
+	case errors.As(err, &errNotEnoughQuantity):
+		details.WarehouseID, details.ProductID = &errNotEnoughQuantity.WarehouseID, errNotEnoughQuantity.SKU
+		details.RequestedQuantity = &errNotEnoughQuantity.RequiredQuantity
+		details.AvailableQuantity, found = &errNotEnoughQuantity.AvailableQuantity, true
+	case errors.As(err, &errReservationNotFound):
+		details.ReservationID, found = &errReservationNotFound.ReservationID, true
+	case errors.As(err, &errWarehouseNotFound):
+		details.WarehouseID, found = &errWarehouseNotFound.WarehouseID, true
+	case errors.As(err, &errProductNotFound):
+		details.ProductID, found = errProductNotFound.SKU, true
+	case errors.As(err, &errInvalidSortField):
+		details.Field, details.AllowedValues, found = "sorting", errInvalidSortField.Allowed, true
+	case errors.As(err, &errWarehouseForbidden):
+		details.WarehouseID, found = &errWarehouseForbidden.WarehouseID, true
+	case errors.As(err, &errVersionConflict):
+		details.WarehouseID, details.ProductID = &errVersionConflict.Current.WarehouseID, errVersionConflict.Current.ProductID
+		found = true
+	case errors.As(err, &errBelowReserved):
+		details.WarehouseID, details.ProductID = &errBelowReserved.Current.WarehouseID, errBelowReserved.Current.ProductID
+		details.RequestedQuantity, details.MinQuantity = &errBelowReserved.RequestedQuantity, &errBelowReserved.MinQuantity
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+
+	return &details
+}
@@ -0,0 +1,191 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// eventsHeartbeatPeriod is how often comment is sent to idle stream, so proxies don't close it.
+const eventsHeartbeatPeriod = 15 * time.Second
+
+// streamStockEventsV2 sends stock events as server-sent events until client disconnects or server shuts down.
+// Client resumes stream with Last-Event-ID header, which browsers send on reconnect by themselves.
+func (s *APIServer) streamStockEventsV2(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStockEventsFilter(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	var afterID *int64
+
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 0 {
+			writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid Last-Event-ID header")
+
+			return
+		}
+
+		afterID = &id
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.streamsDone:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stream := newEventStream(w)
+
+	if err = stream.start(); err != nil {
+		zap.L().With(zap.Error(err)).Warn("streamStockEventsV2/stream.start()")
+
+		return
+	}
+
+	defer stream.stop()
+
+	err = s.service.StreamStockEvents(ctx, filter, afterID, stream.send)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrStockEventsLagged):
+		// client reconnects and continues from the last received event
+		zap.L().With(zap.Error(err)).Debug(
+			"streamStockEventsV2/s.service.StreamStockEvents(ctx, filter, afterID, stream.send)")
+	case ctx.Err() == nil:
+		zap.L().With(zap.Error(err)).Warn(
+			"streamStockEventsV2/s.service.StreamStockEvents(ctx, filter, afterID, stream.send)")
+	}
+}
+
+func parseStockEventsFilter(query url.Values) (model.StockEventsFilter, error) {
+	var filter model.StockEventsFilter
+
+	for _, value := range query["warehouseId"] {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return filter, errInvalidQuery
+		}
+
+		filter.WarehouseIDs = append(filter.WarehouseIDs, id)
+	}
+
+	filter.ProductIDs = query["productId"]
+
+	// stream starts before events are read, so invalid filter is reported while response can be an error
+	if err := model.ValidateStockEventsFilter(filter); err != nil {
+		return filter, fmt.Errorf("model.ValidateStockEventsFilter(filter): %w", err)
+	}
+
+	return filter, nil
+}
+
+// eventStream writes server-sent events. Events and heartbeats are written from different goroutines,
+// so every write holds mu.
+type eventStream struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+
+	mu   sync.Mutex
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	return &eventStream{
+		w:          w,
+		controller: http.NewResponseController(w),
+		done:       make(chan struct{}),
+	}
+}
+
+// start sends response headers right away, so client knows stream is open before the first event.
+func (e *eventStream) start() error {
+	e.w.Header().Set("Content-Type", "text/event-stream")
+	e.w.Header().Set("Cache-Control", "no-cache")
+	e.w.Header().Set("X-Accel-Buffering", "no")
+	e.w.WriteHeader(http.StatusOK)
+
+	if err := e.flush(); err != nil {
+		return fmt.Errorf("e.flush(): %w", err)
+	}
+
+	e.wg.Add(1)
+
+	go e.heartbeat()
+
+	return nil
+}
+
+func (e *eventStream) heartbeat() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(eventsHeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		}
+
+		if err := e.write([]byte(": heartbeat\n\n")); err != nil {
+			return
+		}
+	}
+}
+
+// stop waits for heartbeat, so nothing is written after handler returns.
+func (e *eventStream) stop() {
+	close(e.done)
+	e.wg.Wait()
+}
+
+func (e *eventStream) send(event model.StockEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(event): %w", err)
+	}
+
+	return e.write([]byte(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Kind, data)))
+}
+
+func (e *eventStream) write(message []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, err := e.w.Write(message); err != nil {
+		return fmt.Errorf("e.w.Write(message): %w", err)
+	}
+
+	return e.flush()
+}
+
+func (e *eventStream) flush() error {
+	err := e.controller.Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("e.controller.Flush(): %w", err)
+	}
+
+	return nil
+}
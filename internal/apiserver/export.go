@@ -0,0 +1,185 @@
+package apiserver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+
+	// exportFlushSize is amount of stocks written between flushes of response
+	exportFlushSize = 1000
+)
+
+var stocksCSVHeader = []string{
+	"warehouseId", "productId", "quantity", "reservedQuantity", "availableQuantity", "createdAt", "modifiedAt", "version",
+}
+
+// exportStocksV2 streams every stock matching filters as csv or ndjson. Format is taken from format param
+// or from Accept header. Export failed in the middle aborts response, so client doesn't take it as complete.
+func (s *APIServer) exportStocksV2(w http.ResponseWriter, r *http.Request) {
+	params, err := parseGetParams(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatNDJSON
+
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			format = exportFormatCSV
+		}
+	}
+
+	if format != exportFormatCSV && format != exportFormatNDJSON {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid export format")
+
+		return
+	}
+
+	encoder := newStocksEncoder(w, format)
+
+	err = s.service.ExportStocks(r.Context(), params, encoder.encode)
+	if err == nil {
+		err = encoder.close()
+	}
+
+	var errInvalidSortField *model.InvalidSortFieldError
+
+	switch {
+	case err == nil:
+		return
+	case encoder.started:
+		zap.L().With(zap.Error(err)).Warn("exportStocksV2/s.service.ExportStocks(r.Context(), params, encoder.encode)")
+
+		panic(http.ErrAbortHandler)
+	case errors.As(err, &errInvalidSortField):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidSortField, errInvalidSortField.Error(), err)
+	case errors.Is(err, model.ErrInvalidSKU):
+		fallthrough
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+	default:
+		zap.L().With(zap.Error(err)).Warn("exportStocksV2/s.service.ExportStocks(r.Context(), params, encoder.encode)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+	}
+}
+
+// stocksEncoder writes stocks to response as soon as they are read. Response starts with the first stock,
+// so errors happened before it can still be reported with error response.
+type stocksEncoder struct {
+	w       http.ResponseWriter
+	format  string
+	started bool
+	written int
+
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+func newStocksEncoder(w http.ResponseWriter, format string) *stocksEncoder {
+	return &stocksEncoder{w: w, format: format}
+}
+
+func (e *stocksEncoder) start() error {
+	e.started = true
+
+	if e.format == exportFormatNDJSON {
+		e.w.Header().Set("Content-Type", "application/x-ndjson")
+		e.w.WriteHeader(http.StatusOK)
+
+		e.json = json.NewEncoder(e.w)
+
+		return nil
+	}
+
+	e.w.Header().Set("Content-Type", "text/csv")
+	e.w.Header().Set("Content-Disposition", `attachment; filename="stocks.csv"`)
+	e.w.WriteHeader(http.StatusOK)
+
+	e.csv = csv.NewWriter(e.w)
+
+	if err := e.csv.Write(stocksCSVHeader); err != nil {
+		return fmt.Errorf("e.csv.Write(stocksCSVHeader): %w", err)
+	}
+
+	return nil
+}
+
+func (e *stocksEncoder) encode(stock model.Stock) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return fmt.Errorf("e.start(): %w", err)
+		}
+	}
+
+	if e.json != nil {
+		if err := e.json.Encode(stock); err != nil {
+			return fmt.Errorf("e.json.Encode(stock): %w", err)
+		}
+	} else {
+		err := e.csv.Write([]string{
+			stock.WarehouseID.String(),
+			stock.ProductID,
+			strconv.FormatUint(uint64(stock.Quantity), 10),
+			strconv.FormatUint(uint64(stock.ReservedQuantity), 10),
+			strconv.FormatUint(uint64(stock.AvailableQuantity), 10),
+			stock.CreatedAt.Format(time.RFC3339Nano),
+			stock.ModifiedAt.Format(time.RFC3339Nano),
+			strconv.FormatUint(uint64(stock.Version), 10),
+		})
+		if err != nil {
+			return fmt.Errorf("e.csv.Write(stock): %w", err)
+		}
+	}
+
+	if e.written++; e.written%exportFlushSize == 0 {
+		return e.flush()
+	}
+
+	return nil
+}
+
+// close starts response of empty export and flushes the rest of stocks.
+func (e *stocksEncoder) close() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return fmt.Errorf("e.start(): %w", err)
+		}
+	}
+
+	return e.flush()
+}
+
+func (e *stocksEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+
+		if err := e.csv.Error(); err != nil {
+			return fmt.Errorf("e.csv.Flush(): %w", err)
+		}
+	}
+
+	// writers without flush support send response as their buffer fills up
+	err := http.NewResponseController(e.w).Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("http.NewResponseController(e.w).Flush(): %w", err)
+	}
+
+	return nil
+}
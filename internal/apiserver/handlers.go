@@ -7,102 +7,179 @@ import (
 	"net/http"
 
 	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 type HTTPResponse struct {
-	Data  any    `json:"data,omitempty"`
-	Error string `json:"error,omitempty"`
+	Data  any       `json:"data,omitempty"`
+	Meta  *ListMeta `json:"meta,omitempty"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+// ListMeta describes page of list returned in HTTPResponse.Data.
+type ListMeta struct {
+	Limit      uint   `json:"limit"`
+	Offset     uint   `json:"offset"`
+	HasMore    bool   `json:"hasMore"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Total is returned only if it was requested
+	Total              *int64     `json:"total,omitempty"`
+	TotalIsApproximate bool       `json:"totalIsApproximate,omitempty"`
+	Links              *ListLinks `json:"links,omitempty"`
+}
+
+// ListLinks contains urls of neighbour pages. Only v2 lists have them.
+type ListLinks struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+func newListMeta[T any](page *model.Page[T]) *ListMeta {
+	return &ListMeta{
+		Limit:              page.Limit,
+		Offset:             page.Offset,
+		HasMore:            page.HasMore,
+		NextCursor:         page.NextCursor,
+		Total:              page.Total,
+		TotalIsApproximate: page.TotalIsApproximate,
+	}
 }
 
 type service interface {
 	CreateReservations(ctx context.Context, reservations []model.Reservation) (*[]model.Reservation, error)
 	DeleteReservations(ctx context.Context, reservations []model.Reservation) error
-
-	GetStocks(ctx context.Context, params model.GetParams) (*[]model.Stock, error)
+	GetReservations(ctx context.Context, params model.GetReservationsParams) (*model.Page[model.Reservation], error)
+	GetReservation(ctx context.Context, id uuid.UUID, includeArchived bool) (*model.Reservation, error)
+	UpdateReservation(ctx context.Context, id uuid.UUID, update model.ReservationUpdate) (*model.Reservation, error)
+
+	GetStocks(ctx context.Context, params model.GetParams) (*model.Page[model.Stock], error)
+	GetAvailability(ctx context.Context, params model.AvailabilityParams) (*[]model.ProductAvailability, error)
+	GetStock(ctx context.Context, warehouseID uuid.UUID, productID string) (*model.Stock, error)
+	ExportStocks(ctx context.Context, params model.GetParams, fn func(stock model.Stock) error) error
+	ChangeStock(ctx context.Context, change model.StockChange) (*model.Stock, error)
+	StreamStockEvents(
+		ctx context.Context,
+		filter model.StockEventsFilter,
+		afterID *int64,
+		fn func(event model.StockEvent) error,
+	) error
+
+	SetOverbookingAllowance(ctx context.Context, allowance model.OverbookingAllowance) error
+	SetWarehouseRegion(ctx context.Context, region model.WarehouseRegion) error
+	GetOverbookedStocks(ctx context.Context, params model.GetParams) (*model.Page[model.OverbookedStock], error)
+
+	GetLeases(ctx context.Context) (*[]model.Lease, error)
+
+	CreateWebhook(ctx context.Context, subscription model.WebhookSubscription) (*model.WebhookSubscription, error)
+	GetWebhooks(ctx context.Context) (*[]model.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	GetWebhookDeliveries(
+		ctx context.Context,
+		params model.GetWebhookDeliveriesParams,
+	) (*model.Page[model.WebhookDelivery], error)
+	RetryWebhookDelivery(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error)
+
+	Authenticate(ctx context.Context, secret string) (*model.Client, error)
+	CheckReadiness(ctx context.Context) model.Readiness
+	ForceDeactivation(ctx context.Context, request model.DeactivationRequest) (*model.DeactivationResult, error)
 }
 
 func (s *APIServer) createReservations(w http.ResponseWriter, r *http.Request) {
-	var reservations *[]model.Reservation
+	var reservations []model.Reservation
 
-	if err := json.NewDecoder(r.Body).Decode(&reservations); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "error reading body")
+	if err := json.NewDecoder(r.Body).Decode(&reservations); err != nil || reservations == nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
 
 		return
 	}
 
-	reservations, err := s.service.CreateReservations(r.Context(), *reservations)
+	created, err := s.service.CreateReservations(r.Context(), reservations)
 
 	var (
 		errDuplicateReservation *model.DuplicateReservationError
 		errStockNotFound        *model.StockNotFoundError
 		errNotEnoughQuantity    *model.NotEnoughQuantityError
+		errWarehouseForbidden   *model.WarehouseForbiddenError
 	)
 
 	switch {
 	case errors.Is(err, model.ErrInvalidSKU):
-		writeErrorResponse(w, http.StatusBadRequest, "invalid product sku")
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku", err)
 
 		return
 	case errors.Is(err, model.ErrInvalidUUID):
-		writeErrorResponse(w, http.StatusBadRequest, "invalid uuid")
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid", err)
 
 		return
 	case errors.Is(err, model.ErrInvalidQuantity):
-		writeErrorResponse(w, http.StatusBadRequest, "invalid quantity")
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidQuantity, "invalid quantity", err)
 
 		return
 	case errors.Is(err, model.ErrIncorrectDueDate):
-		writeErrorResponse(w, http.StatusBadRequest, "incorrect due date")
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidDueDate, "incorrect due date", err)
 
 		return
 	case errors.As(err, &errDuplicateReservation):
-		writeErrorResponse(w, http.StatusTooManyRequests, errDuplicateReservation.Error())
+		writeErrorDetails(w, http.StatusConflict, CodeDuplicateReservation, errDuplicateReservation.Error(), err)
 
 		return
 	case errors.As(err, &errNotEnoughQuantity):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, errNotEnoughQuantity.Error())
+		writeErrorDetails(w, http.StatusUnprocessableEntity, CodeNotEnoughQuantity, errNotEnoughQuantity.Error(), err)
 
 		return
 	case errors.As(err, &errStockNotFound):
-		writeErrorResponse(w, http.StatusNotFound, errStockNotFound.Error())
+		writeErrorDetails(w, http.StatusNotFound, CodeStockNotFound, errStockNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
 
 		return
 	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("createReservations/s.service.CreateReservations(r.Context(), *reservations)")
+		zap.L().With(zap.Error(err)).Warn("createReservations/s.service.CreateReservations(r.Context(), reservations)")
 
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusCreated, reservations)
+	writeOkResponse(w, http.StatusCreated, created)
 }
 
 func (s *APIServer) deleteReservations(w http.ResponseWriter, r *http.Request) {
-	var reservations *[]model.Reservation
+	var reservations []model.Reservation
+
+	if err := json.NewDecoder(r.Body).Decode(&reservations); err != nil || reservations == nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
 
-	if err := json.NewDecoder(r.Body).Decode(&reservations); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "error reading body")
+		return
 	}
 
-	err := s.service.DeleteReservations(r.Context(), *reservations)
+	err := s.service.DeleteReservations(r.Context(), reservations)
 
-	var errReservationNotFound *model.ReservationNotFoundError
+	var (
+		errReservationNotFound *model.ReservationNotFoundError
+		errWarehouseForbidden  *model.WarehouseForbiddenError
+	)
 
 	switch {
 	case errors.Is(err, model.ErrInvalidUUID):
-		writeErrorResponse(w, http.StatusBadRequest, "invalid uuid")
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid", err)
 
 		return
 	case errors.As(err, &errReservationNotFound):
-		writeErrorResponse(w, http.StatusNotFound, errReservationNotFound.Error())
+		writeErrorDetails(w, http.StatusNotFound, CodeReservationNotFound, errReservationNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
 
 		return
 	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("deleteReservations/s.service.DeleteReservations(r.Context(), *reservations)")
+		zap.L().With(zap.Error(err)).Warn("deleteReservations/s.service.DeleteReservations(r.Context(), reservations)")
 
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
 
 		return
 	}
@@ -110,33 +187,246 @@ func (s *APIServer) deleteReservations(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (s *APIServer) getReservations(w http.ResponseWriter, r *http.Request) {
+	var params model.GetReservationsParams
+
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	page, err := s.service.GetReservations(r.Context(), params)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku")
+
+		return
+	case errors.Is(err, model.ErrInvalidLimit):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidLimit, "invalid limit")
+
+		return
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("getReservations/s.service.GetReservations(r.Context(), params)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeListResponse(w, http.StatusOK, page.Items, newListMeta(page))
+}
+
 func (s *APIServer) getStocks(w http.ResponseWriter, r *http.Request) {
 	var params model.GetParams
 
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "error reading body")
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
 
 		return
 	}
 
-	stocks, err := s.service.GetStocks(r.Context(), params)
+	page, err := s.service.GetStocks(r.Context(), params)
+
+	var errInvalidSortField *model.InvalidSortFieldError
 
 	switch {
+	case errors.As(err, &errInvalidSortField):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidSortField, errInvalidSortField.Error(), err)
+
+		return
+	case errors.Is(err, model.ErrInvalidCursor):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+
+		return
 	case errors.Is(err, model.ErrInvalidSKU):
 		fallthrough
 	case errors.Is(err, model.ErrInvalidGetParams):
-		writeErrorResponse(w, http.StatusBadRequest, "invalid get params")
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("getStocks/s.service.GetStocks(r.Context(), params)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeListResponse(w, http.StatusOK, page.Items, newListMeta(page))
+}
+
+func (s *APIServer) getAvailability(w http.ResponseWriter, r *http.Request) {
+	var params model.AvailabilityParams
+
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	s.writeAvailability(w, r, params)
+}
+
+func (s *APIServer) writeAvailability(w http.ResponseWriter, r *http.Request, params model.AvailabilityParams) {
+	availability, err := s.service.GetAvailability(r.Context(), params)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku")
+
+		return
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
 
 		return
 	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("getStocks/s.service.GetWarehouseStocks(r.Context(), warehouseID)")
+		zap.L().With(zap.Error(err)).Warn("writeAvailability/s.service.GetAvailability(r.Context(), params)")
 
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
 
+	etag, lastModified := availabilityETag(*availability)
+	if notModified(w, r, etag, lastModified) {
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, stocks)
+	writeOkResponse(w, http.StatusOK, availability)
+}
+
+func (s *APIServer) setOverbookingAllowance(w http.ResponseWriter, r *http.Request) {
+	var allowance model.OverbookingAllowance
+
+	if err := json.NewDecoder(r.Body).Decode(&allowance); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	err := s.service.SetOverbookingAllowance(r.Context(), allowance)
+
+	var (
+		errWarehouseNotFound  *model.WarehouseNotFoundError
+		errProductNotFound    *model.ProductNotFoundError
+		errWarehouseForbidden *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku")
+
+		return
+	case errors.Is(err, model.ErrInvalidOverbooking):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidOverbooking, "invalid overbooking allowance")
+
+		return
+	case errors.As(err, &errWarehouseNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeWarehouseNotFound, errWarehouseNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errProductNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeProductNotFound, errProductNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("setOverbookingAllowance/s.service.SetOverbookingAllowance(r.Context(), allowance)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *APIServer) getOverbookedStocks(w http.ResponseWriter, r *http.Request) {
+	var params model.GetParams
+
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	page, err := s.service.GetOverbookedStocks(r.Context(), params)
+
+	var errInvalidSortField *model.InvalidSortFieldError
+
+	switch {
+	case errors.As(err, &errInvalidSortField):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidSortField, errInvalidSortField.Error(), err)
+
+		return
+	case errors.Is(err, model.ErrInvalidSKU):
+		fallthrough
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("getOverbookedStocks/s.service.GetOverbookedStocks(r.Context(), params)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeListResponse(w, http.StatusOK, page.Items, newListMeta(page))
+}
+
+func (s *APIServer) getWorkerLeases(w http.ResponseWriter, r *http.Request) {
+	leases, err := s.service.GetLeases(r.Context())
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn("getWorkerLeases/s.service.GetLeases(r.Context())")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, leases)
+}
+
+func (s *APIServer) deactivateDueReservations(w http.ResponseWriter, r *http.Request) {
+	var request model.DeactivationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	result, err := s.service.ForceDeactivation(r.Context(), request)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidLimit):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidLimit, "invalid limit")
+
+		return
+	case errors.Is(err, model.ErrFutureAsOf):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidAsOf, err.Error())
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("deactivateDueReservations/s.service.ForceDeactivation(r.Context(), request)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, result)
 }
 
 func writeOkResponse(w http.ResponseWriter, statusCode int, data any) {
@@ -150,13 +440,47 @@ func writeOkResponse(w http.ResponseWriter, statusCode int, data any) {
 	}
 }
 
-func writeErrorResponse(w http.ResponseWriter, statusCode int, description string) {
+func writeListResponse(w http.ResponseWriter, statusCode int, data any, meta *ListMeta) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(HTTPResponse{Data: data, Meta: meta})
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn(
+			"writeListResponse/json.NewEncoder(w).Encode(HTTPResponse{Data: data, Meta: meta})")
+	}
+}
+
+func writeErrorResponse(w http.ResponseWriter, statusCode int, code ErrorCode, message string) {
+	writeAPIError(w, statusCode, &APIError{Code: code, Message: message})
+}
+
+// writeErrorDetails writes error together with fields of typed errors in err chain.
+func writeErrorDetails(w http.ResponseWriter, statusCode int, code ErrorCode, message string, err error) {
+	writeAPIError(w, statusCode, &APIError{Code: code, Message: message, Details: errorDetails(err)})
+}
+
+// writeErrorData writes error together with data describing current state, e.g. of conflicting object.
+func writeErrorData(w http.ResponseWriter, statusCode int, code ErrorCode, message string, err error, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	apiError := &APIError{Code: code, Message: message, Details: errorDetails(err)}
+
+	err = json.NewEncoder(w).Encode(HTTPResponse{Data: data, Error: apiError})
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn(
+			"writeErrorData/json.NewEncoder(w).Encode(HTTPResponse{Data: data, Error: apiError})")
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, statusCode int, apiError *APIError) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
-	err := json.NewEncoder(w).Encode(HTTPResponse{Error: description})
+	err := json.NewEncoder(w).Encode(HTTPResponse{Error: apiError})
 	if err != nil {
 		zap.L().With(zap.Error(err)).Warn(
-			"writeErrorResponse/json.NewEncoder(w).Encode(HTTPResponse{Error: data})")
+			"writeAPIError/json.NewEncoder(w).Encode(HTTPResponse{Error: apiError})")
 	}
 }
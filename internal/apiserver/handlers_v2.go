@@ -0,0 +1,837 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const v2ReservationsPath = "/api/v2/reservations/"
+
+var errInvalidQuery = errors.New("err invalid query")
+
+func (s *APIServer) configRouterV2(r chi.Router) {
+	r.Route("/stocks", func(r chi.Router) {
+		r.Use(s.requireScope(model.ScopeStocksRead))
+
+		r.Get("/", s.listStocksV2)
+		r.Get("/overbooked", s.listOverbookedStocksV2)
+		r.Get("/export", s.exportStocksV2)
+		r.Get("/events", s.streamStockEventsV2)
+		r.Get("/{warehouseId}/{productId}", s.getStockV2)
+	})
+
+	r.With(s.requireScope(model.ScopeStocksWrite)).Post("/stocks/{warehouseId}/{productId}/changes", s.changeStockV2)
+
+	r.With(s.requireScope(model.ScopeStocksRead)).Get("/availability", s.getAvailabilityV2)
+
+	r.Route("/reservations", func(r chi.Router) {
+		r.With(s.requireScope(model.ScopeReservationsRead)).Get("/", s.listReservationsV2)
+		r.With(s.requireScope(model.ScopeReservationsWrite)).Post("/", s.createReservationV2)
+		r.With(s.requireScope(model.ScopeReservationsRead)).Get("/{id}", s.getReservationV2)
+		r.With(s.requireScope(model.ScopeReservationsWrite)).Patch("/{id}", s.updateReservationV2)
+		r.With(s.requireScope(model.ScopeReservationsWrite)).Delete("/{id}", s.deleteReservationV2)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.requireScope(model.ScopeAdmin))
+
+		r.Put("/warehouses/{warehouseId}/overbooking", s.setOverbookingV2)
+		r.Put("/products/{productId}/overbooking", s.setOverbookingV2)
+		r.Put("/warehouses/{warehouseId}/region", s.setWarehouseRegionV2)
+
+		r.Get("/workers/leases", s.getWorkerLeases)
+
+		r.Post("/admin/deactivations", s.deactivateDueReservations)
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", s.listWebhooksV2)
+			r.Post("/", s.createWebhookV2)
+			r.Delete("/{id}", s.deleteWebhookV2)
+			r.Get("/{id}/deliveries", s.listWebhookDeliveriesV2)
+			r.Post("/deliveries/{id}/retry", s.retryWebhookDeliveryV2)
+		})
+	})
+}
+
+func (s *APIServer) listStocksV2(w http.ResponseWriter, r *http.Request) {
+	params, err := parseGetParams(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	page, err := s.service.GetStocks(r.Context(), params)
+
+	var errInvalidSortField *model.InvalidSortFieldError
+
+	switch {
+	case errors.As(err, &errInvalidSortField):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidSortField, errInvalidSortField.Error(), err)
+
+		return
+	case errors.Is(err, model.ErrInvalidCursor):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+
+		return
+	case errors.Is(err, model.ErrInvalidSKU):
+		fallthrough
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("listStocksV2/s.service.GetStocks(r.Context(), params)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	etag, lastModified := stocksETag(page)
+	if notModified(w, r, etag, lastModified) {
+		return
+	}
+
+	writeListResponse(w, http.StatusOK, page.Items, withLinks(newListMeta(page), r))
+}
+
+func (s *APIServer) listOverbookedStocksV2(w http.ResponseWriter, r *http.Request) {
+	params, err := parseGetParams(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	page, err := s.service.GetOverbookedStocks(r.Context(), params)
+
+	var errInvalidSortField *model.InvalidSortFieldError
+
+	switch {
+	case errors.As(err, &errInvalidSortField):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidSortField, errInvalidSortField.Error(), err)
+
+		return
+	case errors.Is(err, model.ErrInvalidSKU):
+		fallthrough
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("listOverbookedStocksV2/s.service.GetOverbookedStocks(r.Context(), params)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeListResponse(w, http.StatusOK, page.Items, withLinks(newListMeta(page), r))
+}
+
+func (s *APIServer) getAvailabilityV2(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	params := model.AvailabilityParams{ProductIDs: query["productId"]}
+
+	var err error
+
+	if params.ActiveWarehousesOnly, err = parseBoolQuery(query, "activeWarehousesOnly"); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	switch query.Get("groupBy") {
+	case "", "warehouse":
+	case "region":
+		params.GroupByRegion = true
+	default:
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	s.writeAvailability(w, r, params)
+}
+
+func (s *APIServer) getStockV2(w http.ResponseWriter, r *http.Request) {
+	warehouseID, err := uuid.Parse(chi.URLParam(r, "warehouseId"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	stock, err := s.service.GetStock(r.Context(), warehouseID, chi.URLParam(r, "productId"))
+
+	var errStockNotFound *model.StockNotFoundError
+
+	switch {
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku")
+
+		return
+	case errors.As(err, &errStockNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeStockNotFound, errStockNotFound.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("getStockV2/s.service.GetStock(r.Context(), warehouseID, productID)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	if notModified(w, r, stockETag(*stock), stock.ModifiedAt) {
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, stock)
+}
+
+// changeStockV2 applies change to stock quantity. Expected version is taken from If-Match header,
+// which takes precedence over expectedVersion of body. Version conflict and quantity below reserved one
+// return current stock with error.
+func (s *APIServer) changeStockV2(w http.ResponseWriter, r *http.Request) {
+	warehouseID, err := uuid.Parse(chi.URLParam(r, "warehouseId"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	var change model.StockChange
+
+	if err = json.NewDecoder(r.Body).Decode(&change); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	version, err := expectedVersion(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid If-Match header")
+
+		return
+	}
+
+	// "*" doesn't restrict version even if body does
+	if r.Header.Get("If-Match") != "" {
+		change.ExpectedVersion = version
+	}
+
+	change.WarehouseID, change.ProductID = warehouseID, chi.URLParam(r, "productId")
+
+	stock, err := s.service.ChangeStock(r.Context(), change)
+
+	var (
+		errStockNotFound      *model.StockNotFoundError
+		errVersionConflict    *model.StockVersionConflictError
+		errBelowReserved      *model.StockBelowReservedError
+		errWarehouseForbidden *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidUUID):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku")
+
+		return
+	case errors.Is(err, model.ErrInvalidStockChange):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "invalid stock change kind")
+
+		return
+	case errors.Is(err, model.ErrInvalidQuantity):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidQuantity, "invalid quantity")
+
+		return
+	case errors.Is(err, model.ErrNegativeStock):
+		writeErrorResponse(w, http.StatusConflict, CodeNegativeStock, "stock quantity can't become negative")
+
+		return
+	case errors.As(err, &errStockNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeStockNotFound, errStockNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errVersionConflict):
+		writeErrorData(
+			w, http.StatusConflict, CodeVersionConflict, errVersionConflict.Error(), err, errVersionConflict.Current)
+
+		return
+	case errors.As(err, &errBelowReserved):
+		writeErrorData(w, http.StatusConflict, CodeBelowReserved, errBelowReserved.Error(), err, errBelowReserved.Current)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("changeStockV2/s.service.ChangeStock(r.Context(), change)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, stock)
+}
+
+func (s *APIServer) listReservationsV2(w http.ResponseWriter, r *http.Request) {
+	params, err := parseGetReservationsParams(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	page, err := s.service.GetReservations(r.Context(), params)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku")
+
+		return
+	case errors.Is(err, model.ErrInvalidLimit):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidLimit, "invalid limit")
+
+		return
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("listReservationsV2/s.service.GetReservations(r.Context(), params)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeListResponse(w, http.StatusOK, page.Items, withLinks(newListMeta(page), r))
+}
+
+func (s *APIServer) createReservationV2(w http.ResponseWriter, r *http.Request) {
+	var reservation model.Reservation
+
+	if err := json.NewDecoder(r.Body).Decode(&reservation); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	reservations, err := s.service.CreateReservations(r.Context(), []model.Reservation{reservation})
+
+	var (
+		errDuplicateReservation *model.DuplicateReservationError
+		errStockNotFound        *model.StockNotFoundError
+		errNotEnoughQuantity    *model.NotEnoughQuantityError
+		errWarehouseForbidden   *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku", err)
+
+		return
+	case errors.Is(err, model.ErrInvalidUUID):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid", err)
+
+		return
+	case errors.Is(err, model.ErrInvalidQuantity):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidQuantity, "invalid quantity", err)
+
+		return
+	case errors.Is(err, model.ErrIncorrectDueDate):
+		writeErrorDetails(w, http.StatusBadRequest, CodeInvalidDueDate, "incorrect due date", err)
+
+		return
+	case errors.As(err, &errDuplicateReservation):
+		writeErrorDetails(w, http.StatusConflict, CodeDuplicateReservation, errDuplicateReservation.Error(), err)
+
+		return
+	case errors.As(err, &errNotEnoughQuantity):
+		writeErrorDetails(w, http.StatusUnprocessableEntity, CodeNotEnoughQuantity, errNotEnoughQuantity.Error(), err)
+
+		return
+	case errors.As(err, &errStockNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeStockNotFound, errStockNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("createReservationV2/s.service.CreateReservations(r.Context(), reservations)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	created := (*reservations)[0]
+
+	w.Header().Set("Location", v2ReservationsPath+created.ID.String())
+	writeOkResponse(w, http.StatusCreated, created)
+}
+
+func (s *APIServer) getReservationV2(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	includeArchived, err := parseBoolQuery(r.URL.Query(), "includeArchived")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	reservation, err := s.service.GetReservation(r.Context(), id, includeArchived)
+
+	var errReservationNotFound *model.ReservationNotFoundError
+
+	switch {
+	case errors.As(err, &errReservationNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeReservationNotFound, errReservationNotFound.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("getReservationV2/s.service.GetReservation(r.Context(), id, includeArchived)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, reservation)
+}
+
+func (s *APIServer) updateReservationV2(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	var update model.ReservationUpdate
+
+	if err = json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	reservation, err := s.service.UpdateReservation(r.Context(), id, update)
+
+	var (
+		errReservationNotFound *model.ReservationNotFoundError
+		errWarehouseForbidden  *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrIncorrectDueDate):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidDueDate, "incorrect due date")
+
+		return
+	case errors.As(err, &errReservationNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeReservationNotFound, errReservationNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("updateReservationV2/s.service.UpdateReservation(r.Context(), id, update)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, reservation)
+}
+
+func (s *APIServer) deleteReservationV2(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	err = s.service.DeleteReservations(r.Context(), []model.Reservation{{ID: id}})
+
+	var (
+		errReservationNotFound *model.ReservationNotFoundError
+		errWarehouseForbidden  *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidUUID):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	case errors.As(err, &errReservationNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeReservationNotFound, errReservationNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("deleteReservationV2/s.service.DeleteReservations(r.Context(), reservations)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *APIServer) setOverbookingV2(w http.ResponseWriter, r *http.Request) {
+	var allowance model.OverbookingAllowance
+
+	if err := json.NewDecoder(r.Body).Decode(&allowance); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	allowance.WarehouseID = uuid.Nil
+	allowance.ProductID = chi.URLParam(r, "productId")
+
+	if warehouseID := chi.URLParam(r, "warehouseId"); warehouseID != "" {
+		id, err := uuid.Parse(warehouseID)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+			return
+		}
+
+		allowance.WarehouseID = id
+	}
+
+	err := s.service.SetOverbookingAllowance(r.Context(), allowance)
+
+	var (
+		errWarehouseNotFound  *model.WarehouseNotFoundError
+		errProductNotFound    *model.ProductNotFoundError
+		errWarehouseForbidden *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidSKU):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidSKU, "invalid product sku")
+
+		return
+	case errors.Is(err, model.ErrInvalidOverbooking):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidOverbooking, "invalid overbooking allowance")
+
+		return
+	case errors.As(err, &errWarehouseNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeWarehouseNotFound, errWarehouseNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errProductNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeProductNotFound, errProductNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("setOverbookingV2/s.service.SetOverbookingAllowance(r.Context(), allowance)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *APIServer) setWarehouseRegionV2(w http.ResponseWriter, r *http.Request) {
+	var region model.WarehouseRegion
+
+	if err := json.NewDecoder(r.Body).Decode(&region); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	warehouseID, err := uuid.Parse(chi.URLParam(r, "warehouseId"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	region.WarehouseID = warehouseID
+
+	err = s.service.SetWarehouseRegion(r.Context(), region)
+
+	var (
+		errWarehouseNotFound  *model.WarehouseNotFoundError
+		errWarehouseForbidden *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidUUID):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	case errors.Is(err, model.ErrInvalidRegion):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidRegion, "invalid warehouse region")
+
+		return
+	case errors.As(err, &errWarehouseNotFound):
+		writeErrorDetails(w, http.StatusNotFound, CodeWarehouseNotFound, errWarehouseNotFound.Error(), err)
+
+		return
+	case errors.As(err, &errWarehouseForbidden):
+		writeErrorDetails(w, http.StatusForbidden, CodeForbidden, errWarehouseForbidden.Error(), err)
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("setWarehouseRegionV2/s.service.SetWarehouseRegion(r.Context(), region)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withLinks adds to meta links to neighbour pages of list requested by r. Cursor pagination
+// goes only forward, so there is no previous page link for it.
+func withLinks(meta *ListMeta, r *http.Request) *ListMeta {
+	links := &ListLinks{}
+	query := r.URL.Query()
+
+	if meta.HasMore {
+		next := cloneQuery(query)
+
+		if meta.NextCursor != "" {
+			next.Set("cursor", meta.NextCursor)
+			next.Del("offset")
+		} else {
+			next.Set("offset", strconv.FormatUint(uint64(meta.Offset+meta.Limit), 10))
+		}
+
+		links.Next = r.URL.Path + "?" + next.Encode()
+	}
+
+	if query.Get("cursor") == "" && meta.Offset > 0 {
+		prev := cloneQuery(query)
+
+		if meta.Offset > meta.Limit {
+			prev.Set("offset", strconv.FormatUint(uint64(meta.Offset-meta.Limit), 10))
+		} else {
+			prev.Del("offset")
+		}
+
+		links.Prev = r.URL.Path + "?" + prev.Encode()
+	}
+
+	if *links != (ListLinks{}) {
+		meta.Links = links
+	}
+
+	return meta
+}
+
+func cloneQuery(query url.Values) url.Values {
+	result := make(url.Values, len(query))
+
+	for key, values := range query {
+		result[key] = append([]string(nil), values...)
+	}
+
+	return result
+}
+
+func parseGetParams(query url.Values) (model.GetParams, error) {
+	var (
+		params model.GetParams
+		err    error
+	)
+
+	if params.Offset, err = parseUintQuery(query, "offset"); err != nil {
+		return params, err
+	}
+
+	if params.Limit, err = parseUintQuery(query, "limit"); err != nil {
+		return params, err
+	}
+
+	if params.Descending, err = parseBoolQuery(query, "descending"); err != nil {
+		return params, err
+	}
+
+	if params.InStockOnly, err = parseBoolQuery(query, "inStockOnly"); err != nil {
+		return params, err
+	}
+
+	ranges := map[string]**uint{
+		"minQuantity":          &params.MinQuantity,
+		"maxQuantity":          &params.MaxQuantity,
+		"minReservedQuantity":  &params.MinReservedQuantity,
+		"maxReservedQuantity":  &params.MaxReservedQuantity,
+		"minAvailableQuantity": &params.MinAvailableQuantity,
+		"maxAvailableQuantity": &params.MaxAvailableQuantity,
+	}
+
+	for key, dest := range ranges {
+		if *dest, err = parseOptionalUintQuery(query, key); err != nil {
+			return params, err
+		}
+	}
+
+	if value := query.Get("modifiedSince"); value != "" {
+		modifiedSince, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return params, errInvalidQuery
+		}
+
+		params.ModifiedSince = &modifiedSince
+	}
+
+	// single warehouse and product are passed as plain filters, so they work for every listing
+	if warehouseIDs := query["warehouseId"]; len(warehouseIDs) > 1 {
+		for _, value := range warehouseIDs {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return params, errInvalidQuery
+			}
+
+			params.WarehouseIDs = append(params.WarehouseIDs, id)
+		}
+	} else {
+		params.WarehouseFilter = query.Get("warehouseId")
+	}
+
+	if productIDs := query["productId"]; len(productIDs) > 1 {
+		params.ProductIDs = productIDs
+	} else {
+		params.ProductFilter = query.Get("productId")
+	}
+
+	params.Sorting = query.Get("sort")
+	params.Cursor = query.Get("cursor")
+	params.Total = model.TotalMode(query.Get("total"))
+
+	return params, nil
+}
+
+func parseGetReservationsParams(query url.Values) (model.GetReservationsParams, error) {
+	var (
+		params model.GetReservationsParams
+		err    error
+	)
+
+	if params.Offset, err = parseUintQuery(query, "offset"); err != nil {
+		return params, err
+	}
+
+	if params.Limit, err = parseUintQuery(query, "limit"); err != nil {
+		return params, err
+	}
+
+	if params.ActiveOnly, err = parseBoolQuery(query, "activeOnly"); err != nil {
+		return params, err
+	}
+
+	if params.IncludeArchived, err = parseBoolQuery(query, "includeArchived"); err != nil {
+		return params, err
+	}
+
+	for _, value := range query["id"] {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return params, errInvalidQuery
+		}
+
+		params.IDs = append(params.IDs, id)
+	}
+
+	if value := query.Get("warehouseId"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return params, errInvalidQuery
+		}
+
+		params.WarehouseID = &id
+	}
+
+	params.ProductID = query.Get("productId")
+	params.Total = model.TotalMode(query.Get("total"))
+
+	return params, nil
+}
+
+func parseUintQuery(query url.Values, key string) (uint, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	result, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return 0, errInvalidQuery
+	}
+
+	return uint(result), nil
+}
+
+func parseOptionalUintQuery(query url.Values, key string) (*uint, error) {
+	if query.Get(key) == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	result, err := parseUintQuery(query, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func parseBoolQuery(query url.Values, key string) (bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return false, nil
+	}
+
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errInvalidQuery
+	}
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+// readinessTimeout limits checks of readiness, so probe gets an answer before it times out itself.
+const readinessTimeout = 2 * time.Second
+
+var errShuttingDown = errors.New("err server is shutting down")
+
+// healthz reports that process is alive. It keeps answering during shutdown, so the instance isn't restarted
+// while it finishes requests.
+func (s *APIServer) healthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealthResponse(w, http.StatusOK, model.Readiness{Status: model.HealthOK})
+}
+
+// readyz reports if instance can serve requests. It fails as soon as shutdown starts.
+func (s *APIServer) readyz(w http.ResponseWriter, r *http.Request) {
+	if s.shuttingDown.Load() {
+		var readiness model.Readiness
+
+		readiness.AddCheck("server", errShuttingDown)
+
+		writeHealthResponse(w, http.StatusServiceUnavailable, readiness)
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	readiness := s.service.CheckReadiness(ctx)
+
+	if readiness.Status != model.HealthOK {
+		zap.L().Warn("readyz/s.service.CheckReadiness(ctx)", zap.Any("checks", readiness.Checks))
+
+		writeHealthResponse(w, http.StatusServiceUnavailable, readiness)
+
+		return
+	}
+
+	writeHealthResponse(w, http.StatusOK, readiness)
+}
+
+// writeHealthResponse writes readiness as is, without envelope of api responses, as probes expect.
+func writeHealthResponse(w http.ResponseWriter, statusCode int, readiness model.Readiness) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(readiness)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn("writeHealthResponse/json.NewEncoder(w).Encode(readiness)")
+	}
+}
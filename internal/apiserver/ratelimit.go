@@ -0,0 +1,109 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// streamingRoutes are routes of long-lived streams. They are limited by rate only, otherwise every open stream
+// would hold a slot of concurrent requests of client.
+var streamingRoutes = map[string]struct{}{
+	"GET /api/v2/stocks/events": {},
+}
+
+type rateLimiter interface {
+	Acquire(ctx context.Context, client, route string) (release func(), retryAfter time.Duration, err error)
+	Admit(ctx context.Context, client, route string) (retryAfter time.Duration, err error)
+}
+
+// SetRateLimiter enables limiting requests of every client to every route. Without limiter requests aren't limited.
+func (s *APIServer) SetRateLimiter(limiter rateLimiter) {
+	s.limiter = limiter
+}
+
+// rateLimit rejects requests of clients which are over limit of route with 429 error and Retry-After header.
+// Route is named by method and pattern, e.g. "POST /api/v1/createReservations". Unknown routes aren't limited.
+func (s *APIServer) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		routeContext := chi.NewRouteContext()
+		if !s.router.Match(routeContext, r.Method, r.URL.Path) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		route := r.Method + " " + routeContext.RoutePattern()
+
+		release, retryAfter, err := s.acquire(r, route)
+		if err != nil {
+			// limiter failure shouldn't make api unavailable
+			zap.L().With(zap.Error(err)).Warn("rateLimit/s.acquire(r, route)")
+
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if release == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeErrorResponse(w, http.StatusTooManyRequests, CodeRateLimited, "too many requests, retry later")
+
+			return
+		}
+
+		defer release()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *APIServer) acquire(r *http.Request, route string) (func(), time.Duration, error) {
+	if _, ok := streamingRoutes[route]; !ok {
+		release, retryAfter, err := s.limiter.Acquire(r.Context(), clientOfRequest(r), route)
+		if err != nil {
+			return nil, 0, fmt.Errorf("s.limiter.Acquire(r.Context(), client, route): %w", err)
+		}
+
+		return release, retryAfter, nil
+	}
+
+	retryAfter, err := s.limiter.Admit(r.Context(), clientOfRequest(r), route)
+	if err != nil {
+		return nil, 0, fmt.Errorf("s.limiter.Admit(r.Context(), client, route): %w", err)
+	}
+
+	if retryAfter > 0 {
+		return nil, retryAfter, nil
+	}
+
+	return func() {}, 0, nil
+}
+
+// clientOfRequest identifies authenticated client by its id and anonymous one by its address.
+func clientOfRequest(r *http.Request) string {
+	if client, ok := model.ClientFromContext(r.Context()); ok {
+		return client.ID
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,288 @@
+package apiserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Saaghh/lamoda-hr/api"
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+//nolint:gochecknoinits
+func init() {
+	// uuid format isn't checked by default. Values are checked the same way handlers parse them.
+	openapi3.DefineStringFormatCallback("uuid", func(value string) error {
+		if _, err := uuid.Parse(value); err != nil {
+			return fmt.Errorf("uuid.Parse(%s): %w", value, err)
+		}
+
+		return nil
+	})
+}
+
+// fieldErrorCodes are codes of invalid fields, which handlers report for the same fields.
+// Field is a parameter name or the last property name of json pointer.
+var fieldErrorCodes = map[string]ErrorCode{
+	"id":                CodeInvalidUUID,
+	"ids":               CodeInvalidUUID,
+	"warehouseId":       CodeInvalidUUID,
+	"warehouseIds":      CodeInvalidUUID,
+	"warehouseFilter":   CodeInvalidUUID,
+	"reservationId":     CodeInvalidUUID,
+	"productId":         CodeInvalidSKU,
+	"productIds":        CodeInvalidSKU,
+	"productFilter":     CodeInvalidSKU,
+	"quantity":          CodeInvalidQuantity,
+	"dueDate":           CodeInvalidDueDate,
+	"limit":             CodeInvalidLimit,
+	"cursor":            CodeInvalidCursor,
+	"sorting":           CodeInvalidSortField,
+	"sort":              CodeInvalidSortField,
+	"percent":           CodeInvalidOverbooking,
+	"region":            CodeInvalidRegion,
+	"asOf":              CodeInvalidAsOf,
+	"url":               CodeInvalidWebhook,
+	"eventTypes":        CodeInvalidWebhook,
+	"lowStockThreshold": CodeInvalidWebhook,
+}
+
+// validator checks requests, and optionally responses, against OpenAPI specification of api version.
+// Requests to paths or methods missing in specification are rejected, so every route has to be described.
+type validator struct {
+	router routers.Router
+	// prefix is a path which is mounted at the root of specification paths
+	prefix            string
+	validateResponses bool
+}
+
+func newValidator(specFile string, prefix string, validateResponses bool) (*validator, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(_ *openapi3.Loader, location *url.URL) ([]byte, error) {
+		return api.Specs.ReadFile(path.Clean(location.Path))
+	}
+
+	doc, err := loader.LoadFromFile(specFile)
+	if err != nil {
+		return nil, fmt.Errorf("loader.LoadFromFile(%s): %w", specFile, err)
+	}
+
+	// servers of specification point at local instance, prefix is trimmed from request path instead
+	doc.Servers = nil
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("legacy.NewRouter(doc): %w", err)
+	}
+
+	return &validator{
+		router:            router,
+		prefix:            prefix,
+		validateResponses: validateResponses,
+	}, nil
+}
+
+func (v *validator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := v.findRoute(r)
+
+		// router returns new errors, which are told apart by reason only
+		var errRoute *routers.RouteError
+
+		switch {
+		case errors.As(err, &errRoute) && errRoute.Reason == routers.ErrMethodNotAllowed.Error():
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		case err != nil:
+			http.NotFound(w, r)
+
+			return
+		}
+
+		// handlers decode body as json regardless of content type
+		if r.Header.Get("Content-Type") == "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				SkipSettingDefaults: true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+
+		if err = openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			writeAPIError(w, http.StatusBadRequest, validationError(err))
+
+			return
+		}
+
+		// streamed responses can't be buffered for validation, they are marked with x-streaming extension
+		if !v.validateResponses || route.Operation.Extensions["x-streaming"] == true {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.status,
+			Header:                 recorder.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+			Options:                input.Options,
+		})
+		if err != nil {
+			zap.L().With(zap.Error(err)).Error("validator/openapi3filter.ValidateResponse(...)")
+
+			writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "response doesn't match specification")
+
+			return
+		}
+
+		w.WriteHeader(recorder.status)
+
+		if _, err = w.Write(recorder.body.Bytes()); err != nil {
+			zap.L().With(zap.Error(err)).Warn("validator/w.Write(recorder.body.Bytes())")
+		}
+	})
+}
+
+// findRoute looks for operation of request in specification. Trailing slash is trimmed,
+// because router treats "/stocks/" and "/stocks" the same way.
+func (v *validator) findRoute(r *http.Request) (*routers.Route, map[string]string, error) {
+	routed := *r
+	routedURL := *r.URL
+	routedURL.Path = "/" + strings.Trim(strings.TrimPrefix(r.URL.Path, v.prefix), "/")
+	routedURL.RawPath = ""
+	routed.URL = &routedURL
+
+	route, pathParams, err := v.router.FindRoute(&routed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("v.router.FindRoute(%s): %w", routedURL.Path, err)
+	}
+
+	return route, pathParams, nil
+}
+
+// validationError describes the first mismatch of request. Field is a json pointer for body
+// and a parameter name for query and path parameters. Code is the one handlers report for the field,
+// INVALID_PARAMS or INVALID_BODY if field has no specific code.
+func validationError(err error) *APIError {
+	var (
+		errRequest *openapi3filter.RequestError
+		errSchema  *openapi3.SchemaError
+	)
+
+	apiError := &APIError{Code: CodeInvalidBody, Message: "invalid request", Details: &ErrorDetails{}}
+
+	if !errors.As(err, &errRequest) {
+		apiError.Message = err.Error()
+
+		return apiError
+	}
+
+	if errRequest.Parameter != nil {
+		apiError.Code = CodeInvalidParams
+		apiError.Details.Field = errRequest.Parameter.Name
+
+		if code, ok := fieldErrorCodes[errRequest.Parameter.Name]; ok {
+			apiError.Code = code
+		}
+	}
+
+	if !errors.As(err, &errSchema) {
+		apiError.Message = errRequest.Reason
+		if errRequest.Err != nil {
+			apiError.Message = errRequest.Err.Error()
+		}
+
+		return apiError
+	}
+
+	apiError.Message = errSchema.Reason
+
+	if errRequest.Parameter == nil {
+		pointer := errSchema.JSONPointer()
+
+		// unsupported and missing properties are reported at the object, which contains them
+		var property string
+		if _, scanErr := fmt.Sscanf(errSchema.Reason, "property %q is unsupported", &property); scanErr == nil {
+			pointer = append(pointer, property)
+		} else if _, scanErr = fmt.Sscanf(errSchema.Reason, "property %q is missing", &property); scanErr == nil {
+			pointer = append(pointer, property)
+		}
+
+		apiError.Details.Field = "/" + strings.Join(pointer, "/")
+
+		if code, ok := fieldErrorCodes[pointerField(pointer)]; ok {
+			apiError.Code = code
+		}
+	}
+
+	if errSchema.SchemaField == "enum" {
+		for _, value := range errSchema.Schema.Enum {
+			apiError.Details.AllowedValues = append(apiError.Details.AllowedValues, fmt.Sprint(value))
+		}
+
+		if apiError.Code == CodeInvalidSortField {
+			apiError.Message = model.InvalidSortFieldError{
+				Field:   fmt.Sprint(errSchema.Value),
+				Allowed: apiError.Details.AllowedValues,
+			}.Error()
+		}
+	}
+
+	return apiError
+}
+
+// pointerField returns the last property name of json pointer, items of arrays are skipped.
+func pointerField(pointer []string) string {
+	for i := len(pointer) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(pointer[i]); err != nil {
+			return pointer[i]
+		}
+	}
+
+	return ""
+}
+
+// responseRecorder keeps response until it is validated. Headers are written to underlying writer.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	n, err := r.body.Write(data)
+	if err != nil {
+		return n, fmt.Errorf("r.body.Write(data): %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,134 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+var routeParamRegexp = regexp.MustCompile(`\{[^}]+\}`)
+
+// TestSpecCoversRoutes checks that every api route is described in specification, because validator
+// rejects requests to routes which are missing in it.
+func TestSpecCoversRoutes(t *testing.T) {
+	server := New(Config{}, nil)
+
+	require.NoError(t, server.configRouter())
+
+	validators := make(map[string]*validator)
+
+	for file, prefix := range map[string]string{"openapi.yaml": "/api/v1", "openapi.v2.yaml": "/api/v2"} {
+		v, err := newValidator(file, prefix, false)
+		require.NoError(t, err)
+
+		validators[prefix] = v
+	}
+
+	routes := 0
+
+	err := chi.Walk(server.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, "/api/") {
+			return nil
+		}
+
+		routes++
+
+		v, ok := validators[route[:len("/api/v1")]]
+		require.True(t, ok, route)
+
+		path := routeParamRegexp.ReplaceAllString(route, "param")
+
+		_, _, err := v.findRoute(httptest.NewRequest(method, path, nil))
+		require.NoError(t, err, "%s %s", method, route)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.NotZero(t, routes)
+}
+
+func TestValidationErrorCodes(t *testing.T) {
+	v, err := newValidator("openapi.yaml", "/api/v1", false)
+	require.NoError(t, err)
+
+	// requests which pass validation get status, which isn't expected by any case
+	handler := v.middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   ErrorCode
+	}{
+		{
+			name:   "sort field",
+			method: http.MethodPost,
+			path:   "/api/v1/getStocks",
+			body:   `{"sorting": "unknown"}`,
+			status: http.StatusBadRequest,
+			code:   CodeInvalidSortField,
+		},
+		{
+			name:   "quantity of item",
+			method: http.MethodPost,
+			path:   "/api/v1/createReservations",
+			body: `[{"id": "a4522a50-155a-4044-a435-63f6972f634f", "warehouseId": "a4522a50-155a-4044-a435-63f6972f634f",
+				"productId": "ABCDEF123456", "quantity": "1", "dueDate": "2030-01-01T00:00:00Z"}]`,
+			status: http.StatusBadRequest,
+			code:   CodeInvalidQuantity,
+		},
+		{
+			name:   "missing uuid",
+			method: http.MethodPost,
+			path:   "/api/v1/deleteReservations",
+			body:   `[{}]`,
+			status: http.StatusBadRequest,
+			code:   CodeInvalidUUID,
+		},
+		{
+			name:   "unknown property",
+			method: http.MethodPost,
+			path:   "/api/v1/getStocks",
+			body:   `{"unknown": 1}`,
+			status: http.StatusBadRequest,
+			code:   CodeInvalidBody,
+		},
+		{
+			name:   "unknown path",
+			method: http.MethodPost,
+			path:   "/api/v1/unknown",
+			body:   `{}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "unknown method",
+			method: http.MethodGet,
+			path:   "/api/v1/getStocks",
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.status, recorder.Code)
+
+			if tt.code != "" {
+				require.Contains(t, recorder.Body.String(), `"code":"`+string(tt.code)+`"`)
+			}
+		})
+	}
+}
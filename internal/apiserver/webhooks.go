@@ -0,0 +1,156 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// createWebhookV2 returns the created subscription with its secret. Secret isn't returned by other requests.
+func (s *APIServer) createWebhookV2(w http.ResponseWriter, r *http.Request) {
+	var subscription model.WebhookSubscription
+
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidBody, "error reading body")
+
+		return
+	}
+
+	created, err := s.service.CreateWebhook(r.Context(), subscription)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidWebhook):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidWebhook, "invalid webhook url or event types")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("createWebhookV2/s.service.CreateWebhook(r.Context(), subscription)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusCreated, created)
+}
+
+func (s *APIServer) listWebhooksV2(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := s.service.GetWebhooks(r.Context())
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn("listWebhooksV2/s.service.GetWebhooks(r.Context())")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, subscriptions)
+}
+
+func (s *APIServer) deleteWebhookV2(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	err = s.service.DeleteWebhook(r.Context(), id)
+
+	switch {
+	case errors.Is(err, model.ErrWebhookNotFound):
+		writeErrorResponse(w, http.StatusNotFound, CodeWebhookNotFound, "webhook not found")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("deleteWebhookV2/s.service.DeleteWebhook(r.Context(), id)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveriesV2 returns delivery log of subscription, the latest deliveries first.
+func (s *APIServer) listWebhookDeliveriesV2(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	query := r.URL.Query()
+
+	params := model.GetWebhookDeliveriesParams{
+		SubscriptionID: id,
+		Status:         model.WebhookDeliveryStatus(query.Get("status")),
+	}
+
+	if params.Offset, err = parseUintQuery(query, "offset"); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	if params.Limit, err = parseUintQuery(query, "limit"); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	}
+
+	page, err := s.service.GetWebhookDeliveries(r.Context(), params)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidLimit):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidLimit, "invalid limit")
+
+		return
+	case errors.Is(err, model.ErrInvalidGetParams):
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidParams, "invalid get params")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("listWebhookDeliveriesV2/s.service.GetWebhookDeliveries(r.Context(), params)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeListResponse(w, http.StatusOK, page.Items, withLinks(newListMeta(page), r))
+}
+
+// retryWebhookDeliveryV2 sends dead delivery again. Deliveries which aren't dead can't be retried.
+func (s *APIServer) retryWebhookDeliveryV2(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, CodeInvalidUUID, "invalid uuid")
+
+		return
+	}
+
+	delivery, err := s.service.RetryWebhookDelivery(r.Context(), id)
+
+	switch {
+	case errors.Is(err, model.ErrDeliveryNotFound):
+		writeErrorResponse(w, http.StatusNotFound, CodeDeliveryNotFound, "dead delivery not found")
+
+		return
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("retryWebhookDeliveryV2/s.service.RetryWebhookDelivery(r.Context(), id)")
+
+		writeErrorResponse(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+
+		return
+	}
+
+	writeOkResponse(w, http.StatusOK, delivery)
+}
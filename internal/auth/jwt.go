@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoKeys     = errors.New("err neither jwt secret nor jwks file is configured")
+	ErrUnknownKey = errors.New("err unknown signing key")
+	// ErrUnsupportedKey is returned for keys other than RSA and P-256 EC ones
+	ErrUnsupportedKey = errors.New("err unsupported key")
+)
+
+type JWTConfig struct {
+	// Secret verifies HS256 tokens
+	Secret string
+	// JWKSFile is a path to json web key set, which verifies RS256 and ES256 tokens
+	JWKSFile string
+	// Issuer and Audience are checked only if they are set
+	Issuer   string
+	Audience string
+}
+
+// claims are expected in tokens in addition to registered ones.
+// Roles map to scopes, warehouses limit warehouses token holder may act on.
+type claims struct {
+	jwt.RegisteredClaims
+	Name       string   `json:"name,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	Warehouses []string `json:"warehouses,omitempty"`
+}
+
+// JWTVerifier checks signed bearer tokens and resolves their holders.
+type JWTVerifier struct {
+	parser *jwt.Parser
+	secret []byte
+	// keys are public keys of jwks by key id
+	keys map[string]any
+}
+
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	verifier := &JWTVerifier{keys: make(map[string]any)}
+
+	methods := make([]string, 0)
+
+	if cfg.Secret != "" {
+		verifier.secret = []byte(cfg.Secret)
+
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := readJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("readJWKS(%s): %w", cfg.JWKSFile, err)
+		}
+
+		verifier.keys = keys
+
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+
+	if len(methods) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	verifier.parser = jwt.NewParser(options...)
+
+	return verifier, nil
+}
+
+func (v *JWTVerifier) Verify(token string) (*model.Client, error) {
+	var tokenClaims claims
+
+	if _, err := v.parser.ParseWithClaims(token, &tokenClaims, v.key); err != nil {
+		return nil, fmt.Errorf("v.parser.ParseWithClaims(token, &tokenClaims, v.key): %w", err)
+	}
+
+	client := &model.Client{
+		ID:     tokenClaims.Subject,
+		Name:   tokenClaims.Name,
+		Scopes: model.ScopesOfRoles(tokenClaims.Roles),
+	}
+
+	if client.Name == "" {
+		client.Name = tokenClaims.Subject
+	}
+
+	// tokens without warehouses claim may act on every warehouse
+	if tokenClaims.Warehouses != nil {
+		client.Warehouses = make([]uuid.UUID, 0, len(tokenClaims.Warehouses))
+
+		for _, value := range tokenClaims.Warehouses {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("uuid.Parse(%s): %w", value, err)
+			}
+
+			client.Warehouses = append(client.Warehouses, id)
+		}
+	}
+
+	return client, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("kid %q: %w", kid, ErrUnknownKey)
+	}
+
+	return key, nil
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// readJWKS reads public keys of json web key set. Key without kid is used for tokens without kid.
+func readJWKS(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(%s): %w", path, err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(data, &set): %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+
+	for _, value := range set.Keys {
+		key, err := value.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("value.publicKey(%s): %w", value.Kid, err)
+		}
+
+		keys[value.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decodeBigInt(n): %w", err)
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decodeBigInt(e): %w", err)
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("curve %q: %w", k.Crv, ErrUnsupportedKey)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decodeBigInt(x): %w", err)
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decodeBigInt(y): %w", err)
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("key type %q: %w", k.Kty, ErrUnsupportedKey)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("base64.RawURLEncoding.DecodeString(value): %w", err)
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -1,12 +1,32 @@
 package config
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type Config struct {
-	BindAddress string `env:"BIND_ADDR" env-default:":8080"`
-	LogLevel    string `env:"LOG_LEVEL" env-default:"debug"`
+	BindAddress     string `env:"BIND_ADDR" env-default:":8080"`
+	GRPCBindAddress string `env:"GRPC_BIND_ADDR" env-default:":9090"`
+	LogLevel        string `env:"LOG_LEVEL" env-default:"debug"`
+	InstanceID      string `env:"INSTANCE_ID"`
+	AuthEnabled     bool   `env:"AUTH_ENABLED" env-default:"false"`
+	// ShutdownDelay is the time /readyz reports 503 before server stops accepting requests
+	ShutdownDelay string `env:"SHUTDOWN_DELAY" env-default:"3s"`
+
+	JWTSecret   string `env:"JWT_SECRET"`
+	JWTJWKSFile string `env:"JWT_JWKS_FILE"`
+	JWTIssuer   string `env:"JWT_ISSUER"`
+	JWTAudience string `env:"JWT_AUDIENCE"`
+
+	RateLimitBackend string `env:"RATE_LIMIT_BACKEND" env-default:"none"`
+	RateLimit        string `env:"RATE_LIMIT" env-default:"50/100"`
+	//nolint:lll
+	RateLimitRoutes        map[string]string `env:"RATE_LIMIT_ROUTES" env-separator:";" env-default:"POST /api/v1/createReservations:10/20;POST /api/v2/reservations:10/20;/reservations.v1.ReservationService/CreateReservations:10/20"`
+	RateLimitMaxConcurrent uint              `env:"RATE_LIMIT_MAX_CONCURRENT" env-default:"20"`
 
 	PGHost     string `env:"PG_HOST" env-default:"localhost"`
 	PGPort     string `env:"PG_PORT" env-default:"5432"`
@@ -14,7 +34,29 @@ type Config struct {
 	PGUser     string `env:"PG_USER" env-default:"user"`
 	PGPassword string `env:"PG_PASSWORD" env-default:"secret"`
 
-	DeactivatorPeriod string `env:"DEACTIVATOR_PERIOD" env-default:"5m"`
+	DeactivatorPeriod    string `env:"DEACTIVATOR_PERIOD" env-default:"5m"`
+	DeactivatorBatchSize uint   `env:"DEACTIVATOR_BATCH_SIZE" env-default:"1000"`
+	DeactivatorLeaseTTL  string `env:"DEACTIVATOR_LEASE_TTL"`
+
+	ArchiverPeriod       string `env:"ARCHIVER_PERIOD" env-default:"1h"`
+	ArchiverRetentionAge string `env:"ARCHIVER_RETENTION_AGE" env-default:"720h"`
+	ArchiverBatchSize    uint   `env:"ARCHIVER_BATCH_SIZE" env-default:"1000"`
+
+	StockEventsRetentionAge string `env:"STOCK_EVENTS_RETENTION_AGE" env-default:"24h"`
+
+	WebhooksPeriod      string `env:"WEBHOOKS_PERIOD" env-default:"5s"`
+	WebhooksMaxAttempts uint   `env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"8"`
+	WebhooksBackoff     string `env:"WEBHOOKS_BACKOFF" env-default:"10s"`
+	WebhooksMaxBackoff  string `env:"WEBHOOKS_MAX_BACKOFF" env-default:"1h"`
+	WebhooksTimeout     string `env:"WEBHOOKS_TIMEOUT" env-default:"5s"`
+	// WebhooksAllowPrivateHosts lets webhooks reach loopback, private and link-local addresses
+	WebhooksAllowPrivateHosts      bool   `env:"WEBHOOKS_ALLOW_PRIVATE_HOSTS" env-default:"false"`
+	WebhooksDeliveriesRetentionAge string `env:"WEBHOOKS_DELIVERIES_RETENTION_AGE" env-default:"720h"`
+
+	NotifierSink       string `env:"NOTIFIER_SINK" env-default:"log"`
+	NotifierPeriod     string `env:"NOTIFIER_PERIOD" env-default:"1m"`
+	NotifierLeadTime   string `env:"NOTIFIER_LEAD_TIME" env-default:"10m"`
+	NotifierWebhookURL string `env:"NOTIFIER_WEBHOOK_URL"`
 }
 
 func New() *Config {
@@ -25,5 +67,19 @@ func New() *Config {
 		panic("error getting config")
 	}
 
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = defaultInstanceID()
+	}
+
 	return &cfg
 }
+
+// defaultInstanceID is unique for every process, so restarted instance doesn't inherit leases of previous one.
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "apiserver"
+	}
+
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8])
+}
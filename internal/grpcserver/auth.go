@@ -0,0 +1,74 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	reservationsv1 "github.com/Saaghh/lamoda-hr/api/proto/reservations/v1"
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const apiKeyMetadata = "x-api-key"
+
+// methodScopes lists scopes required by methods. Methods missing here are rejected when authentication is enabled.
+var methodScopes = map[string]model.Scope{
+	reservationsv1.ReservationService_CreateReservations_FullMethodName: model.ScopeReservationsWrite,
+	reservationsv1.ReservationService_DeleteReservations_FullMethodName: model.ScopeReservationsWrite,
+	reservationsv1.ReservationService_GetStocks_FullMethodName:          model.ScopeStocksRead,
+}
+
+// authenticate puts client, which owns api key or bearer token of call, into call context and checks its scopes.
+// Api key is taken from x-api-key metadata, api key or token - from bearer authorization metadata.
+func (s *GRPCServer) authenticate(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if !s.cfg.AuthEnabled {
+		return handler(ctx, req)
+	}
+
+	client, err := s.service.Authenticate(ctx, credentialsFromMetadata(ctx))
+
+	switch {
+	case errors.Is(err, model.ErrUnauthorized):
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid credentials")
+	case err != nil:
+		zap.L().With(zap.Error(err)).Warn("authenticate/s.service.Authenticate(ctx, key)")
+
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	scope, ok := methodScopes[info.FullMethod]
+	if !ok || !client.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, "client has no "+string(scope)+" scope")
+	}
+
+	return handler(model.ContextWithClient(ctx, client), req)
+}
+
+func credentialsFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(apiKeyMetadata); len(values) > 0 {
+		return values[0]
+	}
+
+	for _, value := range md.Get("authorization") {
+		if key, found := strings.CutPrefix(value, "Bearer "); found {
+			return strings.TrimSpace(key)
+		}
+	}
+
+	return ""
+}
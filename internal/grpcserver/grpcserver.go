@@ -0,0 +1,96 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	reservationsv1 "github.com/Saaghh/lamoda-hr/api/proto/reservations/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// gracefulStopTimeout limits waiting for pending rpcs on shutdown, after it connections are closed.
+const gracefulStopTimeout = 10 * time.Second
+
+type GRPCServer struct {
+	reservationsv1.UnimplementedReservationServiceServer
+
+	cfg     Config
+	server  *grpc.Server
+	service service
+	limiter rateLimiter
+}
+
+type Config struct {
+	BindAddress string
+	// AuthEnabled makes server require api key with scope of called method
+	AuthEnabled bool
+}
+
+func New(cfg Config, service service) *GRPCServer {
+	s := &GRPCServer{
+		cfg:     cfg,
+		service: service,
+	}
+
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(s.authenticate, s.rateLimit))
+
+	reservationsv1.RegisterReservationServiceServer(s.server, s)
+
+	return s
+}
+
+func (s *GRPCServer) Run(ctx context.Context) error {
+	defer zap.L().Info("grpc server stopped")
+
+	listener, err := net.Listen("tcp", s.cfg.BindAddress)
+	if err != nil {
+		return fmt.Errorf("net.Listen(tcp, s.cfg.BindAddress): %w", err)
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		<-ctx.Done()
+
+		s.stop()
+	}()
+
+	zap.L().Info("grpc server starting", zap.String("port", s.cfg.BindAddress))
+
+	if err = s.server.Serve(listener); err != nil {
+		return fmt.Errorf("s.server.Serve(listener): %w", err)
+	}
+
+	// serve returns as soon as stop starts, while pending rpcs are still running
+	<-stopped
+
+	return nil
+}
+
+// stop waits for pending rpcs, but not longer than gracefulStopTimeout, so a hanging rpc doesn't block shutdown.
+func (s *GRPCServer) stop() {
+	zap.L().Debug("attempting graceful shutdown of grpc server")
+
+	stopped := make(chan struct{})
+
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(gracefulStopTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+	case <-timer.C:
+		zap.L().Warn("grpc server didn't stop gracefully in time, closing connections")
+
+		s.server.Stop()
+	}
+}
@@ -0,0 +1,310 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	reservationsv1 "github.com/Saaghh/lamoda-hr/api/proto/reservations/v1"
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const errorDomain = "reservations.lamoda-hr"
+
+type service interface {
+	CreateReservations(ctx context.Context, reservations []model.Reservation) (*[]model.Reservation, error)
+	DeleteReservations(ctx context.Context, reservations []model.Reservation) error
+
+	GetStocks(ctx context.Context, params model.GetParams) (*model.Page[model.Stock], error)
+
+	Authenticate(ctx context.Context, secret string) (*model.Client, error)
+}
+
+func (s *GRPCServer) CreateReservations(
+	ctx context.Context,
+	req *reservationsv1.CreateReservationsRequest,
+) (*reservationsv1.CreateReservationsResponse, error) {
+	reservations := make([]model.Reservation, 0, len(req.GetReservations()))
+
+	for _, value := range req.GetReservations() {
+		reservation, err := reservationFromProto(value)
+		if err != nil {
+			return nil, toStatus("CreateReservations", err)
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	created, err := s.service.CreateReservations(ctx, reservations)
+	if err != nil {
+		return nil, toStatus("CreateReservations", err)
+	}
+
+	response := &reservationsv1.CreateReservationsResponse{
+		Reservations: make([]*reservationsv1.Reservation, 0, len(*created)),
+	}
+
+	for _, value := range *created {
+		response.Reservations = append(response.Reservations, reservationToProto(value))
+	}
+
+	return response, nil
+}
+
+func (s *GRPCServer) DeleteReservations(
+	ctx context.Context,
+	req *reservationsv1.DeleteReservationsRequest,
+) (*reservationsv1.DeleteReservationsResponse, error) {
+	reservations := make([]model.Reservation, 0, len(req.GetIds()))
+
+	for _, value := range req.GetIds() {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, toStatus("DeleteReservations", model.ErrInvalidUUID)
+		}
+
+		reservations = append(reservations, model.Reservation{ID: id})
+	}
+
+	if err := s.service.DeleteReservations(ctx, reservations); err != nil {
+		return nil, toStatus("DeleteReservations", err)
+	}
+
+	return &reservationsv1.DeleteReservationsResponse{}, nil
+}
+
+func (s *GRPCServer) GetStocks(
+	ctx context.Context,
+	req *reservationsv1.GetStocksRequest,
+) (*reservationsv1.GetStocksResponse, error) {
+	params, err := getParamsFromProto(req)
+	if err != nil {
+		return nil, toStatus("GetStocks", err)
+	}
+
+	page, err := s.service.GetStocks(ctx, params)
+	if err != nil {
+		return nil, toStatus("GetStocks", err)
+	}
+
+	response := &reservationsv1.GetStocksResponse{
+		Stocks:     make([]*reservationsv1.Stock, 0, len(page.Items)),
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+
+	for _, value := range page.Items {
+		response.Stocks = append(response.Stocks, &reservationsv1.Stock{
+			WarehouseId:       value.WarehouseID.String(),
+			ProductId:         value.ProductID,
+			Quantity:          uint32(value.Quantity),
+			ReservedQuantity:  uint32(value.ReservedQuantity),
+			AvailableQuantity: uint32(value.AvailableQuantity),
+			CreatedAt:         timestamppb.New(value.CreatedAt),
+			ModifiedAt:        timestamppb.New(value.ModifiedAt),
+		})
+	}
+
+	return response, nil
+}
+
+func getParamsFromProto(req *reservationsv1.GetStocksRequest) (model.GetParams, error) {
+	params := model.GetParams{
+		Offset:               uint(req.GetOffset()),
+		Limit:                uint(req.GetLimit()),
+		Sorting:              req.GetSorting(),
+		Descending:           req.GetDescending(),
+		WarehouseFilter:      req.GetWarehouseFilter(),
+		ProductFilter:        req.GetProductFilter(),
+		Cursor:               req.GetCursor(),
+		ProductIDs:           req.GetProductIds(),
+		MinQuantity:          optionalUint(req.MinQuantity),
+		MaxQuantity:          optionalUint(req.MaxQuantity),
+		MinReservedQuantity:  optionalUint(req.MinReservedQuantity),
+		MaxReservedQuantity:  optionalUint(req.MaxReservedQuantity),
+		MinAvailableQuantity: optionalUint(req.MinAvailableQuantity),
+		MaxAvailableQuantity: optionalUint(req.MaxAvailableQuantity),
+		InStockOnly:          req.GetInStockOnly(),
+	}
+
+	for _, value := range req.GetWarehouseIds() {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return params, model.ErrInvalidUUID
+		}
+
+		params.WarehouseIDs = append(params.WarehouseIDs, id)
+	}
+
+	if req.GetModifiedSince() != nil {
+		modifiedSince := req.GetModifiedSince().AsTime()
+		params.ModifiedSince = &modifiedSince
+	}
+
+	return params, nil
+}
+
+func optionalUint(value *uint32) *uint {
+	if value == nil {
+		return nil
+	}
+
+	result := uint(*value)
+
+	return &result
+}
+
+func reservationFromProto(reservation *reservationsv1.Reservation) (model.Reservation, error) {
+	id, err := uuid.Parse(reservation.GetId())
+	if err != nil {
+		return model.Reservation{}, model.ErrInvalidUUID
+	}
+
+	warehouseID, err := uuid.Parse(reservation.GetWarehouseId())
+	if err != nil {
+		return model.Reservation{}, model.ErrInvalidUUID
+	}
+
+	return model.Reservation{
+		ID:          id,
+		WarehouseID: warehouseID,
+		ProductID:   reservation.GetProductId(),
+		Quantity:    uint(reservation.GetQuantity()),
+		DueDate:     reservation.GetDueDate().AsTime(),
+	}, nil
+}
+
+func reservationToProto(reservation model.Reservation) *reservationsv1.Reservation {
+	return &reservationsv1.Reservation{
+		Id:          reservation.ID.String(),
+		WarehouseId: reservation.WarehouseID.String(),
+		ProductId:   reservation.ProductID,
+		Quantity:    uint32(reservation.Quantity),
+		IsActive:    reservation.IsActive,
+		CreatedAt:   timestamppb.New(reservation.CreatedAt),
+		DueDate:     timestamppb.New(reservation.DueDate),
+	}
+}
+
+// toStatus maps typed model errors to grpc status with google.rpc error details.
+//
+//nolint:cyclop
+func toStatus(method string, err error) error {
+	var (
+		errDuplicateReservation *model.DuplicateReservationError
+		errStockNotFound        *model.StockNotFoundError
+		errNotEnoughQuantity    *model.NotEnoughQuantityError
+		errReservationNotFound  *model.ReservationNotFoundError
+		errInvalidSortField     *model.InvalidSortFieldError
+		errWarehouseForbidden   *model.WarehouseForbiddenError
+	)
+
+	switch {
+	case errors.Is(err, model.ErrInvalidUUID):
+		return invalidArgument("id", "invalid uuid")
+	case errors.Is(err, model.ErrInvalidSKU):
+		return invalidArgument("product_id", "invalid product sku")
+	case errors.Is(err, model.ErrInvalidQuantity):
+		return invalidArgument("quantity", "invalid quantity")
+	case errors.Is(err, model.ErrIncorrectDueDate):
+		return invalidArgument("due_date", "incorrect due date")
+	case errors.Is(err, model.ErrInvalidGetParams):
+		return invalidArgument("", "invalid get params")
+	case errors.Is(err, model.ErrInvalidCursor):
+		return invalidArgument("cursor", "invalid cursor")
+	case errors.As(err, &errInvalidSortField):
+		return invalidArgument("sorting", errInvalidSortField.Error())
+	case errors.As(err, &errDuplicateReservation):
+		return withDetails(
+			status.New(codes.AlreadyExists, errDuplicateReservation.Error()),
+			errorInfo(reservationsv1.ErrorReason_ERROR_REASON_DUPLICATE_RESERVATION, map[string]string{
+				"reservationId": errDuplicateReservation.ReservationID.String(),
+			}))
+	case errors.As(err, &errStockNotFound):
+		return withDetails(
+			status.New(codes.NotFound, errStockNotFound.Error()),
+			errorInfo(reservationsv1.ErrorReason_ERROR_REASON_STOCK_NOT_FOUND, map[string]string{
+				"warehouseId": errStockNotFound.WarehouseID.String(),
+				"productId":   errStockNotFound.SKU,
+			}),
+			&errdetails.ResourceInfo{
+				ResourceType: "stock",
+				ResourceName: errStockNotFound.WarehouseID.String() + "/" + errStockNotFound.SKU,
+			})
+	case errors.As(err, &errNotEnoughQuantity):
+		return withDetails(
+			status.New(codes.FailedPrecondition, errNotEnoughQuantity.Error()),
+			errorInfo(reservationsv1.ErrorReason_ERROR_REASON_NOT_ENOUGH_QUANTITY, map[string]string{
+				"warehouseId":       errNotEnoughQuantity.WarehouseID.String(),
+				"productId":         errNotEnoughQuantity.SKU,
+				"requestedQuantity": strconv.FormatUint(uint64(errNotEnoughQuantity.RequiredQuantity), 10),
+				"availableQuantity": strconv.FormatUint(uint64(errNotEnoughQuantity.AvailableQuantity), 10),
+			}),
+			&errdetails.PreconditionFailure{
+				Violations: []*errdetails.PreconditionFailure_Violation{{
+					Type:        "QUANTITY",
+					Subject:     errNotEnoughQuantity.WarehouseID.String() + "/" + errNotEnoughQuantity.SKU,
+					Description: errNotEnoughQuantity.Error(),
+				}},
+			})
+	case errors.As(err, &errReservationNotFound):
+		return withDetails(
+			status.New(codes.NotFound, errReservationNotFound.Error()),
+			errorInfo(reservationsv1.ErrorReason_ERROR_REASON_RESERVATION_NOT_FOUND, map[string]string{
+				"reservationId": errReservationNotFound.ReservationID.String(),
+			}),
+			&errdetails.ResourceInfo{
+				ResourceType: "reservation",
+				ResourceName: errReservationNotFound.ReservationID.String(),
+			})
+	case errors.As(err, &errWarehouseForbidden):
+		return withDetails(
+			status.New(codes.PermissionDenied, errWarehouseForbidden.Error()),
+			&errdetails.ResourceInfo{
+				ResourceType: "warehouse",
+				ResourceName: errWarehouseForbidden.WarehouseID.String(),
+			})
+	default:
+		zap.L().With(zap.Error(err)).Warn(method + "/s.service")
+
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+func invalidArgument(field, description string) error {
+	return withDetails(
+		status.New(codes.InvalidArgument, description),
+		errorInfo(reservationsv1.ErrorReason_ERROR_REASON_INVALID_ARGUMENT, nil),
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+				Field:       field,
+				Description: description,
+			}},
+		})
+}
+
+func errorInfo(reason reservationsv1.ErrorReason, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{
+		Reason:   reason.String(),
+		Domain:   errorDomain,
+		Metadata: metadata,
+	}
+}
+
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn("withDetails/st.WithDetails(details...)")
+
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
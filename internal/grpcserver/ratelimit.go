@@ -0,0 +1,75 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type rateLimiter interface {
+	Acquire(ctx context.Context, client, route string) (release func(), retryAfter time.Duration, err error)
+}
+
+// SetRateLimiter enables limiting calls of every client to every method. Without limiter calls aren't limited.
+func (s *GRPCServer) SetRateLimiter(limiter rateLimiter) {
+	s.limiter = limiter
+}
+
+// rateLimit rejects calls of clients which are over limit of method with ResourceExhausted error and RetryInfo.
+// Method is named by its full name, e.g. "/reservations.v1.ReservationService/CreateReservations".
+func (s *GRPCServer) rateLimit(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if s.limiter == nil {
+		return handler(ctx, req)
+	}
+
+	release, retryAfter, err := s.limiter.Acquire(ctx, clientOfCall(ctx), info.FullMethod)
+	if err != nil {
+		// limiter failure shouldn't make api unavailable
+		zap.L().With(zap.Error(err)).Warn("rateLimit/s.limiter.Acquire(ctx, client, info.FullMethod)")
+
+		return handler(ctx, req)
+	}
+
+	if release == nil {
+		return nil, withDetails(
+			status.New(codes.ResourceExhausted, "too many requests, retry later"),
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	}
+
+	defer release()
+
+	return handler(ctx, req)
+}
+
+// clientOfCall identifies authenticated client by its id and anonymous one by its address.
+func clientOfCall(ctx context.Context) string {
+	if client, ok := model.ClientFromContext(ctx); ok {
+		return client.ID
+	}
+
+	callPeer, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(callPeer.Addr.String())
+	if err != nil {
+		return callPeer.Addr.String()
+	}
+
+	return host
+}
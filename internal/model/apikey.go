@@ -0,0 +1,98 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scope grants access to a group of api methods.
+type Scope string
+
+const (
+	ScopeStocksRead        Scope = "stocks:read"
+	ScopeStocksWrite       Scope = "stocks:write"
+	ScopeReservationsRead  Scope = "reservations:read"
+	ScopeReservationsWrite Scope = "reservations:write"
+	// ScopeAdmin grants every other scope together with overbooking and workers management
+	ScopeAdmin Scope = "admin"
+)
+
+var Scopes = []Scope{ScopeStocksRead, ScopeStocksWrite, ScopeReservationsRead, ScopeReservationsWrite, ScopeAdmin}
+
+const (
+	apiKeyMarker      = "lhr_"
+	apiKeySecretBytes = 32
+	// APIKeyPrefixLength is the length of key beginning, which is stored as is to recognize keys in listings and logs
+	APIKeyPrefixLength  = 12
+	APIKeyNameMaxLength = 128
+)
+
+// APIKey identifies api client. Key itself is shown only once after creation, only its hash is stored.
+type APIKey struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []Scope    `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Client returns identity of key owner.
+func (k APIKey) Client() *Client {
+	return &Client{ID: k.ID.String(), Name: k.Name, Scopes: k.Scopes}
+}
+
+// IsAPIKey tells api keys from other credentials, like bearer tokens.
+func IsAPIKey(secret string) bool {
+	return strings.HasPrefix(secret, apiKeyMarker)
+}
+
+// NewAPIKeySecret generates random key. Keys have enough entropy to be stored as plain sha256 hashes.
+func NewAPIKeySecret() (string, error) {
+	data := make([]byte, apiKeySecretBytes)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("rand.Read(data): %w", err)
+	}
+
+	return apiKeyMarker + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func HashAPIKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+
+	return sum[:]
+}
+
+func APIKeyPrefix(secret string) string {
+	if len(secret) < APIKeyPrefixLength {
+		return secret
+	}
+
+	return secret[:APIKeyPrefixLength]
+}
+
+func ValidateAPIKeyRequest(name string, scopes []Scope) error {
+	if name == "" || len(name) > APIKeyNameMaxLength {
+		return ErrInvalidAPIKeyName
+	}
+
+	if len(scopes) == 0 {
+		return ErrInvalidScope
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return ErrInvalidScope
+		}
+	}
+
+	return nil
+}
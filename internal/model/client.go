@@ -0,0 +1,81 @@
+package model
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Role is granted to token holders by identity provider. Every role maps to a set of scopes.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleScopes = map[Role][]Scope{
+	RoleViewer:   {ScopeStocksRead, ScopeReservationsRead},
+	RoleOperator: {ScopeStocksRead, ScopeStocksWrite, ScopeReservationsRead, ScopeReservationsWrite},
+	RoleAdmin:    {ScopeAdmin},
+}
+
+// ScopesOfRoles returns scopes granted by roles. Unknown roles grant nothing.
+func ScopesOfRoles(roles []string) []Scope {
+	scopes := make([]Scope, 0)
+
+	for _, role := range roles {
+		for _, scope := range roleScopes[Role(role)] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
+
+// Client is an authenticated caller of api. It is identified either by api key or by bearer token.
+type Client struct {
+	// ID is an id of api key or a subject of token
+	ID     string
+	Name   string
+	Scopes []Scope
+	// Warehouses limits warehouses client may act on. Nil means every warehouse
+	Warehouses []uuid.UUID
+}
+
+func (c Client) HasScope(scope Scope) bool {
+	return slices.Contains(c.Scopes, scope) || slices.Contains(c.Scopes, ScopeAdmin)
+}
+
+func (c Client) CanActOn(warehouseID uuid.UUID) bool {
+	return c.Warehouses == nil || slices.Contains(c.Warehouses, warehouseID)
+}
+
+type clientContextKey struct{}
+
+// ContextWithClient stores authenticated client in ctx.
+func ContextWithClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns authenticated client. It is absent if authentication is disabled.
+func ClientFromContext(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(clientContextKey{}).(*Client)
+
+	return client, ok
+}
+
+// CheckWarehouseAccess returns WarehouseForbiddenError if client of ctx may not act on warehouse.
+// Without client in ctx every warehouse is allowed.
+func CheckWarehouseAccess(ctx context.Context, warehouseID uuid.UUID) error {
+	client, ok := ClientFromContext(ctx)
+	if ok && !client.CanActOn(warehouseID) {
+		return &WarehouseForbiddenError{WarehouseID: warehouseID}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StocksCursor points at the last stock of a page. Stocks are ordered by sorting column
+// and then by (warehouse_id, product_id), so the cursor keeps all three values.
+type StocksCursor struct {
+	Sorting     string    `json:"s,omitempty"`
+	Descending  bool      `json:"d,omitempty"`
+	Value       string    `json:"v,omitempty"`
+	WarehouseID uuid.UUID `json:"w"`
+	ProductID   string    `json:"p"`
+}
+
+// NewStocksCursor returns cursor which points at stock. It returns false if results
+// sorted by params.Sorting can't be paginated with cursor.
+func NewStocksCursor(params GetParams, stock Stock) (*StocksCursor, bool) {
+	cursor := StocksCursor{
+		Sorting:     params.Sorting,
+		Descending:  params.Descending,
+		WarehouseID: stock.WarehouseID,
+		ProductID:   stock.ProductID,
+	}
+
+	switch params.Sorting {
+	case "", "warehouse_id", "product_id":
+	case "quantity":
+		cursor.Value = strconv.FormatUint(uint64(stock.Quantity), 10)
+	case "reserved_quantity":
+		cursor.Value = strconv.FormatUint(uint64(stock.ReservedQuantity), 10)
+	case "available_quantity":
+		cursor.Value = strconv.FormatUint(uint64(stock.AvailableQuantity), 10)
+	case "created_at":
+		cursor.Value = stock.CreatedAt.Format(time.RFC3339Nano)
+	case "modified_at":
+		cursor.Value = stock.ModifiedAt.Format(time.RFC3339Nano)
+	default:
+		return nil, false
+	}
+
+	return &cursor, true
+}
+
+func (c StocksCursor) Encode() string {
+	//nolint:errchkjson
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeStocksCursor parses cursor returned with previous page. Cursor is valid only
+// for the same sorting it was created with.
+func DecodeStocksCursor(value string, params GetParams) (*StocksCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor StocksCursor
+
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sorting != params.Sorting || cursor.Descending != params.Descending {
+		return nil, ErrInvalidCursor
+	}
+
+	switch cursor.Sorting {
+	case "", "warehouse_id", "product_id":
+	case "quantity", "reserved_quantity", "available_quantity":
+		_, err = strconv.ParseUint(cursor.Value, 10, 32)
+	case "created_at", "modified_at":
+		_, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	default:
+		err = ErrInvalidCursor
+	}
+
+	if err != nil || cursor.WarehouseID == uuid.Nil || cursor.ProductID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
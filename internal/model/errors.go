@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -15,7 +16,28 @@ var (
 	ErrIncorrectDueDate    = errors.New("err incorrect due date")
 	ErrInvalidQuantity     = errors.New("err invalid quantity")
 	ErrInvalidLimit        = errors.New("err invalid limit")
+	ErrInvalidBatchSize    = errors.New("err batch size must be positive")
+	ErrFutureAsOf          = errors.New("err reservations can't be deactivated as of future")
 	ErrInvalidGetParams    = errors.New("err invalid get params")
+	ErrInvalidCursor       = errors.New("err invalid cursor")
+	ErrInvalidOverbooking  = errors.New("err invalid overbooking allowance")
+	ErrInvalidRegion       = errors.New("err invalid warehouse region")
+	ErrUnauthorized        = errors.New("err unauthorized")
+	ErrInvalidScope        = errors.New("err invalid scope")
+	ErrInvalidAPIKeyName   = errors.New("err invalid api key name")
+	ErrAPIKeyNotFound      = errors.New("err api key not found")
+	ErrInvalidRateLimit    = errors.New("err invalid rate limit")
+	ErrInvalidStockChange  = errors.New("err invalid stock change kind")
+	ErrNegativeStock       = errors.New("err stock quantity can't become negative")
+	ErrStockEventsLagged   = errors.New("err stock events subscriber fell behind")
+	ErrInvalidWebhook      = errors.New("err invalid webhook subscription")
+	ErrWebhookNotFound     = errors.New("err webhook subscription not found")
+	ErrDeliveryNotFound    = errors.New("err webhook delivery not found")
+	ErrPendingMigrations   = errors.New("err db has pending migrations")
+	ErrWorkerNotRunning    = errors.New("err worker is not running")
+	ErrWorkerStalled       = errors.New("err worker stalled")
+	ErrLeaseLost           = errors.New("err worker lease is taken by another instance")
+	ErrWebhookCursorMoved  = errors.New("err webhook cursor was moved by another dispatcher")
 )
 
 type DuplicateReservationError struct {
@@ -39,6 +61,8 @@ type NotEnoughQuantityError struct {
 	SKU              string
 	WarehouseID      uuid.UUID
 	RequiredQuantity uint
+	// AvailableQuantity is quantity which still can be reserved, including overbooking allowance
+	AvailableQuantity uint
 }
 
 func (e NotEnoughQuantityError) Error() string {
@@ -52,3 +76,75 @@ type ReservationNotFoundError struct {
 func (e ReservationNotFoundError) Error() string {
 	return fmt.Sprintf("err reservation %s not found", e.ReservationID.String())
 }
+
+type WarehouseNotFoundError struct {
+	WarehouseID uuid.UUID
+}
+
+func (e WarehouseNotFoundError) Error() string {
+	return fmt.Sprintf("err warehouse %s not found", e.WarehouseID.String())
+}
+
+type ProductNotFoundError struct {
+	SKU string
+}
+
+func (e ProductNotFoundError) Error() string {
+	return fmt.Sprintf("err product %s not found", e.SKU)
+}
+
+type WarehouseForbiddenError struct {
+	WarehouseID uuid.UUID
+}
+
+func (e WarehouseForbiddenError) Error() string {
+	return fmt.Sprintf("err no access to warehouse %s", e.WarehouseID.String())
+}
+
+// StockVersionConflictError is returned if stock was changed since the version expected by caller.
+type StockVersionConflictError struct {
+	ExpectedVersion uint
+	Current         Stock
+}
+
+func (e StockVersionConflictError) Error() string {
+	return fmt.Sprintf("err stock %s at %s has version %d, expected %d",
+		e.Current.ProductID, e.Current.WarehouseID.String(), e.Current.Version, e.ExpectedVersion)
+}
+
+// StockBelowReservedError is returned if stock change would make quantity smaller than reserved quantity
+// allows with overbooking allowance of stock.
+type StockBelowReservedError struct {
+	RequestedQuantity uint
+	MinQuantity       uint
+	Current           Stock
+}
+
+func (e StockBelowReservedError) Error() string {
+	return fmt.Sprintf("err quantity of %s at %s can't be less than %d, %d is reserved, requested %d",
+		e.Current.ProductID, e.Current.WarehouseID.String(), e.MinQuantity, e.Current.ReservedQuantity,
+		e.RequestedQuantity)
+}
+
+type InvalidSortFieldError struct {
+	Field   string
+	Allowed []string
+}
+
+func (e InvalidSortFieldError) Error() string {
+	return fmt.Sprintf("err invalid sort field %q, allowed fields: %s", e.Field, strings.Join(e.Allowed, ", "))
+}
+
+// ItemError points at the item of a batch request which caused Err.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("item %d: %s", e.Index, e.Err.Error())
+}
+
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,37 @@
+package model
+
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthFail HealthStatus = "fail"
+)
+
+// HealthCheck is result of checking a single dependency of the service.
+type HealthCheck struct {
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// Readiness tells if instance can serve requests. It is ready only if every check passed.
+type Readiness struct {
+	Status HealthStatus  `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// AddCheck appends result of check named name. Failed check makes the whole readiness fail.
+func (r *Readiness) AddCheck(name string, err error) {
+	check := HealthCheck{Name: name, Status: HealthOK}
+
+	if err != nil {
+		check.Status, check.Error = HealthFail, err.Error()
+		r.Status = HealthFail
+	}
+
+	if r.Status == "" {
+		r.Status = HealthOK
+	}
+
+	r.Checks = append(r.Checks, check)
+}
@@ -1,18 +1,24 @@
 package model
 
 import (
-	"strings"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-const SKUMaxLength = 12
+const (
+	SKUMaxLength          = 12
+	MaxOverbookingPercent = 100
+	MaxFilterValues       = 100
+	RegionMaxLength       = 64
+)
 
 type Warehouse struct {
 	ID        uuid.UUID
 	Name      string
 	IsActive  bool
+	Region    string
 	CreatedAt time.Time
 }
 
@@ -28,17 +34,127 @@ type Stock struct {
 	ProductID        string    `json:"productId"`
 	Quantity         uint      `json:"quantity"`
 	ReservedQuantity uint      `json:"reservedQuantity"`
-	CreatedAt        time.Time `json:"createdAt,omitempty"`
-	ModifiedAt       time.Time `json:"modifiedAt,omitempty"`
+	// AvailableQuantity is computed from Quantity and ReservedQuantity and is never negative
+	AvailableQuantity uint      `json:"availableQuantity"`
+	CreatedAt         time.Time `json:"createdAt,omitempty"`
+	ModifiedAt        time.Time `json:"modifiedAt,omitempty"`
+	// Version grows with every change of stock, including changes of ReservedQuantity by reservations
+	Version uint `json:"version"`
 }
 
 type Reservation struct {
-	ID          uuid.UUID `json:"id"`
+	ID            uuid.UUID  `json:"id"`
+	WarehouseID   uuid.UUID  `json:"warehouseId"`
+	ProductID     string     `json:"productId"`
+	Quantity      uint       `json:"quantity"`
+	IsActive      bool       `json:"isActive"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DueDate       time.Time  `json:"dueDate"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+	ArchivedAt    *time.Time `json:"archivedAt,omitempty"`
+}
+
+type ReservationUpdate struct {
+	DueDate time.Time `json:"dueDate"`
+}
+
+type GetReservationsParams struct {
+	Offset          uint        `json:"offset,omitempty"`
+	Limit           uint        `json:"limit,omitempty"`
+	IDs             []uuid.UUID `json:"ids,omitempty"`
+	WarehouseID     *uuid.UUID  `json:"warehouseId,omitempty"`
+	ProductID       string      `json:"productId,omitempty"`
+	ActiveOnly      bool        `json:"activeOnly,omitempty"`
+	IncludeArchived bool        `json:"includeArchived,omitempty"`
+	Total           TotalMode   `json:"total,omitempty"`
+}
+
+type OverbookedStock struct {
+	Stock
+	OverbookingPercent uint `json:"overbookingPercent"`
+	OverbookedQuantity uint `json:"overbookedQuantity"`
+}
+
+// OverbookingAllowance sets overbooking percent either for a warehouse or for a product.
+// Product allowance overrides the warehouse one, nil Percent for a product resets the override.
+type OverbookingAllowance struct {
 	WarehouseID uuid.UUID `json:"warehouseId"`
-	ProductID   string    `json:"productId"`
-	Quantity    uint      `json:"quantity"`
-	CreatedAt   time.Time `json:"createdAt"`
-	DueDate     time.Time `json:"dueDate"`
+	ProductID   string    `json:"productId,omitempty"`
+	Percent     *uint     `json:"percent"`
+}
+
+// WarehouseRegion puts warehouse into region, availability can be grouped by regions.
+// Empty region takes warehouse out of every region.
+type WarehouseRegion struct {
+	WarehouseID uuid.UUID `json:"-"`
+	Region      string    `json:"region"`
+}
+
+// DeactivationStats describes a single run of due reservations deactivation.
+type DeactivationStats struct {
+	Batches      uint          `json:"batches"`
+	Reservations int64         `json:"reservations"`
+	Stocks       int64         `json:"stocks"`
+	Duration     time.Duration `json:"duration"`
+}
+
+const (
+	DefaultDeactivationBatchSize = 1000
+	DefaultPreviewLimit          = 100
+	MaxPreviewLimit              = 1000
+
+	MaxReservationsLimit = 1000
+)
+
+// DeactivationRequest forces deactivation of reservations due before AsOf, or before now if it is not set.
+// Dry run doesn't change anything and returns reservations which would be deactivated.
+type DeactivationRequest struct {
+	AsOf      *time.Time `json:"asOf,omitempty"`
+	DryRun    bool       `json:"dryRun,omitempty"`
+	BatchSize uint       `json:"batchSize,omitempty"`
+	Limit     uint       `json:"limit,omitempty"`
+}
+
+type StockDelta struct {
+	WarehouseID           uuid.UUID `json:"warehouseId"`
+	ProductID             string    `json:"productId"`
+	ReservedQuantityDelta int64     `json:"reservedQuantityDelta"`
+}
+
+type DeactivationResult struct {
+	DryRun       bool               `json:"dryRun"`
+	Stats        *DeactivationStats `json:"stats"`
+	Reservations []Reservation      `json:"reservations,omitempty"`
+	StockDeltas  []StockDelta       `json:"stockDeltas,omitempty"`
+}
+
+// Lease shows which service instance currently runs a background worker.
+type Lease struct {
+	Name       string    `json:"name"`
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	RenewedAt  time.Time `json:"renewedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	IsExpired  bool      `json:"isExpired"`
+}
+
+// LeaseFence identifies a single term of lease holder. Token changes every time lease changes hands,
+// so writes of a worker which has lost its lease in the middle of a sweep are rejected.
+type LeaseFence struct {
+	Name   string
+	Holder string
+	Token  int64
+}
+
+// StocksSortFields contains fields which stocks can be sorted by.
+var StocksSortFields = []string{
+	"warehouse_id",
+	"product_id",
+	"quantity",
+	"reserved_quantity",
+	"available_quantity",
+	"created_at",
+	"modified_at",
 }
 
 type GetParams struct {
@@ -48,6 +164,67 @@ type GetParams struct {
 	Descending      bool   `json:"descending,omitempty"`
 	WarehouseFilter string `json:"warehouseFilter,omitempty"`
 	ProductFilter   string `json:"productFilter,omitempty"`
+	Cursor          string `json:"cursor,omitempty"`
+	// Total defines if total amount of matching stocks is counted
+	Total TotalMode `json:"total,omitempty"`
+
+	WarehouseIDs         []uuid.UUID `json:"warehouseIds,omitempty"`
+	ProductIDs           []string    `json:"productIds,omitempty"`
+	MinQuantity          *uint       `json:"minQuantity,omitempty"`
+	MaxQuantity          *uint       `json:"maxQuantity,omitempty"`
+	MinReservedQuantity  *uint       `json:"minReservedQuantity,omitempty"`
+	MaxReservedQuantity  *uint       `json:"maxReservedQuantity,omitempty"`
+	MinAvailableQuantity *uint       `json:"minAvailableQuantity,omitempty"`
+	MaxAvailableQuantity *uint       `json:"maxAvailableQuantity,omitempty"`
+	ModifiedSince        *time.Time  `json:"modifiedSince,omitempty"`
+	// InStockOnly skips stocks without available quantity
+	InStockOnly bool `json:"inStockOnly,omitempty"`
+}
+
+type AvailabilityParams struct {
+	ProductIDs           []string `json:"productIds"`
+	ActiveWarehousesOnly bool     `json:"activeWarehousesOnly,omitempty"`
+	// GroupByRegion makes breakdown contain regions instead of single warehouses
+	GroupByRegion bool `json:"groupByRegion,omitempty"`
+}
+
+// Availability shows quantity of product at a single warehouse or at all warehouses of region.
+type Availability struct {
+	WarehouseID       *uuid.UUID `json:"warehouseId,omitempty"`
+	Region            string     `json:"region,omitempty"`
+	Quantity          uint       `json:"quantity"`
+	ReservedQuantity  uint       `json:"reservedQuantity"`
+	AvailableQuantity uint       `json:"availableQuantity"`
+}
+
+// ProductAvailability shows total quantity of product across warehouses. AvailableQuantity
+// is a sum of warehouses available quantities, so overbooked warehouses don't decrease it.
+type ProductAvailability struct {
+	ProductID         string         `json:"productId"`
+	Quantity          uint           `json:"quantity"`
+	ReservedQuantity  uint           `json:"reservedQuantity"`
+	AvailableQuantity uint           `json:"availableQuantity"`
+	Breakdown         []Availability `json:"breakdown"`
+	// ModifiedAt is the latest modification of product stocks. It is absent if product has no stocks
+	ModifiedAt *time.Time `json:"modifiedAt,omitempty"`
+}
+
+// HasExtendedFilters reports if params contain filters other than single warehouse and product.
+func (p GetParams) HasExtendedFilters() bool {
+	return len(p.WarehouseIDs) > 0 || len(p.ProductIDs) > 0 ||
+		p.MinQuantity != nil || p.MaxQuantity != nil ||
+		p.MinReservedQuantity != nil || p.MaxReservedQuantity != nil ||
+		p.MinAvailableQuantity != nil || p.MaxAvailableQuantity != nil ||
+		p.ModifiedSince != nil || p.InStockOnly
+}
+
+// AvailableQuantity returns quantity which is not reserved yet. Overbooked stocks have none.
+func AvailableQuantity(quantity, reservedQuantity uint) uint {
+	if reservedQuantity >= quantity {
+		return 0
+	}
+
+	return quantity - reservedQuantity
 }
 
 func ValidateReservationRequest(reservation Reservation) error {
@@ -74,24 +251,138 @@ func ValidateReservationRequest(reservation Reservation) error {
 	return nil
 }
 
+func ValidateOverbookingAllowance(allowance OverbookingAllowance) error {
+	if (allowance.WarehouseID == uuid.Nil) == (allowance.ProductID == "") {
+		return ErrInvalidOverbooking
+	}
+
+	if len(allowance.ProductID) > SKUMaxLength {
+		return ErrInvalidSKU
+	}
+
+	if allowance.WarehouseID != uuid.Nil && allowance.Percent == nil {
+		return ErrInvalidOverbooking
+	}
+
+	if allowance.Percent != nil && *allowance.Percent > MaxOverbookingPercent {
+		return ErrInvalidOverbooking
+	}
+
+	return nil
+}
+
+// ReservationLimit returns maximum quantity which can be reserved from stock with given overbooking percent.
+func ReservationLimit(quantity, overbookingPercent uint) uint {
+	return quantity + quantity*overbookingPercent/100
+}
+
+// MinStockQuantity returns the least quantity of stock, whose reservation limit covers reserved quantity.
+func MinStockQuantity(reservedQuantity, overbookingPercent uint) uint {
+	quantity := reservedQuantity * 100 / (100 + overbookingPercent)
+
+	for ReservationLimit(quantity, overbookingPercent) < reservedQuantity {
+		quantity++
+	}
+
+	return quantity
+}
+
+func ValidateWarehouseRegion(region WarehouseRegion) error {
+	if region.WarehouseID == uuid.Nil {
+		return ErrInvalidUUID
+	}
+
+	if len(region.Region) > RegionMaxLength {
+		return ErrInvalidRegion
+	}
+
+	return nil
+}
+
+func ValidateDeactivationRequest(request DeactivationRequest) error {
+	if request.Limit > MaxPreviewLimit {
+		return ErrInvalidLimit
+	}
+
+	// future moment would expire reservations which are still valid, it can only be previewed
+	if !request.DryRun && request.AsOf != nil && request.AsOf.After(time.Now()) {
+		return ErrFutureAsOf
+	}
+
+	return nil
+}
+
+func ValidateReservationUpdate(update ReservationUpdate) error {
+	if time.Now().After(update.DueDate) {
+		return ErrIncorrectDueDate
+	}
+
+	return nil
+}
+
+func ValidateGetReservationsParams(params GetReservationsParams) error {
+	if len(params.ProductID) > SKUMaxLength {
+		return ErrInvalidSKU
+	}
+
+	if params.Limit > MaxReservationsLimit {
+		return ErrInvalidLimit
+	}
+
+	return ValidateTotalMode(params.Total)
+}
+
 func ValidateGetParams(params GetParams) error {
-	words := strings.Fields(params.Sorting)
-	if len(words) > 1 {
+	if params.Sorting != "" && !slices.Contains(StocksSortFields, params.Sorting) {
+		return &InvalidSortFieldError{Field: params.Sorting, Allowed: StocksSortFields}
+	}
+
+	if params.WarehouseFilter != "" {
+		if err := uuid.Validate(params.WarehouseFilter); err != nil {
+			return ErrInvalidGetParams
+		}
+	}
+
+	if len(params.ProductFilter) > SKUMaxLength {
+		return ErrInvalidSKU
+	}
+
+	if len(params.WarehouseIDs) > MaxFilterValues || len(params.ProductIDs) > MaxFilterValues {
 		return ErrInvalidGetParams
 	}
 
-	words = strings.Fields(params.WarehouseFilter)
-	if len(words) > 1 {
+	for _, productID := range params.ProductIDs {
+		if len(productID) > SKUMaxLength || productID == "" {
+			return ErrInvalidSKU
+		}
+	}
+
+	if !validRange(params.MinQuantity, params.MaxQuantity) ||
+		!validRange(params.MinReservedQuantity, params.MaxReservedQuantity) ||
+		!validRange(params.MinAvailableQuantity, params.MaxAvailableQuantity) {
 		return ErrInvalidGetParams
 	}
 
-	words = strings.Fields(params.ProductFilter)
-	if len(words) > 1 {
+	if params.Cursor != "" && params.Offset != 0 {
 		return ErrInvalidGetParams
 	}
 
-	if len(params.ProductFilter) > SKUMaxLength {
-		return ErrInvalidSKU
+	return ValidateTotalMode(params.Total)
+}
+
+func validRange(minValue, maxValue *uint) bool {
+	return minValue == nil || maxValue == nil || *minValue <= *maxValue
+}
+
+func ValidateAvailabilityParams(params AvailabilityParams) error {
+	if len(params.ProductIDs) == 0 || len(params.ProductIDs) > MaxFilterValues {
+		return ErrInvalidGetParams
+	}
+
+	for _, productID := range params.ProductIDs {
+		if len(productID) > SKUMaxLength || productID == "" {
+			return ErrInvalidSKU
+		}
 	}
 
 	return nil
@@ -0,0 +1,33 @@
+package model
+
+// TotalMode defines how total amount of matching items is counted for a page.
+type TotalMode string
+
+const (
+	// TotalNone skips counting, which is the cheapest option.
+	TotalNone TotalMode = ""
+	// TotalExact counts every matching item.
+	TotalExact TotalMode = "exact"
+	// TotalApproximate uses planner estimation, which doesn't depend on amount of items.
+	TotalApproximate TotalMode = "approximate"
+)
+
+// Page is a single page of list. NextCursor is set only for lists which support cursor pagination.
+type Page[T any] struct {
+	Items              []T
+	Limit              uint
+	Offset             uint
+	HasMore            bool
+	NextCursor         string
+	Total              *int64
+	TotalIsApproximate bool
+}
+
+func ValidateTotalMode(mode TotalMode) error {
+	switch mode {
+	case TotalNone, TotalExact, TotalApproximate:
+		return nil
+	default:
+		return ErrInvalidGetParams
+	}
+}
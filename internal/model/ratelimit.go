@@ -0,0 +1,47 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RateLimit is a token bucket: Burst requests may be made at once, then Rate requests per second.
+// Zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst uint
+}
+
+func (l RateLimit) Unlimited() bool {
+	return l.Rate == 0
+}
+
+// ParseRateLimit parses limit written as "<rate>/<burst>", e.g. "10/20" means 10 requests per second
+// with bursts up to 20 requests. Burst may be omitted, then it equals rate rounded up.
+func ParseRateLimit(value string) (RateLimit, error) {
+	rateValue, burstValue, found := strings.Cut(strings.TrimSpace(value), "/")
+
+	rate, err := strconv.ParseFloat(rateValue, 64)
+	if err != nil || rate < 0 {
+		return RateLimit{}, fmt.Errorf("%w: %q", ErrInvalidRateLimit, value)
+	}
+
+	limit := RateLimit{Rate: rate, Burst: uint(math.Ceil(rate))}
+
+	if found {
+		burst, err := strconv.ParseUint(burstValue, 10, 32)
+		if err != nil {
+			return RateLimit{}, fmt.Errorf("%w: %q", ErrInvalidRateLimit, value)
+		}
+
+		limit.Burst = uint(burst)
+	}
+
+	if rate > 0 && limit.Burst < 1 {
+		return RateLimit{}, fmt.Errorf("%w: %q", ErrInvalidRateLimit, value)
+	}
+
+	return limit, nil
+}
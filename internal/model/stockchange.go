@@ -0,0 +1,88 @@
+package model
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// StockChangeKind tells how StockChange.Quantity is applied to stock quantity.
+type StockChangeKind string
+
+const (
+	// StockReceive adds received quantity to stock
+	StockReceive StockChangeKind = "receive"
+	// StockAdjust adds positive or negative correction to stock, e.g. for damaged goods
+	StockAdjust StockChangeKind = "adjust"
+	// StockCount replaces stock quantity with counted one
+	StockCount StockChangeKind = "count"
+)
+
+var StockChangeKinds = []StockChangeKind{StockReceive, StockAdjust, StockCount}
+
+// MaxStockQuantity is the largest quantity stock can hold, it is limited by column type.
+const MaxStockQuantity = math.MaxInt32
+
+// StockChange changes actual quantity of stock. If ExpectedVersion is set, change is applied only
+// to stock of this version, so concurrent changes don't overwrite each other.
+type StockChange struct {
+	WarehouseID     uuid.UUID       `json:"-"`
+	ProductID       string          `json:"-"`
+	Kind            StockChangeKind `json:"kind"`
+	Quantity        int64           `json:"quantity"`
+	ExpectedVersion *uint           `json:"expectedVersion,omitempty"`
+}
+
+// Apply returns stock quantity after change. Change resulting in negative quantity is rejected,
+// as well as change exceeding MaxStockQuantity.
+func (c StockChange) Apply(quantity uint) (uint, error) {
+	result := c.Quantity
+
+	if c.Kind != StockCount {
+		result += int64(quantity)
+	}
+
+	switch {
+	case result < 0:
+		return 0, ErrNegativeStock
+	case result > MaxStockQuantity:
+		return 0, ErrInvalidQuantity
+	}
+
+	return uint(result), nil
+}
+
+func ValidateStockChange(change StockChange) error {
+	if err := uuid.Validate(change.WarehouseID.String()); err != nil || change.WarehouseID == uuid.Nil {
+		return ErrInvalidUUID
+	}
+
+	if len(change.ProductID) > SKUMaxLength || change.ProductID == "" {
+		return ErrInvalidSKU
+	}
+
+	// bounded quantity can't overflow when it is added to stock quantity
+	if change.Quantity > MaxStockQuantity || change.Quantity < -MaxStockQuantity {
+		return ErrInvalidQuantity
+	}
+
+	switch change.Kind {
+	case StockReceive:
+		if change.Quantity <= 0 {
+			return ErrInvalidQuantity
+		}
+	case StockAdjust:
+		if change.Quantity == 0 {
+			return ErrInvalidQuantity
+		}
+	case StockCount:
+		if change.Quantity < 0 {
+			return ErrInvalidQuantity
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidStockChange, change.Kind)
+	}
+
+	return nil
+}
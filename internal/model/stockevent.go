@@ -0,0 +1,73 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StockEventKind string
+
+const (
+	StockEventReserved StockEventKind = "reserved"
+	StockEventReleased StockEventKind = "released"
+	// StockEventExpired is written when deactivator releases due reservation
+	StockEventExpired         StockEventKind = "expired"
+	StockEventQuantityChanged StockEventKind = "quantity_changed"
+	// StockEventExpiring is written by outbox sink of expiry notifications. Reservation is going to expire soon,
+	// stock isn't changed
+	StockEventExpiring StockEventKind = "expiring"
+)
+
+// StockEvent is a committed change of stock. Events are numbered in order of commit,
+// so client can resume reading them after the last seen ID.
+type StockEvent struct {
+	ID            int64          `json:"id"`
+	Kind          StockEventKind `json:"kind"`
+	WarehouseID   uuid.UUID      `json:"warehouseId"`
+	ProductID     string         `json:"productId"`
+	ReservationID *uuid.UUID     `json:"reservationId,omitempty"`
+	// Quantity is reserved or released quantity, or difference of actual quantity
+	Quantity int64 `json:"quantity"`
+	// ChangeKind is set for quantity_changed events
+	ChangeKind StockChangeKind `json:"changeKind,omitempty"`
+	// StockQuantity, ReservedQuantity and Version describe stock right after the event
+	StockQuantity     uint      `json:"stockQuantity"`
+	ReservedQuantity  uint      `json:"reservedQuantity"`
+	AvailableQuantity uint      `json:"availableQuantity"`
+	Version           uint      `json:"version"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// StockEventsFilter selects events of any of warehouses and any of products. Empty lists match everything.
+type StockEventsFilter struct {
+	WarehouseIDs []uuid.UUID
+	ProductIDs   []string
+}
+
+func (f StockEventsFilter) Match(event StockEvent) bool {
+	if len(f.WarehouseIDs) > 0 && !slices.Contains(f.WarehouseIDs, event.WarehouseID) {
+		return false
+	}
+
+	if len(f.ProductIDs) > 0 && !slices.Contains(f.ProductIDs, event.ProductID) {
+		return false
+	}
+
+	return true
+}
+
+func ValidateStockEventsFilter(filter StockEventsFilter) error {
+	if len(filter.WarehouseIDs) > MaxFilterValues || len(filter.ProductIDs) > MaxFilterValues {
+		return ErrInvalidGetParams
+	}
+
+	for _, value := range filter.ProductIDs {
+		if len(value) > SKUMaxLength || value == "" {
+			return ErrInvalidSKU
+		}
+	}
+
+	return nil
+}
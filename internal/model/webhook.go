@@ -0,0 +1,206 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookReservationExpired WebhookEventType = "reservation.expired"
+	// WebhookStockLow is sent when available quantity of stock falls to low stock threshold of subscription
+	WebhookStockLow      WebhookEventType = "stock.low"
+	WebhookStockReceived WebhookEventType = "stock.received"
+)
+
+var WebhookEventTypes = []WebhookEventType{WebhookReservationExpired, WebhookStockLow, WebhookStockReceived}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryDead is set after the last failed attempt. Dead delivery is sent again only if it is retried
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+var WebhookDeliveryStatuses = []WebhookDeliveryStatus{
+	WebhookDeliveryPending, WebhookDeliveryDelivered, WebhookDeliveryDead,
+}
+
+const (
+	webhookSecretBytes  = 32
+	WebhookURLMaxLength = 2048
+)
+
+// WebhookSubscription is an url which receives events of EventTypes. Secret signs every delivery,
+// it is returned only when subscription is created.
+type WebhookSubscription struct {
+	ID         uuid.UUID          `json:"id"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+	// LowStockThreshold is available quantity at which stock.low is sent
+	LowStockThreshold uint      `json:"lowStockThreshold"`
+	Secret            string    `json:"secret,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// EventTypesOf returns event types of subscription which are caused by stock event.
+// Events committed before subscription was created are skipped.
+func (w WebhookSubscription) EventTypesOf(event StockEvent) []WebhookEventType {
+	if event.CreatedAt.Before(w.CreatedAt) {
+		return nil
+	}
+
+	var types []WebhookEventType
+
+	if event.Kind == StockEventExpired && slices.Contains(w.EventTypes, WebhookReservationExpired) {
+		types = append(types, WebhookReservationExpired)
+	}
+
+	if event.ChangeKind == StockReceive && slices.Contains(w.EventTypes, WebhookStockReceived) {
+		types = append(types, WebhookStockReceived)
+	}
+
+	if slices.Contains(w.EventTypes, WebhookStockLow) && w.fellToLowStock(event) {
+		types = append(types, WebhookStockLow)
+	}
+
+	return types
+}
+
+// fellToLowStock tells if available quantity was above threshold before event and isn't after it.
+func (w WebhookSubscription) fellToLowStock(event StockEvent) bool {
+	quantity, reserved := int64(event.StockQuantity), int64(event.ReservedQuantity)
+
+	switch event.Kind {
+	case StockEventReserved:
+		reserved -= event.Quantity
+	case StockEventReleased, StockEventExpired:
+		reserved += event.Quantity
+	case StockEventQuantityChanged:
+		quantity -= event.Quantity
+	}
+
+	threshold := int64(w.LowStockThreshold)
+
+	return quantity-reserved > threshold && int64(event.AvailableQuantity) <= threshold
+}
+
+// WebhookDelivery is a single event sent to subscription. Delivery log keeps every one of them.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id"`
+	SubscriptionID uuid.UUID             `json:"subscriptionId"`
+	EventType      WebhookEventType      `json:"eventType"`
+	EventID        int64                 `json:"eventId"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       uint                  `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt,omitempty"`
+	LastStatusCode *int                  `json:"lastStatusCode,omitempty"`
+	LastError      string                `json:"lastError,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+	// URL and Secret of subscription are set for deliveries claimed for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookMessage is the body of delivery request.
+type WebhookMessage struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Data      StockEvent       `json:"data"`
+}
+
+type GetWebhookDeliveriesParams struct {
+	SubscriptionID uuid.UUID
+	Status         WebhookDeliveryStatus
+	Limit          uint
+	Offset         uint
+}
+
+func NewWebhookSecret() (string, error) {
+	data := make([]byte, webhookSecretBytes)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("rand.Read(data): %w", err)
+	}
+
+	return hex.EncodeToString(data), nil
+}
+
+// ValidateWebhookSubscription checks url and event types of subscription. Unless allowPrivateHosts is set,
+// url can't point to loopback, private or link-local address, so webhooks can't reach internal services.
+func ValidateWebhookSubscription(subscription WebhookSubscription, allowPrivateHosts bool) error {
+	parsed, err := url.Parse(subscription.URL)
+	if err != nil || len(subscription.URL) > WebhookURLMaxLength ||
+		(parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidWebhook
+	}
+
+	if !allowPrivateHosts && isPrivateWebhookHost(parsed.Hostname()) {
+		return ErrInvalidWebhook
+	}
+
+	if len(subscription.EventTypes) == 0 {
+		return ErrInvalidWebhook
+	}
+
+	for _, eventType := range subscription.EventTypes {
+		if !slices.Contains(WebhookEventTypes, eventType) {
+			return ErrInvalidWebhook
+		}
+	}
+
+	return nil
+}
+
+// IsPublicWebhookAddr tells if webhook can be sent to address. Loopback, private, link-local
+// and unspecified addresses belong to internal network.
+func IsPublicWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsUnspecified()
+}
+
+// isPrivateWebhookHost checks host of url. Names are resolved only when delivery is sent,
+// so sender checks resolved addresses again.
+func isPrivateWebhookHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	return !IsPublicWebhookAddr(addr)
+}
+
+func ValidateGetWebhookDeliveriesParams(params GetWebhookDeliveriesParams) error {
+	if params.Limit > MaxReservationsLimit {
+		return ErrInvalidLimit
+	}
+
+	if params.Status != "" && !slices.Contains(WebhookDeliveryStatuses, params.Status) {
+		return ErrInvalidGetParams
+	}
+
+	return nil
+}
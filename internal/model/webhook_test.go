@@ -0,0 +1,48 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWebhookSubscription(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		valid bool
+	}{
+		{name: "public host", url: "https://example.com/hooks", valid: true},
+		{name: "public address", url: "http://93.184.216.34:8080", valid: true},
+		{name: "scheme", url: "ftp://example.com"},
+		{name: "no host", url: "http:///hooks"},
+		{name: "localhost", url: "http://localhost:8080"},
+		{name: "subdomain of localhost", url: "http://api.localhost."},
+		{name: "loopback", url: "http://127.0.0.1:8080"},
+		{name: "loopback v6", url: "http://[::1]/hooks"},
+		{name: "mapped loopback", url: "http://[::ffff:127.0.0.1]/hooks"},
+		{name: "metadata", url: "http://169.254.169.254/latest/meta-data"},
+		{name: "private", url: "https://10.1.2.3"},
+		{name: "private v6", url: "https://[fd00::1]"},
+		{name: "unspecified", url: "http://0.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription := WebhookSubscription{URL: tt.url, EventTypes: []WebhookEventType{WebhookStockLow}}
+
+			err := ValidateWebhookSubscription(subscription, false)
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrInvalidWebhook)
+			}
+		})
+	}
+
+	t.Run("private hosts allowed", func(t *testing.T) {
+		subscription := WebhookSubscription{URL: "http://127.0.0.1:8080", EventTypes: []WebhookEventType{WebhookStockLow}}
+
+		require.NoError(t, ValidateWebhookSubscription(subscription, true))
+	})
+}
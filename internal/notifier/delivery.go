@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderWebhookID        = "X-Webhook-ID"
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// DeliverySender posts webhook deliveries signed with secrets of their subscriptions.
+type DeliverySender struct {
+	client *http.Client
+}
+
+// NewDeliverySender creates sender with timeout of a single attempt. Unless allowPrivateHosts is set,
+// connections to loopback, private and link-local addresses are refused after names are resolved,
+// and proxy from environment isn't used, so deliveries can't reach internal services.
+func NewDeliverySender(timeout time.Duration, allowPrivateHosts bool) *DeliverySender {
+	client := &http.Client{Timeout: timeout}
+
+	if !allowPrivateHosts {
+		dialer := &net.Dialer{Timeout: timeout, Control: checkPublicAddress}
+
+		client.Transport = &http.Transport{
+			DialContext:       dialer.DialContext,
+			ForceAttemptHTTP2: true,
+			IdleConnTimeout:   time.Minute,
+		}
+	}
+
+	return &DeliverySender{client: client}
+}
+
+func checkPublicAddress(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("netip.ParseAddrPort(%s): %w", address, err)
+	}
+
+	if !model.IsPublicWebhookAddr(addrPort.Addr()) {
+		return fmt.Errorf("%s: %w", address, ErrPrivateAddress)
+	}
+
+	return nil
+}
+
+// Send posts payload of delivery to url of its subscription. Status code is returned whenever response
+// is received, responses other than 2xx are errors too.
+func (d *DeliverySender) Send(ctx context.Context, delivery model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, payload): %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookID, delivery.ID.String())
+	req.Header.Set(HeaderWebhookEvent, string(delivery.EventType))
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	req.Header.Set(HeaderWebhookSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("d.client.Do(req): %w", err)
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("Send/resp.Body.Close()")
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d: %w", resp.StatusCode, ErrUnexpectedStatus)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns signature header value of body sent at timestamp. Receiver computes HMAC-SHA256 of
+// timestamp, a dot and raw body with subscription secret and compares it with the header.
+// Timestamp is signed too, so receiver can reject replayed requests.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliverySenderPrivateAddress(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	delivery := model.WebhookDelivery{URL: receiver.URL, Payload: []byte(`{}`)}
+
+	// names resolved to private addresses are refused too, not only literal addresses
+	for _, url := range []string{receiver.URL, strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)} {
+		delivery.URL = url
+
+		statusCode, err := NewDeliverySender(time.Second, false).Send(context.Background(), delivery)
+		require.ErrorIs(t, err, ErrPrivateAddress)
+		require.Zero(t, statusCode)
+	}
+
+	statusCode, err := NewDeliverySender(time.Second, true).Send(context.Background(), delivery)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, statusCode)
+}
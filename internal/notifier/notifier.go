@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	EventReservationsExpiring = "reservations.expiring"
+
+	SinkNone    = "none"
+	SinkLog     = "log"
+	SinkWebhook = "webhook"
+	SinkOutbox  = "outbox"
+
+	webhookTimeout = 10 * time.Second
+)
+
+var (
+	ErrUnexpectedStatus = errors.New("err unexpected response status")
+	ErrUnknownSink      = errors.New("err unknown notifier sink")
+	ErrEmptyWebhookURL  = errors.New("err empty webhook url")
+	ErrPrivateAddress   = errors.New("err webhook address belongs to internal network")
+)
+
+type Sink interface {
+	NotifyExpiring(ctx context.Context, reservations []model.Reservation) error
+}
+
+type Config struct {
+	Sink       string
+	WebhookURL string
+}
+
+func New(cfg Config) (Sink, error) {
+	switch cfg.Sink {
+	case SinkLog:
+		return NewLog(), nil
+	case SinkWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, ErrEmptyWebhookURL
+		}
+
+		return NewWebhook(cfg.WebhookURL, webhookTimeout), nil
+	case SinkOutbox:
+		return NewOutbox(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSink, cfg.Sink)
+	}
+}
+
+type Message struct {
+	Event        string              `json:"event"`
+	Reservations []model.Reservation `json:"reservations"`
+}
+
+// Outbox turns notifications about expiring reservations into expiring events of stock events stream.
+// Events are written by store in the transaction claiming reservations, so Outbox sends nothing itself.
+type Outbox struct{}
+
+func NewOutbox() *Outbox {
+	return &Outbox{}
+}
+
+func (o *Outbox) NotifyExpiring(_ context.Context, _ []model.Reservation) error {
+	return nil
+}
+
+// WritesOutbox tells that notifications are written together with claim of reservations.
+func (o *Outbox) WritesOutbox() bool {
+	return true
+}
+
+// Log writes notifications about expiring reservations to the application log.
+type Log struct{}
+
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (l *Log) NotifyExpiring(_ context.Context, reservations []model.Reservation) error {
+	for _, value := range reservations {
+		zap.L().Info(
+			"reservation is about to expire",
+			zap.String("id", value.ID.String()),
+			zap.String("warehouseId", value.WarehouseID.String()),
+			zap.String("productId", value.ProductID),
+			zap.Uint("quantity", value.Quantity),
+			zap.Time("dueDate", value.DueDate))
+	}
+
+	return nil
+}
+
+// Webhook posts notifications about expiring reservations to the configured url as a single json message.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *Webhook) NotifyExpiring(ctx context.Context, reservations []model.Reservation) error {
+	body, err := json.Marshal(Message{
+		Event:        EventReservationsExpiring,
+		Reservations: reservations,
+	})
+	if err != nil {
+		return fmt.Errorf("json.Marshal(Message): %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext(ctx, http.MethodPost, h.url, body): %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("h.client.Do(req): %w", err)
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("NotifyExpiring/resp.Body.Close()")
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status %d: %w", resp.StatusCode, ErrUnexpectedStatus)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+)
+
+// sweepPeriod is how often full buckets are dropped, so memory isn't held by clients gone long ago.
+const sweepPeriod = time.Minute
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     model.RateLimit
+}
+
+// refill adds tokens gained since last update. Bucket never holds more than burst tokens.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = min(float64(b.limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*b.limit.Rate)
+	b.updatedAt = now
+}
+
+// Memory keeps buckets in process memory. Every instance of service limits clients on its own.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *Memory) Take(_ context.Context, key string, limit model.RateLimit) (time.Duration, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	value, ok := m.buckets[key]
+	if !ok {
+		value = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		m.buckets[key] = value
+	}
+
+	value.limit = limit
+	value.refill(now)
+
+	if value.tokens < 1 {
+		return time.Duration((1 - value.tokens) / limit.Rate * float64(time.Second)), nil
+	}
+
+	value.tokens--
+
+	return 0, nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepPeriod {
+		return
+	}
+
+	m.lastSweep = now
+
+	for key, value := range m.buckets {
+		if value.refill(now); value.tokens >= float64(value.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
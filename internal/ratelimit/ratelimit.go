@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	BackendNone     = "none"
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+var (
+	ErrUnknownBackend = errors.New("err unknown rate limiter backend")
+	ErrNoStore        = errors.New("err postgres rate limiter requires store")
+)
+
+// Buckets keep token buckets by key. Take returns zero if token is taken,
+// otherwise time after which the next token is available.
+type Buckets interface {
+	Take(ctx context.Context, key string, limit model.RateLimit) (time.Duration, error)
+}
+
+type store interface {
+	TakeRateLimitToken(ctx context.Context, key string, limit model.RateLimit) (time.Duration, error)
+	DeleteFullRateLimitBuckets(ctx context.Context) (int64, error)
+}
+
+type Config struct {
+	Backend string
+	// Limit applies to every route of client, which has no limit in Routes
+	Limit  model.RateLimit
+	Routes map[string]model.RateLimit
+	// MaxConcurrent limits requests of client processed at once by this instance. Zero means no limit
+	MaxConcurrent uint
+}
+
+// Limiter limits requests of every client to every route separately.
+type Limiter struct {
+	cfg     Config
+	buckets Buckets
+
+	mu       sync.Mutex
+	inFlight map[string]uint
+}
+
+// New returns nil limiter for BackendNone. Store is used only by BackendPostgres.
+func New(cfg Config, db store) (*Limiter, error) {
+	var buckets Buckets
+
+	switch cfg.Backend {
+	case BackendNone:
+		return nil, nil //nolint:nilnil
+	case BackendMemory:
+		buckets = NewMemory()
+	case BackendPostgres:
+		if db == nil {
+			return nil, ErrNoStore
+		}
+
+		buckets = NewPostgres(db)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, cfg.Backend)
+	}
+
+	return &Limiter{
+		cfg:      cfg,
+		buckets:  buckets,
+		inFlight: make(map[string]uint),
+	}, nil
+}
+
+// Acquire admits request of client to route. If request is admitted, release must be called after it is processed.
+// Otherwise, retryAfter tells when client may try again.
+func (l *Limiter) Acquire(ctx context.Context, client, route string) (release func(), retryAfter time.Duration, err error) {
+	retryAfter, err = l.Admit(ctx, client, route)
+	if err != nil {
+		return nil, 0, fmt.Errorf("l.Admit(ctx, client, route): %w", err)
+	}
+
+	if retryAfter > 0 {
+		return nil, retryAfter, nil
+	}
+
+	if l.cfg.MaxConcurrent == 0 {
+		return func() {}, 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[client] >= l.cfg.MaxConcurrent {
+		// there is no way to know when one of requests finishes, so client is asked to retry right after
+		return nil, time.Second, nil
+	}
+
+	l.inFlight[client]++
+
+	return func() { l.release(client) }, 0, nil
+}
+
+// Admit takes token of client for route without counting request as processed at once. It is meant
+// for long-lived streams, which would hold MaxConcurrent slots of client for their whole life.
+// Zero retryAfter means request is admitted.
+func (l *Limiter) Admit(ctx context.Context, client, route string) (time.Duration, error) {
+	limit, ok := l.cfg.Routes[route]
+	if !ok {
+		limit = l.cfg.Limit
+	}
+
+	if limit.Unlimited() {
+		return 0, nil
+	}
+
+	retryAfter, err := l.buckets.Take(ctx, client+" "+route, limit)
+	if err != nil {
+		return 0, fmt.Errorf("l.buckets.Take(ctx, key, limit): %w", err)
+	}
+
+	return retryAfter, nil
+}
+
+func (l *Limiter) release(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight[client]--
+
+	if l.inFlight[client] == 0 {
+		delete(l.inFlight, client)
+	}
+}
+
+// Postgres keeps buckets in database, so limits are shared by every instance of service.
+type Postgres struct {
+	db store
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgres(db store) *Postgres {
+	return &Postgres{
+		db:        db,
+		lastSweep: time.Now(),
+	}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, limit model.RateLimit) (time.Duration, error) {
+	p.sweep(ctx)
+
+	retryAfter, err := p.db.TakeRateLimitToken(ctx, key, limit)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.TakeRateLimitToken(ctx, key, limit): %w", err)
+	}
+
+	return retryAfter, nil
+}
+
+// sweep deletes full buckets every sweepPeriod, so table isn't grown by clients gone long ago.
+// Every instance sweeps on its own, failed sweep is retried in the next period.
+func (p *Postgres) sweep(ctx context.Context) {
+	p.mu.Lock()
+
+	if time.Since(p.lastSweep) < sweepPeriod {
+		p.mu.Unlock()
+
+		return
+	}
+
+	p.lastSweep = time.Now()
+	p.mu.Unlock()
+
+	deleted, err := p.db.DeleteFullRateLimitBuckets(ctx)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn("sweep/p.db.DeleteFullRateLimitBuckets(ctx)")
+
+		return
+	}
+
+	zap.L().Debug("full rate limit buckets deleted", zap.Int64("deleted", deleted))
+}
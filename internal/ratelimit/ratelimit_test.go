@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bucket of route", func(t *testing.T) {
+		limiter, err := New(Config{
+			Backend: BackendMemory,
+			Limit:   model.RateLimit{Rate: 0.1, Burst: 1},
+			Routes:  map[string]model.RateLimit{"POST /limited": {Rate: 0.5, Burst: 1}},
+		}, nil)
+		require.NoError(t, err)
+
+		release, _, err := limiter.Acquire(ctx, "client", "POST /limited")
+		require.NoError(t, err)
+		require.NotNil(t, release)
+		release()
+
+		release, retryAfter, err := limiter.Acquire(ctx, "client", "POST /limited")
+		require.NoError(t, err)
+		require.Nil(t, release)
+		require.InDelta(t, 2*time.Second, retryAfter, float64(100*time.Millisecond))
+
+		// other routes and clients have own buckets
+		release, _, err = limiter.Acquire(ctx, "client", "GET /other")
+		require.NoError(t, err)
+		require.NotNil(t, release)
+
+		release, _, err = limiter.Acquire(ctx, "another", "POST /limited")
+		require.NoError(t, err)
+		require.NotNil(t, release)
+	})
+
+	t.Run("max concurrent", func(t *testing.T) {
+		limiter, err := New(Config{Backend: BackendMemory, MaxConcurrent: 2}, nil)
+		require.NoError(t, err)
+
+		first, _, err := limiter.Acquire(ctx, "client", "GET /a")
+		require.NoError(t, err)
+		require.NotNil(t, first)
+
+		second, _, err := limiter.Acquire(ctx, "client", "GET /b")
+		require.NoError(t, err)
+		require.NotNil(t, second)
+
+		release, retryAfter, err := limiter.Acquire(ctx, "client", "GET /a")
+		require.NoError(t, err)
+		require.Nil(t, release)
+		require.Equal(t, time.Second, retryAfter)
+
+		// limit is per client
+		release, _, err = limiter.Acquire(ctx, "another", "GET /a")
+		require.NoError(t, err)
+		require.NotNil(t, release)
+		release()
+
+		first()
+
+		release, _, err = limiter.Acquire(ctx, "client", "GET /a")
+		require.NoError(t, err)
+		require.NotNil(t, release)
+
+		// streams take no slots
+		retryAfter, err = limiter.Admit(ctx, "client", "GET /stream")
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+
+		release()
+		second()
+
+		require.Empty(t, limiter.inFlight)
+	})
+
+	t.Run("backends", func(t *testing.T) {
+		limiter, err := New(Config{Backend: BackendNone}, nil)
+		require.NoError(t, err)
+		require.Nil(t, limiter)
+
+		_, err = New(Config{Backend: BackendPostgres}, nil)
+		require.ErrorIs(t, err, ErrNoStore)
+
+		_, err = New(Config{Backend: "redis"}, nil)
+		require.ErrorIs(t, err, ErrUnknownBackend)
+	})
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CreateAPIKey creates key with given scopes. Returned secret is the only copy of key, it can't be restored later.
+func (s *Service) CreateAPIKey(ctx context.Context, name string, scopes []model.Scope) (*model.APIKey, string, error) {
+	if err := model.ValidateAPIKeyRequest(name, scopes); err != nil {
+		return nil, "", fmt.Errorf("model.ValidateAPIKeyRequest(name, scopes): %w", err)
+	}
+
+	secret, err := model.NewAPIKeySecret()
+	if err != nil {
+		return nil, "", fmt.Errorf("model.NewAPIKeySecret(): %w", err)
+	}
+
+	key, err := s.db.CreateAPIKey(ctx, model.APIKey{
+		ID:     uuid.New(),
+		Name:   name,
+		Prefix: model.APIKeyPrefix(secret),
+		Scopes: scopes,
+	}, model.HashAPIKey(secret))
+	if err != nil {
+		return nil, "", fmt.Errorf("s.db.CreateAPIKey(ctx, key, hash): %w", err)
+	}
+
+	return key, secret, nil
+}
+
+// Authenticate returns client which owns api key or bearer token. Unknown and revoked keys
+// and invalid tokens result in model.ErrUnauthorized.
+func (s *Service) Authenticate(ctx context.Context, secret string) (*model.Client, error) {
+	if secret == "" {
+		return nil, model.ErrUnauthorized
+	}
+
+	if !model.IsAPIKey(secret) {
+		if s.tokens == nil {
+			return nil, model.ErrUnauthorized
+		}
+
+		client, err := s.tokens.Verify(secret)
+		if err != nil {
+			zap.L().With(zap.Error(err)).Debug("Authenticate/s.tokens.Verify(secret)")
+
+			return nil, model.ErrUnauthorized
+		}
+
+		return client, nil
+	}
+
+	key, err := s.db.GetAPIKeyByHash(ctx, model.HashAPIKey(secret))
+
+	switch {
+	case errors.Is(err, model.ErrAPIKeyNotFound):
+		return nil, model.ErrUnauthorized
+	case err != nil:
+		return nil, fmt.Errorf("s.db.GetAPIKeyByHash(ctx, hash): %w", err)
+	case key.RevokedAt != nil:
+		return nil, model.ErrUnauthorized
+	}
+
+	return key.Client(), nil
+}
+
+func (s *Service) GetAPIKeys(ctx context.Context) (*[]model.APIKey, error) {
+	keys, err := s.db.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetAPIKeys(ctx): %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	if err := s.db.RevokeAPIKey(ctx, id); err != nil {
+		return fmt.Errorf("s.db.RevokeAPIKey(ctx, id): %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const ArchiverLeaseName = "reservations-archiver"
+
+type ArchiverConfig struct {
+	Period time.Duration
+	// RetentionAge is the time reservation stays inactive before it is moved to archive.
+	RetentionAge time.Duration
+	BatchSize    uint
+	// EventsRetentionAge is the time stock events are kept for clients resuming events stream.
+	// Zero keeps events forever
+	EventsRetentionAge time.Duration
+	// DeliveriesRetentionAge is the time delivered and dead webhook deliveries are kept in delivery log.
+	// Zero keeps deliveries forever
+	DeliveriesRetentionAge time.Duration
+
+	InstanceID string
+	// LeaseTTL is the time after which another instance takes over the archiver. Defaults to 3 periods.
+	LeaseTTL time.Duration
+}
+
+func (s *Service) RunReservationsArchivation(ctx context.Context, cfg ArchiverConfig) error {
+	if cfg.LeaseTTL == 0 {
+		cfg.LeaseTTL = 3 * cfg.Period
+	}
+
+	leader := newLeaderElector(s.db, ArchiverLeaseName, cfg.InstanceID, cfg.LeaseTTL)
+	defer leader.release()
+
+	ticker := time.NewTicker(cfg.Period)
+	defer ticker.Stop()
+
+	for {
+		isLeader, err := leader.check(ctx)
+		if err != nil {
+			return fmt.Errorf("leader.check(ctx): %w", err)
+		}
+
+		if isLeader {
+			archived, err := s.db.ArchiveInactiveReservations(
+				ctx, leader.fence, time.Now().Add(-cfg.RetentionAge), cfg.BatchSize)
+
+			switch {
+			case errors.Is(err, model.ErrLeaseLost):
+				zap.L().Warn("archiver lease is lost during sweep", zap.String("holder", cfg.InstanceID))
+			case err != nil:
+				return fmt.Errorf("s.db.ArchiveInactiveReservations(ctx, leader.fence, olderThan, cfg.BatchSize): %w", err)
+			}
+
+			if archived > 0 {
+				zap.L().Info("inactive reservations archived", zap.Int64("reservations", archived))
+			}
+
+			if cfg.EventsRetentionAge > 0 {
+				deleted, err := s.db.DeleteStockEvents(ctx, time.Now().Add(-cfg.EventsRetentionAge))
+				if err != nil {
+					return fmt.Errorf("s.db.DeleteStockEvents(ctx, olderThan): %w", err)
+				}
+
+				if deleted > 0 {
+					zap.L().Info("old stock events deleted", zap.Int64("events", deleted))
+				}
+			}
+
+			if cfg.DeliveriesRetentionAge > 0 {
+				deleted, err := s.db.DeleteWebhookDeliveries(ctx, time.Now().Add(-cfg.DeliveriesRetentionAge))
+				if err != nil {
+					return fmt.Errorf("s.db.DeleteWebhookDeliveries(ctx, olderThan): %w", err)
+				}
+
+				if deleted > 0 {
+					zap.L().Info("old webhook deliveries deleted", zap.Int64("deliveries", deleted))
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// stockEventsBatchSize is amount of events read from db at once
+	stockEventsBatchSize = 1000
+	// stockEventsBuffer is amount of events waiting to be sent to subscriber.
+	// Subscriber falling behind further is dropped and has to resume with the last received event id.
+	stockEventsBuffer = 1000
+)
+
+type stockEventsSubscription struct {
+	filter model.StockEventsFilter
+	events chan model.StockEvent
+	// lagged tells events channel was closed because subscriber fell behind, not because broker stopped
+	lagged bool
+}
+
+// stockEventsBroker passes events read by the single listener of instance to every subscriber.
+type stockEventsBroker struct {
+	mu          sync.Mutex
+	subscribers map[*stockEventsSubscription]struct{}
+	// lastID is id of the last event passed to subscribers
+	lastID int64
+	// running is closed when the last event id is known and is replaced when broker stops
+	running chan struct{}
+}
+
+func newStockEventsBroker() *stockEventsBroker {
+	return &stockEventsBroker{
+		subscribers: make(map[*stockEventsSubscription]struct{}),
+		running:     make(chan struct{}),
+	}
+}
+
+func (b *stockEventsBroker) subscribe(filter model.StockEventsFilter) (*stockEventsSubscription, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscription := &stockEventsSubscription{
+		filter: filter,
+		events: make(chan model.StockEvent, stockEventsBuffer),
+	}
+
+	b.subscribers[subscription] = struct{}{}
+
+	return subscription, b.lastID
+}
+
+func (b *stockEventsBroker) unsubscribe(subscription *stockEventsSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[subscription]; ok {
+		delete(b.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+func (b *stockEventsBroker) publish(events []model.StockEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		for subscription := range b.subscribers {
+			if !subscription.filter.Match(event) {
+				continue
+			}
+
+			select {
+			case subscription.events <- event:
+			default:
+				subscription.lagged = true
+
+				delete(b.subscribers, subscription)
+				close(subscription.events)
+			}
+		}
+
+		b.lastID = event.ID
+	}
+}
+
+// stop drops every subscriber, so their streams end together with the broker.
+func (b *stockEventsBroker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscription := range b.subscribers {
+		delete(b.subscribers, subscription)
+		close(subscription.events)
+	}
+
+	b.running = make(chan struct{})
+}
+
+// RunStockEvents relays stock events from outbox, reads committed ones and passes them to subscribers
+// of this instance until ctx is done. Every instance relays, so events aren't stuck if one of them stops.
+func (s *Service) RunStockEvents(ctx context.Context) error {
+	defer s.stockEvents.stop()
+
+	lastID, err := s.db.GetLastStockEventID(ctx)
+	if err != nil {
+		return fmt.Errorf("s.db.GetLastStockEventID(ctx): %w", err)
+	}
+
+	s.stockEvents.mu.Lock()
+	s.stockEvents.lastID = lastID
+	close(s.stockEvents.running)
+	s.stockEvents.mu.Unlock()
+
+	for {
+		err = s.db.ListenStockEvents(ctx, func() {
+			if _, err := s.db.RelayStockEvents(ctx); err != nil && ctx.Err() == nil {
+				zap.L().With(zap.Error(err)).Warn("RunStockEvents/s.db.RelayStockEvents(ctx)")
+			}
+
+			if err := s.readStockEvents(ctx); err != nil && ctx.Err() == nil {
+				zap.L().With(zap.Error(err)).Warn("RunStockEvents/s.readStockEvents(ctx)")
+			}
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		zap.L().With(zap.Error(err)).Warn("RunStockEvents/s.db.ListenStockEvents(ctx, fn)")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// readStockEvents publishes every event committed after the last published one.
+func (s *Service) readStockEvents(ctx context.Context) error {
+	for {
+		s.stockEvents.mu.Lock()
+		lastID := s.stockEvents.lastID
+		s.stockEvents.mu.Unlock()
+
+		events, err := s.db.GetStockEvents(ctx, lastID, model.StockEventsFilter{}, stockEventsBatchSize)
+		if err != nil {
+			return fmt.Errorf("s.db.GetStockEvents(ctx, lastID, filter, stockEventsBatchSize): %w", err)
+		}
+
+		s.stockEvents.publish(*events)
+
+		if len(*events) < stockEventsBatchSize {
+			return nil
+		}
+	}
+}
+
+// StreamStockEvents calls fn with every stock event matching filter as it is committed, until ctx is done or fn
+// fails. If afterID is set, events committed after it are passed first, as long as they are kept in db.
+// model.ErrStockEventsLagged is returned if fn can't keep up with events.
+func (s *Service) StreamStockEvents(
+	ctx context.Context,
+	filter model.StockEventsFilter,
+	afterID *int64,
+	fn func(event model.StockEvent) error,
+) error {
+	if err := model.ValidateStockEventsFilter(filter); err != nil {
+		return fmt.Errorf("model.ValidateStockEventsFilter(filter): %w", err)
+	}
+
+	s.stockEvents.mu.Lock()
+	running := s.stockEvents.running
+	s.stockEvents.mu.Unlock()
+
+	// subscription must start from the known last event, which is read by the broker on start
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-running:
+	}
+
+	subscription, lastID := s.stockEvents.subscribe(filter)
+	defer s.stockEvents.unsubscribe(subscription)
+
+	if afterID != nil && *afterID < lastID {
+		if err := s.replayStockEvents(ctx, filter, *afterID, lastID, fn); err != nil {
+			return fmt.Errorf("s.replayStockEvents(ctx, filter, afterID, lastID, fn): %w", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-subscription.events:
+			if !ok && subscription.lagged {
+				return model.ErrStockEventsLagged
+			}
+
+			if !ok {
+				return nil
+			}
+
+			if err := fn(event); err != nil {
+				return fmt.Errorf("fn(event): %w", err)
+			}
+		}
+	}
+}
+
+// replayStockEvents passes events with ids in (afterID, untilID] matching filter to fn. These events
+// were published before subscription started, so they are read from db.
+func (s *Service) replayStockEvents(
+	ctx context.Context,
+	filter model.StockEventsFilter,
+	afterID, untilID int64,
+	fn func(event model.StockEvent) error,
+) error {
+	for afterID < untilID {
+		events, err := s.db.GetStockEvents(ctx, afterID, filter, stockEventsBatchSize)
+		if err != nil {
+			return fmt.Errorf("s.db.GetStockEvents(ctx, afterID, filter, stockEventsBatchSize): %w", err)
+		}
+
+		for _, event := range *events {
+			if event.ID > untilID {
+				return nil
+			}
+
+			if err = fn(event); err != nil {
+				return fmt.Errorf("fn(event): %w", err)
+			}
+
+			afterID = event.ID
+		}
+
+		if len(*events) < stockEventsBatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+)
+
+// workerHeartbeat tracks that worker loop keeps running. Worker beats on every iteration
+// and is considered stalled if the next beat is late for more than maxAge.
+type workerHeartbeat struct {
+	mu      sync.Mutex
+	running bool
+	last    time.Time
+	maxAge  time.Duration
+}
+
+func (h *workerHeartbeat) beat(maxAge time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.running, h.last, h.maxAge = true, time.Now(), maxAge
+}
+
+func (h *workerHeartbeat) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.running = false
+}
+
+func (h *workerHeartbeat) check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case !h.running:
+		return model.ErrWorkerNotRunning
+	case time.Since(h.last) > h.maxAge:
+		return fmt.Errorf("last beat %s ago: %w", time.Since(h.last).Round(time.Second), model.ErrWorkerStalled)
+	}
+
+	return nil
+}
+
+// CheckReadiness checks db connection, that every migration is applied and that deactivator of this instance runs.
+func (s *Service) CheckReadiness(ctx context.Context) model.Readiness {
+	var readiness model.Readiness
+
+	readiness.AddCheck("postgres", s.db.Ping(ctx))
+	readiness.AddCheck("migrations", s.checkMigrations(ctx))
+	readiness.AddCheck("deactivator", s.deactivator.check())
+
+	return readiness
+}
+
+func (s *Service) checkMigrations(ctx context.Context) error {
+	pending, err := s.db.GetPendingMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("s.db.GetPendingMigrations(ctx): %w", err)
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending, the first is %s: %w", len(pending), pending[0], model.ErrPendingMigrations)
+	}
+
+	return nil
+}
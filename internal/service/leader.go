@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	DeactivatorLeaseName = "reservations-deactivator"
+
+	leaseReleaseTimeout = 5 * time.Second
+)
+
+// leaderElector lets only one service instance run a worker at a time.
+// Leadership is kept in a lease, which is renewed on every check and taken over by another instance after ttl.
+// Sweep may outlast ttl, so writes of the sweep are fenced: they pass fence of the last check
+// and are rejected with ErrLeaseLost once lease has changed hands.
+type leaderElector struct {
+	db       store
+	name     string
+	holder   string
+	ttl      time.Duration
+	isLeader bool
+	// fence is issued by the last successful check, it is nil while instance isn't a leader
+	fence *model.LeaseFence
+}
+
+func newLeaderElector(db store, name, holder string, ttl time.Duration) *leaderElector {
+	return &leaderElector{
+		db:     db,
+		name:   name,
+		holder: holder,
+		ttl:    ttl,
+	}
+}
+
+func (l *leaderElector) check(ctx context.Context) (bool, error) {
+	fence, err := l.db.AcquireLease(ctx, l.name, l.holder, l.ttl)
+	if err != nil {
+		return false, fmt.Errorf("l.db.AcquireLease(ctx, l.name, l.holder, l.ttl): %w", err)
+	}
+
+	isLeader := fence != nil
+
+	if isLeader != l.isLeader {
+		zap.L().Info(
+			"worker leadership changed",
+			zap.String("lease", l.name),
+			zap.String("holder", l.holder),
+			zap.Bool("isLeader", isLeader))
+	}
+
+	l.isLeader, l.fence = isLeader, fence
+
+	return isLeader, nil
+}
+
+// release gives up the lease, so other instances don't have to wait for it to expire.
+func (l *leaderElector) release() {
+	if !l.isLeader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaseReleaseTimeout)
+	defer cancel()
+
+	if err := l.db.ReleaseLease(ctx, l.name, l.holder); err != nil {
+		zap.L().With(zap.Error(err)).Warn("release/l.db.ReleaseLease(ctx, l.name, l.holder)")
+
+		return
+	}
+
+	l.isLeader, l.fence = false, nil
+}
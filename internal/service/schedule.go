@@ -0,0 +1,110 @@
+package service
+
+import (
+	"container/heap"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	scheduledDueDatesLimit = 100
+
+	// dueDateSlack is added to due date, so reservation is already due when deactivation starts.
+	dueDateSlack = 10 * time.Millisecond
+	// minDeactivationDelay prevents busy loop when clocks of service and db differ.
+	minDeactivationDelay = 100 * time.Millisecond
+	// maxStalledShift limits growth of the shortest delay of stalled sweeps, further growth is capped by period.
+	maxStalledShift = 10
+)
+
+type dueDates []time.Time
+
+func (d dueDates) Len() int           { return len(d) }
+func (d dueDates) Less(i, j int) bool { return d[i].Before(d[j]) }
+func (d dueDates) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func (d *dueDates) Push(x any) {
+	//nolint: forcetypeassert
+	*d = append(*d, x.(time.Time))
+}
+
+func (d *dueDates) Pop() any {
+	old := *d
+	n := len(old)
+	x := old[n-1]
+	*d = old[:n-1]
+
+	return x
+}
+
+// dueSchedule keeps upcoming due dates of active reservations in a min-heap
+// and signals wake when a new reservation becomes due earlier than all known ones.
+type dueSchedule struct {
+	mu    sync.Mutex
+	dates dueDates
+	wake  chan struct{}
+	// stalled counts sweeps in a row which left already due reservations active
+	stalled uint
+}
+
+func newDueSchedule() *dueSchedule {
+	return &dueSchedule{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (d *dueSchedule) push(date time.Time) {
+	d.mu.Lock()
+	isEarliest := len(d.dates) == 0 || date.Before(d.dates[0])
+	heap.Push(&d.dates, date)
+	d.mu.Unlock()
+
+	if !isEarliest {
+		return
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dueSchedule) reset(dates []time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.dates = slices.Clone(dates)
+	heap.Init(&d.dates)
+}
+
+// delay returns time left until the earliest due date, but not more than maxDelay.
+func (d *dueSchedule) delay(maxDelay time.Duration) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.dates) == 0 {
+		return maxDelay
+	}
+
+	delay := time.Until(d.dates[0]) + dueDateSlack
+	minDelay := minDeactivationDelay << min(d.stalled, maxStalledShift)
+
+	return min(max(delay, minDelay), maxDelay)
+}
+
+// sweepDone records result of a sweep. Sweep which deactivated nothing while the earliest due date
+// has already passed, e.g. because due reservations are locked by another transaction, doubles
+// the shortest delay, so such reservations aren't polled every minDeactivationDelay.
+func (d *dueSchedule) sweepDone(deactivated int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if deactivated > 0 || len(d.dates) == 0 || time.Now().Before(d.dates[0]) {
+		d.stalled = 0
+
+		return
+	}
+
+	d.stalled++
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDueSchedule(t *testing.T) {
+	t.Run("delay of empty schedule", func(t *testing.T) {
+		schedule := newDueSchedule()
+
+		require.Equal(t, time.Minute, schedule.delay(time.Minute))
+	})
+
+	t.Run("delay until the earliest date", func(t *testing.T) {
+		schedule := newDueSchedule()
+		now := time.Now()
+
+		schedule.reset([]time.Time{now.Add(time.Hour), now.Add(10 * time.Second), now.Add(time.Minute)})
+		schedule.push(now.Add(30 * time.Second))
+
+		delay := schedule.delay(time.Hour)
+		require.LessOrEqual(t, delay, 10*time.Second+dueDateSlack)
+		require.Greater(t, delay, 9*time.Second)
+
+		// period caps delay
+		require.Equal(t, time.Second, schedule.delay(time.Second))
+	})
+
+	t.Run("passed date is polled after min delay", func(t *testing.T) {
+		schedule := newDueSchedule()
+
+		schedule.reset([]time.Time{time.Now().Add(-time.Second)})
+
+		require.Equal(t, minDeactivationDelay, schedule.delay(time.Minute))
+	})
+
+	t.Run("wake on the earliest date only", func(t *testing.T) {
+		schedule := newDueSchedule()
+		now := time.Now()
+
+		schedule.reset([]time.Time{now.Add(time.Minute)})
+
+		schedule.push(now.Add(time.Hour))
+		require.Empty(t, schedule.wake)
+
+		schedule.push(now.Add(time.Second))
+		require.Len(t, schedule.wake, 1)
+
+		// wake isn't blocked by signal which hasn't been taken yet
+		schedule.push(now.Add(time.Millisecond))
+		require.Len(t, schedule.wake, 1)
+	})
+
+	t.Run("reset drops pushed dates", func(t *testing.T) {
+		schedule := newDueSchedule()
+
+		schedule.push(time.Now().Add(time.Second))
+		schedule.reset(nil)
+
+		require.Equal(t, time.Minute, schedule.delay(time.Minute))
+	})
+
+	t.Run("stalled sweeps back off", func(t *testing.T) {
+		schedule := newDueSchedule()
+
+		schedule.reset([]time.Time{time.Now().Add(-time.Second)})
+
+		schedule.sweepDone(0)
+		require.Equal(t, 2*minDeactivationDelay, schedule.delay(time.Minute))
+
+		schedule.sweepDone(0)
+		require.Equal(t, 4*minDeactivationDelay, schedule.delay(time.Minute))
+
+		for i := 0; i < 2*maxStalledShift; i++ {
+			schedule.sweepDone(0)
+		}
+
+		require.Equal(t, minDeactivationDelay<<maxStalledShift, schedule.delay(time.Hour))
+		require.Equal(t, time.Minute, schedule.delay(time.Minute))
+
+		// progress restores the shortest delay
+		schedule.sweepDone(1)
+		require.Equal(t, minDeactivationDelay, schedule.delay(time.Minute))
+	})
+
+	t.Run("future date doesn't stall", func(t *testing.T) {
+		schedule := newDueSchedule()
+
+		schedule.reset([]time.Time{time.Now().Add(-time.Second)})
+		schedule.sweepDone(0)
+
+		schedule.reset([]time.Time{time.Now().Add(time.Hour)})
+		schedule.sweepDone(0)
+
+		schedule.reset([]time.Time{time.Now().Add(-time.Second)})
+		require.Equal(t, minDeactivationDelay, schedule.delay(time.Minute))
+	})
+}
@@ -2,35 +2,175 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/Saaghh/lamoda-hr/internal/model"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type store interface {
 	CreateReservations(ctx context.Context, reservations []model.Reservation) (*[]model.Reservation, error)
 	DeleteReservations(ctx context.Context, reservations []model.Reservation) error
-	GetStocks(ctx context.Context, params model.GetParams) (*[]model.Stock, error)
+	GetStocks(ctx context.Context, params model.GetParams, after *model.StocksCursor) (*[]model.Stock, error)
+	CountStocks(ctx context.Context, params model.GetParams, mode model.TotalMode) (int64, error)
+	GetAvailability(ctx context.Context, params model.AvailabilityParams) (*[]model.ProductAvailability, error)
 
-	DeactivateDueReservations(ctx context.Context) error
+	SetOverbookingAllowance(ctx context.Context, allowance model.OverbookingAllowance) error
+	SetWarehouseRegion(ctx context.Context, region model.WarehouseRegion) error
+	GetOverbookedStocks(ctx context.Context, params model.GetParams) (*[]model.OverbookedStock, error)
+	CountOverbookedStocks(ctx context.Context, params model.GetParams, mode model.TotalMode) (int64, error)
+
+	DeactivateDueReservations(
+		ctx context.Context,
+		fence *model.LeaseFence,
+		asOf *time.Time,
+		batchSize uint,
+	) (*model.DeactivationStats, error)
+	PreviewDueReservations(ctx context.Context, asOf *time.Time, limit uint) (*model.DeactivationResult, error)
+	ClaimExpiringReservations(
+		ctx context.Context,
+		before time.Time,
+		limit uint,
+		writeEvents bool,
+	) (*[]model.Reservation, error)
+
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (*model.LeaseFence, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+	GetLeases(ctx context.Context) (*[]model.Lease, error)
+
+	ArchiveInactiveReservations(
+		ctx context.Context,
+		fence *model.LeaseFence,
+		olderThan time.Time,
+		batchSize uint,
+	) (int64, error)
+	GetReservations(ctx context.Context, params model.GetReservationsParams) (*[]model.Reservation, error)
+	CountReservations(ctx context.Context, params model.GetReservationsParams, mode model.TotalMode) (int64, error)
+	UpdateReservationDueDate(ctx context.Context, id uuid.UUID, dueDate time.Time) (*model.Reservation, error)
+	GetStock(ctx context.Context, warehouseID uuid.UUID, productID string) (*model.Stock, error)
+	ExportStocks(ctx context.Context, params model.GetParams, fn func(stock model.Stock) error) error
+	ChangeStock(ctx context.Context, change model.StockChange) (*model.Stock, error)
+
+	GetStockEvents(
+		ctx context.Context,
+		afterID int64,
+		filter model.StockEventsFilter,
+		limit uint,
+	) (*[]model.StockEvent, error)
+	GetLastStockEventID(ctx context.Context) (int64, error)
+	ListenStockEvents(ctx context.Context, fn func()) error
+	RelayStockEvents(ctx context.Context) (int64, error)
+	DeleteStockEvents(ctx context.Context, olderThan time.Time) (int64, error)
+
+	CreateWebhookSubscription(
+		ctx context.Context,
+		subscription model.WebhookSubscription,
+	) (*model.WebhookSubscription, error)
+	GetWebhookSubscriptions(ctx context.Context) (*[]model.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error
+	GetWebhookCursor(ctx context.Context) (int64, error)
+	CreateWebhookDeliveries(
+		ctx context.Context,
+		deliveries []model.WebhookDelivery,
+		prevEventID int64,
+		lastEventID int64,
+	) error
+	DeleteWebhookDeliveries(ctx context.Context, olderThan time.Time) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit uint, leaseUntil time.Time) (*[]model.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery model.WebhookDelivery) error
+	GetWebhookDeliveries(
+		ctx context.Context,
+		params model.GetWebhookDeliveriesParams,
+	) (*[]model.WebhookDelivery, error)
+	RetryWebhookDelivery(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error)
+
+	CreateAPIKey(ctx context.Context, key model.APIKey, hash []byte) (*model.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash []byte) (*model.APIKey, error)
+	GetAPIKeys(ctx context.Context) (*[]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+
+	GetUpcomingDueDates(ctx context.Context, limit uint) ([]time.Time, error)
+	ListenDueDates(ctx context.Context, fn func(dueDate time.Time)) error
+
+	Ping(ctx context.Context) error
+	GetPendingMigrations(ctx context.Context) ([]string, error)
+}
+
+// tokenVerifier checks bearer tokens issued by identity provider.
+type tokenVerifier interface {
+	Verify(token string) (*model.Client, error)
+}
+
+type notifier interface {
+	NotifyExpiring(ctx context.Context, reservations []model.Reservation) error
+}
+
+// outboxNotifier is a notifier whose notifications are written to stock events outbox
+// in the transaction claiming reservations.
+type outboxNotifier interface {
+	WritesOutbox() bool
+}
+
+const (
+	expiringReservationsBatchSize = 100
+
+	listenRetryDelay = 5 * time.Second
+)
+
+type DeactivatorConfig struct {
+	// Period is the longest time between deactivations. Usually deactivation starts right at the earliest due date.
+	Period time.Duration
+	// BatchSize is the number of reservations deactivated in a single transaction. It must be positive.
+	BatchSize uint
+
+	// InstanceID identifies this service instance in the deactivator lease.
+	InstanceID string
+	// LeaseTTL is the time after which another instance takes over the deactivator. Defaults to 3 periods.
+	LeaseTTL time.Duration
 }
 
 type Service struct {
 	db store
+
+	dueDates    *dueSchedule
+	tokens      tokenVerifier
+	stockEvents *stockEventsBroker
+	deactivator *workerHeartbeat
+
+	allowPrivateWebhookHosts bool
 }
 
 func New(db store) *Service {
 	return &Service{
-		db: db,
+		db:          db,
+		dueDates:    newDueSchedule(),
+		stockEvents: newStockEventsBroker(),
+		deactivator: &workerHeartbeat{},
 	}
 }
 
+// SetTokenVerifier enables authentication with bearer tokens. Without verifier only api keys are accepted.
+func (s *Service) SetTokenVerifier(tokens tokenVerifier) {
+	s.tokens = tokens
+}
+
+// AllowPrivateWebhookHosts lets webhooks be sent to loopback, private and link-local addresses.
+func (s *Service) AllowPrivateWebhookHosts(allow bool) {
+	s.allowPrivateWebhookHosts = allow
+}
+
 func (s *Service) CreateReservations(ctx context.Context, reservations []model.Reservation) (*[]model.Reservation, error) {
-	for _, value := range reservations {
+	for i, value := range reservations {
 		if err := model.ValidateReservationRequest(value); err != nil {
-			return nil, fmt.Errorf("model.ValidateReservationRequest(value): %w", err)
+			return nil, fmt.Errorf("model.ValidateReservationRequest(value): %w", &model.ItemError{Index: i, Err: err})
+		}
+
+		if err := model.CheckWarehouseAccess(ctx, value.WarehouseID); err != nil {
+			return nil, fmt.Errorf("model.CheckWarehouseAccess(ctx, value.WarehouseID): %w", &model.ItemError{Index: i, Err: err})
 		}
 	}
 
@@ -43,12 +183,16 @@ func (s *Service) CreateReservations(ctx context.Context, reservations []model.R
 }
 
 func (s *Service) DeleteReservations(ctx context.Context, reservations []model.Reservation) error {
-	for _, value := range reservations {
+	for i, value := range reservations {
 		if value.ID == uuid.Nil {
-			return model.ErrInvalidUUID
+			return &model.ItemError{Index: i, Err: model.ErrInvalidUUID}
 		}
 	}
 
+	if err := s.checkReservationsAccess(ctx, reservations); err != nil {
+		return fmt.Errorf("s.checkReservationsAccess(ctx, reservations): %w", err)
+	}
+
 	err := s.db.DeleteReservations(ctx, reservations)
 	if err != nil {
 		return fmt.Errorf("s.db.DeleteReservations(ctx, reservations): %w", err)
@@ -57,30 +201,476 @@ func (s *Service) DeleteReservations(ctx context.Context, reservations []model.R
 	return nil
 }
 
-func (s *Service) GetStocks(ctx context.Context, params model.GetParams) (*[]model.Stock, error) {
+// checkReservationsAccess checks that client of ctx may act on warehouses of existing reservations.
+// Missing reservations are skipped, they are reported by the following action.
+func (s *Service) checkReservationsAccess(ctx context.Context, reservations []model.Reservation) error {
+	client, ok := model.ClientFromContext(ctx)
+	if !ok || client.Warehouses == nil {
+		return nil
+	}
+
+	params := model.GetReservationsParams{Limit: uint(len(reservations))}
+
+	for _, value := range reservations {
+		params.IDs = append(params.IDs, value.ID)
+	}
+
+	existing, err := s.db.GetReservations(ctx, params)
+	if err != nil {
+		return fmt.Errorf("s.db.GetReservations(ctx, params): %w", err)
+	}
+
+	for _, value := range *existing {
+		if err = model.CheckWarehouseAccess(ctx, value.WarehouseID); err != nil {
+			index := slices.IndexFunc(reservations, func(r model.Reservation) bool { return r.ID == value.ID })
+
+			return &model.ItemError{Index: index, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) GetReservations(
+	ctx context.Context,
+	params model.GetReservationsParams,
+) (*model.Page[model.Reservation], error) {
+	if params.Limit == 0 {
+		params.Limit = 10
+	}
+
+	if err := model.ValidateGetReservationsParams(params); err != nil {
+		return nil, fmt.Errorf("model.ValidateGetReservationsParams(params): %w", err)
+	}
+
+	page := &model.Page[model.Reservation]{Limit: params.Limit, Offset: params.Offset}
+
+	// one extra reservation shows if there is a next page
+	params.Limit++
+
+	reservations, err := s.db.GetReservations(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetReservations(ctx, params): %w", err)
+	}
+
+	page.Items, page.HasMore = trimPage(*reservations, page.Limit)
+
+	if params.Total != model.TotalNone {
+		total, err := s.db.CountReservations(ctx, params, params.Total)
+		if err != nil {
+			return nil, fmt.Errorf("s.db.CountReservations(ctx, params, params.Total): %w", err)
+		}
+
+		page.Total, page.TotalIsApproximate = &total, params.Total == model.TotalApproximate
+	}
+
+	return page, nil
+}
+
+// ExportStocks passes every stock matching params filters to fn. Stocks are a consistent snapshot,
+// pagination params are ignored.
+func (s *Service) ExportStocks(ctx context.Context, params model.GetParams, fn func(stock model.Stock) error) error {
+	if err := model.ValidateGetParams(params); err != nil {
+		return fmt.Errorf("model.ValidateGetParams(params): %w", err)
+	}
+
+	if err := s.db.ExportStocks(ctx, params, fn); err != nil {
+		return fmt.Errorf("s.db.ExportStocks(ctx, params, fn): %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) GetReservation(ctx context.Context, id uuid.UUID, includeArchived bool) (*model.Reservation, error) {
+	reservations, err := s.db.GetReservations(ctx, model.GetReservationsParams{
+		Limit:           1,
+		IDs:             []uuid.UUID{id},
+		IncludeArchived: includeArchived,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetReservations(ctx, params): %w", err)
+	}
+
+	if len(*reservations) == 0 {
+		return nil, &model.ReservationNotFoundError{ReservationID: id}
+	}
+
+	return &(*reservations)[0], nil
+}
+
+func (s *Service) UpdateReservation(
+	ctx context.Context,
+	id uuid.UUID,
+	update model.ReservationUpdate,
+) (*model.Reservation, error) {
+	if err := model.ValidateReservationUpdate(update); err != nil {
+		return nil, fmt.Errorf("model.ValidateReservationUpdate(update): %w", err)
+	}
+
+	if err := s.checkReservationsAccess(ctx, []model.Reservation{{ID: id}}); err != nil {
+		return nil, fmt.Errorf("s.checkReservationsAccess(ctx, id): %w", err)
+	}
+
+	reservation, err := s.db.UpdateReservationDueDate(ctx, id, update.DueDate)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.UpdateReservationDueDate(ctx, id, update.DueDate): %w", err)
+	}
+
+	return reservation, nil
+}
+
+func (s *Service) GetStock(ctx context.Context, warehouseID uuid.UUID, productID string) (*model.Stock, error) {
+	if len(productID) > model.SKUMaxLength || productID == "" {
+		return nil, model.ErrInvalidSKU
+	}
+
+	stock, err := s.db.GetStock(ctx, warehouseID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetStock(ctx, warehouseID, productID): %w", err)
+	}
+
+	return stock, nil
+}
+
+// GetStocks returns single page of stocks. Page is either chosen by params.Offset or
+// starts right after params.Cursor returned with previous page.
+func (s *Service) GetStocks(ctx context.Context, params model.GetParams) (*model.Page[model.Stock], error) {
 	if params.Limit == 0 {
 		params.Limit = 10
 	}
 
 	if err := model.ValidateGetParams(params); err != nil {
-		return nil, fmt.Errorf("m odel.ValidateGetParams(params): %w", err)
+		return nil, fmt.Errorf("model.ValidateGetParams(params): %w", err)
 	}
 
-	stocks, err := s.db.GetStocks(ctx, params)
+	var after *model.StocksCursor
+
+	if params.Cursor != "" {
+		cursor, err := model.DecodeStocksCursor(params.Cursor, params)
+		if err != nil {
+			return nil, fmt.Errorf("model.DecodeStocksCursor(params.Cursor, params): %w", err)
+		}
+
+		after = cursor
+	}
+
+	page := &model.Page[model.Stock]{Limit: params.Limit, Offset: params.Offset}
+
+	// one extra stock shows if there is a next page
+	params.Limit++
+
+	stocks, err := s.db.GetStocks(ctx, params, after)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetStocks(ctx, params, after): %w", err)
+	}
+
+	page.Items, page.HasMore = trimPage(*stocks, page.Limit)
+
+	if page.HasMore {
+		if cursor, ok := model.NewStocksCursor(params, page.Items[len(page.Items)-1]); ok {
+			page.NextCursor = cursor.Encode()
+		}
+	}
+
+	if params.Total != model.TotalNone {
+		total, err := s.db.CountStocks(ctx, params, params.Total)
+		if err != nil {
+			return nil, fmt.Errorf("s.db.CountStocks(ctx, params, params.Total): %w", err)
+		}
+
+		page.Total, page.TotalIsApproximate = &total, params.Total == model.TotalApproximate
+	}
+
+	return page, nil
+}
+
+func (s *Service) GetAvailability(
+	ctx context.Context,
+	params model.AvailabilityParams,
+) (*[]model.ProductAvailability, error) {
+	if err := model.ValidateAvailabilityParams(params); err != nil {
+		return nil, fmt.Errorf("model.ValidateAvailabilityParams(params): %w", err)
+	}
+
+	availability, err := s.db.GetAvailability(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetAvailability(ctx, params): %w", err)
+	}
+
+	return availability, nil
+}
+
+// ChangeStock applies change to stock quantity. Change with ExpectedVersion is rejected
+// with StockVersionConflictError if stock was changed by someone else in the meantime.
+func (s *Service) ChangeStock(ctx context.Context, change model.StockChange) (*model.Stock, error) {
+	if err := model.ValidateStockChange(change); err != nil {
+		return nil, fmt.Errorf("model.ValidateStockChange(change): %w", err)
+	}
+
+	if err := model.CheckWarehouseAccess(ctx, change.WarehouseID); err != nil {
+		return nil, fmt.Errorf("model.CheckWarehouseAccess(ctx, change.WarehouseID): %w", err)
+	}
+
+	stock, err := s.db.ChangeStock(ctx, change)
 	if err != nil {
-		return nil, fmt.Errorf("s.db.GetWarehouseStocks(ctx, warehouseID): %w", err)
+		return nil, fmt.Errorf("s.db.ChangeStock(ctx, change): %w", err)
 	}
 
-	return stocks, nil
+	return stock, nil
 }
 
-func (s *Service) RunReservationsDeactivations(ctx context.Context, duration time.Duration) error {
-	ticker := time.NewTicker(duration)
+func (s *Service) SetOverbookingAllowance(ctx context.Context, allowance model.OverbookingAllowance) error {
+	if err := model.ValidateOverbookingAllowance(allowance); err != nil {
+		return fmt.Errorf("model.ValidateOverbookingAllowance(allowance): %w", err)
+	}
+
+	// product allowance is applied at every warehouse, so it is checked against uuid.Nil,
+	// which is allowed only to clients without warehouses restriction
+	if err := model.CheckWarehouseAccess(ctx, allowance.WarehouseID); err != nil {
+		return fmt.Errorf("model.CheckWarehouseAccess(ctx, allowance.WarehouseID): %w", err)
+	}
+
+	if err := s.db.SetOverbookingAllowance(ctx, allowance); err != nil {
+		return fmt.Errorf("s.db.SetOverbookingAllowance(ctx, allowance): %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) SetWarehouseRegion(ctx context.Context, region model.WarehouseRegion) error {
+	if err := model.ValidateWarehouseRegion(region); err != nil {
+		return fmt.Errorf("model.ValidateWarehouseRegion(region): %w", err)
+	}
+
+	if err := model.CheckWarehouseAccess(ctx, region.WarehouseID); err != nil {
+		return fmt.Errorf("model.CheckWarehouseAccess(ctx, region.WarehouseID): %w", err)
+	}
+
+	if err := s.db.SetWarehouseRegion(ctx, region); err != nil {
+		return fmt.Errorf("s.db.SetWarehouseRegion(ctx, region): %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) GetOverbookedStocks(
+	ctx context.Context,
+	params model.GetParams,
+) (*model.Page[model.OverbookedStock], error) {
+	if params.Limit == 0 {
+		params.Limit = 10
+	}
+
+	// overbooked stocks are ordered by overbooked quantity, so only offset pagination is supported.
+	// They are filtered only by single warehouse and product
+	if params.Cursor != "" || params.HasExtendedFilters() {
+		return nil, model.ErrInvalidGetParams
+	}
+
+	if err := model.ValidateGetParams(params); err != nil {
+		return nil, fmt.Errorf("model.ValidateGetParams(params): %w", err)
+	}
+
+	page := &model.Page[model.OverbookedStock]{Limit: params.Limit, Offset: params.Offset}
+
+	// one extra stock shows if there is a next page
+	params.Limit++
+
+	stocks, err := s.db.GetOverbookedStocks(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetOverbookedStocks(ctx, params): %w", err)
+	}
+
+	page.Items, page.HasMore = trimPage(*stocks, page.Limit)
+
+	if params.Total != model.TotalNone {
+		total, err := s.db.CountOverbookedStocks(ctx, params, params.Total)
+		if err != nil {
+			return nil, fmt.Errorf("s.db.CountOverbookedStocks(ctx, params, params.Total): %w", err)
+		}
+
+		page.Total, page.TotalIsApproximate = &total, params.Total == model.TotalApproximate
+	}
+
+	return page, nil
+}
+
+// trimPage drops extra item requested to find out if there is a next page.
+func trimPage[T any](items []T, limit uint) ([]T, bool) {
+	if uint(len(items)) > limit {
+		return items[:limit], true
+	}
+
+	return items, false
+}
+
+func (s *Service) GetLeases(ctx context.Context) (*[]model.Lease, error) {
+	leases, err := s.db.GetLeases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetLeases(ctx): %w", err)
+	}
+
+	return leases, nil
+}
+
+func (s *Service) RunReservationsDeactivations(ctx context.Context, cfg DeactivatorConfig) error {
+	// zero batch would deactivate nothing while the worker looks healthy
+	if cfg.BatchSize == 0 {
+		return fmt.Errorf("deactivator: %w", model.ErrInvalidBatchSize)
+	}
+
+	if cfg.LeaseTTL == 0 {
+		cfg.LeaseTTL = 3 * cfg.Period
+	}
+
+	leader := newLeaderElector(s.db, DeactivatorLeaseName, cfg.InstanceID, cfg.LeaseTTL)
+	defer leader.release()
+
+	go s.listenDueDates(ctx)
+
+	defer s.deactivator.stop()
+
+	for {
+		// worker stalled for longer than lease ttl would have lost its lease already
+		s.deactivator.beat(cfg.Period + cfg.LeaseTTL)
+
+		isLeader, err := leader.check(ctx)
+		if err != nil {
+			return fmt.Errorf("leader.check(ctx): %w", err)
+		}
+
+		delay := cfg.Period
+
+		// wake is left nil for followers, so new reservations don't make them renew lease all the time
+		var wake chan struct{}
+
+		if isLeader {
+			err = s.deactivateDueReservations(ctx, leader.fence, cfg.BatchSize)
+
+			switch {
+			case errors.Is(err, model.ErrLeaseLost):
+				// another instance has taken over while sweep was running, it is found out on the next check
+				zap.L().Warn("deactivator lease is lost during sweep", zap.String("holder", cfg.InstanceID))
+			case err != nil:
+				return fmt.Errorf("s.deactivateDueReservations(ctx, leader.fence, cfg.BatchSize): %w", err)
+			}
+
+			delay = s.dueDates.delay(cfg.Period)
+			wake = s.dueDates.wake
+		} else {
+			s.dueDates.reset(nil)
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil
+		case <-timer.C:
+		case <-wake:
+			timer.Stop()
+		}
+	}
+}
+
+func (s *Service) deactivateDueReservations(ctx context.Context, fence *model.LeaseFence, batchSize uint) error {
+	stats, err := s.db.DeactivateDueReservations(ctx, fence, nil, batchSize)
+	if err != nil {
+		return fmt.Errorf("s.db.DeactivateDueReservations(ctx, fence, nil, batchSize): %w", err)
+	}
+
+	logDeactivationStats(stats)
+
+	dueDates, err := s.db.GetUpcomingDueDates(ctx, scheduledDueDatesLimit)
+	if err != nil {
+		return fmt.Errorf("s.db.GetUpcomingDueDates(ctx, scheduledDueDatesLimit): %w", err)
+	}
+
+	s.dueDates.reset(dueDates)
+	s.dueDates.sweepDone(stats.Reservations)
+
+	return nil
+}
+
+func (s *Service) listenDueDates(ctx context.Context) {
+	for {
+		err := s.db.ListenDueDates(ctx, s.dueDates.push)
+		if ctx.Err() != nil {
+			return
+		}
+
+		zap.L().With(zap.Error(err)).Warn("listenDueDates/s.db.ListenDueDates(ctx, s.dueDates.push)")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// ForceDeactivation deactivates due reservations right away, regardless of which instance runs the deactivator.
+func (s *Service) ForceDeactivation(ctx context.Context, request model.DeactivationRequest) (*model.DeactivationResult, error) {
+	if err := model.ValidateDeactivationRequest(request); err != nil {
+		return nil, fmt.Errorf("model.ValidateDeactivationRequest(request): %w", err)
+	}
+
+	if request.DryRun {
+		if request.Limit == 0 {
+			request.Limit = model.DefaultPreviewLimit
+		}
+
+		result, err := s.db.PreviewDueReservations(ctx, request.AsOf, request.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("s.db.PreviewDueReservations(ctx, request.AsOf, request.Limit): %w", err)
+		}
+
+		return result, nil
+	}
+
+	if request.BatchSize == 0 {
+		request.BatchSize = model.DefaultDeactivationBatchSize
+	}
+
+	// forced deactivation doesn't need the lease, concurrent deactivator skips reservations it has locked
+	stats, err := s.db.DeactivateDueReservations(ctx, nil, request.AsOf, request.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.DeactivateDueReservations(ctx, nil, request.AsOf, request.BatchSize): %w", err)
+	}
+
+	logDeactivationStats(stats)
+
+	return &model.DeactivationResult{Stats: stats}, nil
+}
+
+func logDeactivationStats(stats *model.DeactivationStats) {
+	log := zap.L().With(
+		zap.Uint("batches", stats.Batches),
+		zap.Int64("reservations", stats.Reservations),
+		zap.Int64("stocks", stats.Stocks),
+		zap.Duration("duration", stats.Duration))
+
+	if stats.Reservations == 0 {
+		log.Debug("no due reservations to deactivate")
+
+		return
+	}
+
+	log.Info("due reservations deactivated")
+}
+
+// RunExpiryNotifications periodically sends reservations which will expire within leadTime to the sink.
+// Reservations are claimed before sending, so failed notifications are not retried.
+// Outbox notifier gets expiring events written in the same transaction as claim.
+func (s *Service) RunExpiryNotifications(ctx context.Context, period, leadTime time.Duration, sink notifier) error {
+	ticker := time.NewTicker(period)
 	defer ticker.Stop()
 
 	for {
-		if err := s.db.DeactivateDueReservations(ctx); err != nil {
-			return fmt.Errorf("s.db.DeactivateDueReservations(ctx): %w", err)
+		if err := s.notifyExpiringReservations(ctx, leadTime, sink); err != nil {
+			return fmt.Errorf("s.notifyExpiringReservations(ctx, leadTime, sink): %w", err)
 		}
 
 		select {
@@ -90,3 +680,29 @@ func (s *Service) RunReservationsDeactivations(ctx context.Context, duration tim
 		}
 	}
 }
+
+func (s *Service) notifyExpiringReservations(ctx context.Context, leadTime time.Duration, sink notifier) error {
+	outbox, ok := sink.(outboxNotifier)
+	writeEvents := ok && outbox.WritesOutbox()
+
+	for {
+		reservations, err := s.db.ClaimExpiringReservations(
+			ctx, time.Now().Add(leadTime), expiringReservationsBatchSize, writeEvents)
+		if err != nil {
+			return fmt.Errorf("s.db.ClaimExpiringReservations(ctx, before, limit, writeEvents): %w", err)
+		}
+
+		if len(*reservations) == 0 {
+			return nil
+		}
+
+		if err = sink.NotifyExpiring(ctx, *reservations); err != nil {
+			zap.L().With(zap.Error(err), zap.Int("count", len(*reservations))).
+				Warn("notifyExpiringReservations/sink.NotifyExpiring(ctx, reservations)")
+		}
+
+		if len(*reservations) < expiringReservationsBatchSize {
+			return nil
+		}
+	}
+}
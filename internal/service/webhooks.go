@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	WebhooksLeaseName = "webhooks-dispatcher"
+
+	// webhookDeliveriesBatchSize is amount of deliveries sent at once
+	webhookDeliveriesBatchSize = 50
+)
+
+type WebhooksConfig struct {
+	// Period is the time between checks for new events and due deliveries.
+	Period time.Duration
+	// MaxAttempts is amount of failed attempts after which delivery becomes dead.
+	MaxAttempts uint
+	// Backoff is the delay after the first failed attempt. It doubles after every next one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	InstanceID string
+	// LeaseTTL is the time after which another instance takes over the dispatcher. Defaults to 3 periods.
+	// Delivery which wasn't updated during LeaseTTL after it was claimed is sent again.
+	LeaseTTL time.Duration
+}
+
+type webhookSender interface {
+	Send(ctx context.Context, delivery model.WebhookDelivery) (int, error)
+}
+
+// CreateWebhook subscribes url to event types. Returned secret signs deliveries, it isn't shown later.
+func (s *Service) CreateWebhook(
+	ctx context.Context,
+	subscription model.WebhookSubscription,
+) (*model.WebhookSubscription, error) {
+	if err := model.ValidateWebhookSubscription(subscription, s.allowPrivateWebhookHosts); err != nil {
+		return nil, fmt.Errorf("model.ValidateWebhookSubscription(subscription, allowPrivateHosts): %w", err)
+	}
+
+	secret, err := model.NewWebhookSecret()
+	if err != nil {
+		return nil, fmt.Errorf("model.NewWebhookSecret(): %w", err)
+	}
+
+	subscription.ID, subscription.Secret = uuid.New(), secret
+
+	result, err := s.db.CreateWebhookSubscription(ctx, subscription)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.CreateWebhookSubscription(ctx, subscription): %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *Service) GetWebhooks(ctx context.Context) (*[]model.WebhookSubscription, error) {
+	subscriptions, err := s.db.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetWebhookSubscriptions(ctx): %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	if err := s.db.DeleteWebhookSubscription(ctx, id); err != nil {
+		return fmt.Errorf("s.db.DeleteWebhookSubscription(ctx, id): %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) GetWebhookDeliveries(
+	ctx context.Context,
+	params model.GetWebhookDeliveriesParams,
+) (*model.Page[model.WebhookDelivery], error) {
+	if params.Limit == 0 {
+		params.Limit = 10
+	}
+
+	if err := model.ValidateGetWebhookDeliveriesParams(params); err != nil {
+		return nil, fmt.Errorf("model.ValidateGetWebhookDeliveriesParams(params): %w", err)
+	}
+
+	page := &model.Page[model.WebhookDelivery]{Limit: params.Limit, Offset: params.Offset}
+
+	// one extra delivery shows if there is a next page
+	params.Limit++
+
+	deliveries, err := s.db.GetWebhookDeliveries(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetWebhookDeliveries(ctx, params): %w", err)
+	}
+
+	page.Items, page.HasMore = trimPage(*deliveries, page.Limit)
+
+	return page, nil
+}
+
+// RetryWebhookDelivery sends dead delivery again as soon as dispatcher runs.
+func (s *Service) RetryWebhookDelivery(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	delivery, err := s.db.RetryWebhookDelivery(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.RetryWebhookDelivery(ctx, id): %w", err)
+	}
+
+	return delivery, nil
+}
+
+// RunWebhooks turns stock events into deliveries for subscriptions and sends due deliveries until ctx is done.
+// Failed delivery is retried with exponential backoff and becomes dead after cfg.MaxAttempts.
+func (s *Service) RunWebhooks(ctx context.Context, cfg WebhooksConfig, sender webhookSender) error {
+	if cfg.LeaseTTL == 0 {
+		cfg.LeaseTTL = 3 * cfg.Period
+	}
+
+	leader := newLeaderElector(s.db, WebhooksLeaseName, cfg.InstanceID, cfg.LeaseTTL)
+	defer leader.release()
+
+	ticker := time.NewTicker(cfg.Period)
+	defer ticker.Stop()
+
+	for {
+		isLeader, err := leader.check(ctx)
+		if err != nil {
+			return fmt.Errorf("leader.check(ctx): %w", err)
+		}
+
+		if isLeader {
+			err = s.createWebhookDeliveries(ctx)
+
+			switch {
+			case errors.Is(err, model.ErrWebhookCursorMoved):
+				zap.L().Warn("webhook cursor is moved by another dispatcher", zap.String("holder", cfg.InstanceID))
+			case err != nil:
+				return fmt.Errorf("s.createWebhookDeliveries(ctx): %w", err)
+			}
+
+			if err = s.sendWebhookDeliveries(ctx, cfg, sender); err != nil {
+				return fmt.Errorf("s.sendWebhookDeliveries(ctx, cfg, sender): %w", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// createWebhookDeliveries creates deliveries of events committed after the webhook cursor.
+func (s *Service) createWebhookDeliveries(ctx context.Context) error {
+	subscriptions, err := s.db.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("s.db.GetWebhookSubscriptions(ctx): %w", err)
+	}
+
+	lastID, err := s.db.GetWebhookCursor(ctx)
+	if err != nil {
+		return fmt.Errorf("s.db.GetWebhookCursor(ctx): %w", err)
+	}
+
+	for {
+		events, err := s.db.GetStockEvents(ctx, lastID, model.StockEventsFilter{}, stockEventsBatchSize)
+		if err != nil {
+			return fmt.Errorf("s.db.GetStockEvents(ctx, lastID, filter, stockEventsBatchSize): %w", err)
+		}
+
+		if len(*events) == 0 {
+			return nil
+		}
+
+		deliveries := make([]model.WebhookDelivery, 0)
+
+		for _, event := range *events {
+			for _, subscription := range *subscriptions {
+				for _, eventType := range subscription.EventTypesOf(event) {
+					delivery, err := newWebhookDelivery(subscription.ID, eventType, event)
+					if err != nil {
+						return fmt.Errorf("newWebhookDelivery(subscription.ID, eventType, event): %w", err)
+					}
+
+					deliveries = append(deliveries, *delivery)
+				}
+			}
+		}
+
+		prevID := lastID
+		lastID = (*events)[len(*events)-1].ID
+
+		if err = s.db.CreateWebhookDeliveries(ctx, deliveries, prevID, lastID); err != nil {
+			return fmt.Errorf("s.db.CreateWebhookDeliveries(ctx, deliveries, prevID, lastID): %w", err)
+		}
+
+		if len(*events) < stockEventsBatchSize {
+			return nil
+		}
+	}
+}
+
+func newWebhookDelivery(
+	subscriptionID uuid.UUID,
+	eventType model.WebhookEventType,
+	event model.StockEvent,
+) (*model.WebhookDelivery, error) {
+	delivery := model.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: subscriptionID,
+		EventType:      eventType,
+		EventID:        event.ID,
+	}
+
+	payload, err := json.Marshal(model.WebhookMessage{
+		ID:        delivery.ID,
+		Type:      eventType,
+		CreatedAt: event.CreatedAt,
+		Data:      event,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(WebhookMessage): %w", err)
+	}
+
+	delivery.Payload = payload
+
+	return &delivery, nil
+}
+
+// sendWebhookDeliveries sends due deliveries. Deliveries of a batch are sent concurrently,
+// so a slow receiver delays others by one timeout at most.
+func (s *Service) sendWebhookDeliveries(ctx context.Context, cfg WebhooksConfig, sender webhookSender) error {
+	for {
+		deliveries, err := s.db.ClaimWebhookDeliveries(ctx, webhookDeliveriesBatchSize, time.Now().Add(cfg.LeaseTTL))
+		if err != nil {
+			return fmt.Errorf("s.db.ClaimWebhookDeliveries(ctx, limit, leaseUntil): %w", err)
+		}
+
+		var wg sync.WaitGroup
+
+		for _, delivery := range *deliveries {
+			wg.Add(1)
+
+			go func(delivery model.WebhookDelivery) {
+				defer wg.Done()
+
+				s.sendWebhookDelivery(ctx, cfg, sender, delivery)
+			}(delivery)
+		}
+
+		wg.Wait()
+
+		if len(*deliveries) < webhookDeliveriesBatchSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (s *Service) sendWebhookDelivery(
+	ctx context.Context,
+	cfg WebhooksConfig,
+	sender webhookSender,
+	delivery model.WebhookDelivery,
+) {
+	statusCode, err := sender.Send(ctx, delivery)
+
+	// interrupted delivery isn't an attempt, it is sent again when its claim expires
+	if ctx.Err() != nil {
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastStatusCode = nil
+
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	switch {
+	case err == nil:
+		now := time.Now()
+
+		delivery.Status, delivery.DeliveredAt, delivery.LastError = model.WebhookDeliveryDelivered, &now, ""
+	case delivery.Attempts >= cfg.MaxAttempts:
+		delivery.Status, delivery.LastError = model.WebhookDeliveryDead, err.Error()
+	default:
+		nextAttemptAt := time.Now().Add(webhookBackoff(cfg, delivery.Attempts))
+
+		delivery.NextAttemptAt, delivery.LastError = &nextAttemptAt, err.Error()
+	}
+
+	if err = s.db.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		zap.L().With(zap.Error(err), zap.String("id", delivery.ID.String())).
+			Warn("sendWebhookDelivery/s.db.UpdateWebhookDelivery(ctx, delivery)")
+	}
+}
+
+// webhookBackoff returns delay after failed attempt number attempts.
+func webhookBackoff(cfg WebhooksConfig, attempts uint) time.Duration {
+	backoff := cfg.Backoff
+
+	for i := uint(1); i < attempts && backoff < cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, cfg.MaxBackoff)
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (p *Postgres) CreateAPIKey(ctx context.Context, key model.APIKey, hash []byte) (*model.APIKey, error) {
+	query := `
+	INSERT INTO api_keys (id, name, prefix, key_hash, scopes)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING created_at`
+
+	err := p.db.QueryRow(
+		ctx,
+		query,
+		key.ID,
+		key.Name,
+		key.Prefix,
+		hash,
+		scopesToStrings(key.Scopes),
+	).Scan(
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	return &key, nil
+}
+
+// GetAPIKeyByHash returns key with given hash. Revoked keys are returned too, caller decides what to do with them.
+func (p *Postgres) GetAPIKeyByHash(ctx context.Context, hash []byte) (*model.APIKey, error) {
+	query := `
+	SELECT id, name, prefix, scopes, created_at, revoked_at
+	FROM api_keys
+	WHERE key_hash = $1`
+
+	key, err := scanAPIKey(p.db.QueryRow(ctx, query, hash))
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, model.ErrAPIKeyNotFound
+	case err != nil:
+		return nil, fmt.Errorf("scanAPIKey(p.db.QueryRow(%s)): %w", query, err)
+	}
+
+	return key, nil
+}
+
+func (p *Postgres) GetAPIKeys(ctx context.Context) (*[]model.APIKey, error) {
+	query := `
+	SELECT id, name, prefix, scopes, created_at, revoked_at
+	FROM api_keys
+	ORDER BY created_at`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	keys := make([]model.APIKey, 0)
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanAPIKey(rows): %w", err)
+		}
+
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &keys, nil
+}
+
+func (p *Postgres) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return model.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (*model.APIKey, error) {
+	var (
+		key    model.APIKey
+		scopes []string
+	)
+
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.CreatedAt,
+		&key.RevokedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan(...): %w", err)
+	}
+
+	key.Scopes = make([]model.Scope, 0, len(scopes))
+
+	for _, scope := range scopes {
+		key.Scopes = append(key.Scopes, model.Scope(scope))
+	}
+
+	return &key, nil
+}
+
+func scopesToStrings(scopes []model.Scope) []string {
+	result := make([]string, 0, len(scopes))
+
+	for _, scope := range scopes {
+		result = append(result, string(scope))
+	}
+
+	return result
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ArchiveInactiveReservations moves reservations inactive since before olderThan to reservations_archive
+// in batches of batchSize and returns amount of archived reservations.
+func (p *Postgres) ArchiveInactiveReservations(
+	ctx context.Context,
+	fence *model.LeaseFence,
+	olderThan time.Time,
+	batchSize uint,
+) (int64, error) {
+	var total int64
+
+	for {
+		archived, err := p.archiveBatch(ctx, fence, olderThan, batchSize)
+		if err != nil {
+			return total, fmt.Errorf("p.archiveBatch(ctx, fence, olderThan, batchSize): %w", err)
+		}
+
+		total += archived
+
+		if archived < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+func (p *Postgres) archiveBatch(
+	ctx context.Context,
+	fence *model.LeaseFence,
+	olderThan time.Time,
+	batchSize uint,
+) (int64, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("archiveBatch/tx.Rollback(ctx)")
+		}
+	}()
+
+	if err = checkFence(ctx, tx, fence); err != nil {
+		return 0, fmt.Errorf("checkFence(ctx, tx, fence): %w", err)
+	}
+
+	query := `
+	WITH archived AS (
+		DELETE FROM reservations
+		WHERE id IN (
+			SELECT id FROM reservations
+			WHERE is_active = false AND COALESCE(deactivated_at, due_date) < $1
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, warehouse_id, product_id, quantity, created_at, due_date, deactivated_at
+	)
+	INSERT INTO reservations_archive (id, warehouse_id, product_id, quantity, created_at, due_date, deactivated_at)
+	SELECT id, warehouse_id, product_id, quantity, created_at, due_date, deactivated_at FROM archived`
+
+	commandTag, err := tx.Exec(ctx, query, olderThan, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+// reservationsQuery returns query which selects reservations matching params filters together
+// with its arguments. Query has neither order nor limit.
+func reservationsQuery(params model.GetReservationsParams) (string, []any) {
+	conditions := `
+		(cardinality($1::uuid[]) = 0 OR id = ANY($1::uuid[]))
+		AND ($2::uuid IS NULL OR warehouse_id = $2::uuid)
+		AND ($3 = '' OR product_id = $3)`
+
+	query := `
+	SELECT id, warehouse_id, product_id, quantity, is_active, created_at, due_date, deactivated_at,
+		NULL::timestamptz AS archived_at
+	FROM reservations
+	WHERE ` + conditions + ` AND ($4 = false OR is_active = true)`
+
+	if params.IncludeArchived && !params.ActiveOnly {
+		query += `
+	UNION ALL
+	SELECT id, warehouse_id, product_id, quantity, false, created_at, due_date, deactivated_at, archived_at
+	FROM reservations_archive
+	WHERE ` + conditions
+	}
+
+	ids := params.IDs
+	if ids == nil {
+		ids = []uuid.UUID{}
+	}
+
+	return query, []any{ids, params.WarehouseID, params.ProductID, params.ActiveOnly}
+}
+
+// CountReservations returns amount of reservations matching params filters.
+func (p *Postgres) CountReservations(
+	ctx context.Context,
+	params model.GetReservationsParams,
+	mode model.TotalMode,
+) (int64, error) {
+	query, args := reservationsQuery(params)
+
+	total, err := p.count(ctx, query, args, mode)
+	if err != nil {
+		return 0, fmt.Errorf("p.count(ctx, query, args, mode): %w", err)
+	}
+
+	return total, nil
+}
+
+func (p *Postgres) GetReservations(ctx context.Context, params model.GetReservationsParams) (*[]model.Reservation, error) {
+	query, args := reservationsQuery(params)
+
+	query += `
+	ORDER BY created_at DESC, id
+	OFFSET $5 LIMIT $6`
+
+	rows, err := p.db.Query(ctx, query, append(args, params.Offset, params.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	reservations := make([]model.Reservation, 0)
+
+	for rows.Next() {
+		var reservation model.Reservation
+
+		err = rows.Scan(
+			&reservation.ID,
+			&reservation.WarehouseID,
+			&reservation.ProductID,
+			&reservation.Quantity,
+			&reservation.IsActive,
+			&reservation.CreatedAt,
+			&reservation.DueDate,
+			&reservation.DeactivatedAt,
+			&reservation.ArchivedAt)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &reservations, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+)
+
+// GetAvailability sums stocks of every product in params.ProductIDs. Products without stocks
+// are returned with zero quantities, so result always follows the order of params.ProductIDs.
+func (p *Postgres) GetAvailability(
+	ctx context.Context,
+	params model.AvailabilityParams,
+) (*[]model.ProductAvailability, error) {
+	query := `
+	SELECT s.product_id, s.warehouse_id, w.region,
+		SUM(s.quantity), SUM(s.reserved_quantity), SUM(GREATEST(s.quantity - s.reserved_quantity, 0)),
+		MAX(s.modified_at)
+	FROM stocks s
+	JOIN warehouses w ON w.id = s.warehouse_id
+	WHERE s.product_id = ANY($1::text[]) AND ($2 = false OR w.is_active = true)
+	GROUP BY s.product_id, s.warehouse_id, w.region
+	ORDER BY s.product_id, w.region, s.warehouse_id`
+
+	if params.GroupByRegion {
+		query = `
+	SELECT s.product_id, NULL::uuid, w.region,
+		SUM(s.quantity), SUM(s.reserved_quantity), SUM(GREATEST(s.quantity - s.reserved_quantity, 0)),
+		MAX(s.modified_at)
+	FROM stocks s
+	JOIN warehouses w ON w.id = s.warehouse_id
+	WHERE s.product_id = ANY($1::text[]) AND ($2 = false OR w.is_active = true)
+	GROUP BY s.product_id, w.region
+	ORDER BY s.product_id, w.region`
+	}
+
+	rows, err := p.db.Query(ctx, query, params.ProductIDs, params.ActiveWarehousesOnly)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	products := make(map[string]*model.ProductAvailability, len(params.ProductIDs))
+
+	for rows.Next() {
+		var (
+			productID    string
+			warehouseID  *uuid.UUID
+			availability model.Availability
+			modifiedAt   time.Time
+		)
+
+		err = rows.Scan(
+			&productID,
+			&warehouseID,
+			&availability.Region,
+			&availability.Quantity,
+			&availability.ReservedQuantity,
+			&availability.AvailableQuantity,
+			&modifiedAt)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		availability.WarehouseID = warehouseID
+
+		product, ok := products[productID]
+		if !ok {
+			product = &model.ProductAvailability{ProductID: productID}
+			products[productID] = product
+		}
+
+		product.Quantity += availability.Quantity
+		product.ReservedQuantity += availability.ReservedQuantity
+		product.AvailableQuantity += availability.AvailableQuantity
+		product.Breakdown = append(product.Breakdown, availability)
+
+		if product.ModifiedAt == nil || modifiedAt.After(*product.ModifiedAt) {
+			product.ModifiedAt = &modifiedAt
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	result := make([]model.ProductAvailability, 0, len(params.ProductIDs))
+	added := make(map[string]bool, len(params.ProductIDs))
+
+	for _, productID := range params.ProductIDs {
+		if added[productID] {
+			continue
+		}
+
+		added[productID] = true
+
+		product, ok := products[productID]
+		if !ok {
+			product = &model.ProductAvailability{ProductID: productID, Breakdown: []model.Availability{}}
+		}
+
+		result = append(result, *product)
+	}
+
+	return &result, nil
+}
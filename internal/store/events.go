@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	stockEventsChannel       = "stock_events"
+	stockEventsOutboxChannel = "stock_events_outbox"
+	// stockEventsRelayLock is an advisory lock held by transaction relaying events from outbox until it commits.
+	// Events ids are taken under it, so events become visible in order of ids and reader
+	// continuing after the last seen id doesn't skip events committed later with smaller id.
+	// Writers of stocks don't take it, so they aren't serialized with each other.
+	stockEventsRelayLock = 7_440_001
+	// stockEventsRelayBatchSize is amount of events relayed by one transaction
+	stockEventsRelayBatchSize = 1000
+)
+
+// insertStockEvents writes events to outbox and wakes relays when tx commits. Events of a stock are written
+// while stock is locked, so they are relayed in order of their changes.
+func insertStockEvents(ctx context.Context, tx pgx.Tx, events []model.StockEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO stock_events_outbox
+		(kind, warehouse_id, product_id, reservation_id, quantity, change_kind, stock_quantity, reserved_quantity, version)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)`
+
+	batch := &pgx.Batch{}
+
+	for _, event := range events {
+		batch.Queue(
+			query,
+			event.Kind,
+			event.WarehouseID,
+			event.ProductID,
+			event.ReservationID,
+			event.Quantity,
+			event.ChangeKind,
+			event.StockQuantity,
+			event.ReservedQuantity,
+			event.Version,
+		)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("tx.SendBatch(ctx, batch).Close(): %w", err)
+	}
+
+	query = `SELECT pg_notify($1, '')`
+
+	if _, err := tx.Exec(ctx, query, stockEventsOutboxChannel); err != nil {
+		return fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	return nil
+}
+
+// RelayStockEvents moves events from outbox to stock_events and wakes listeners. Relays are serialized,
+// so ids of events grow in order they become visible. It returns amount of relayed events.
+func (p *Postgres) RelayStockEvents(ctx context.Context) (int64, error) {
+	var relayed int64
+
+	for {
+		count, err := p.relayStockEventsBatch(ctx)
+		if err != nil {
+			return relayed, fmt.Errorf("p.relayStockEventsBatch(ctx): %w", err)
+		}
+
+		relayed += count
+
+		if count < stockEventsRelayBatchSize {
+			return relayed, nil
+		}
+	}
+}
+
+func (p *Postgres) relayStockEventsBatch(ctx context.Context) (int64, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("relayStockEventsBatch/tx.Rollback(ctx)")
+		}
+	}()
+
+	// lock is taken by its own statement, so the next one sees events relayed by the previous relay
+	query := `SELECT pg_advisory_xact_lock($1)`
+
+	if _, err = tx.Exec(ctx, query, stockEventsRelayLock); err != nil {
+		return 0, fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	query = `
+	WITH relayed AS (
+		DELETE FROM stock_events_outbox
+		WHERE id IN (SELECT id FROM stock_events_outbox ORDER BY id LIMIT $1)
+		RETURNING *
+	)
+	INSERT INTO stock_events (kind, warehouse_id, product_id, reservation_id, quantity, change_kind,
+		stock_quantity, reserved_quantity, version, created_at)
+	SELECT kind, warehouse_id, product_id, reservation_id, quantity, change_kind,
+		stock_quantity, reserved_quantity, version, created_at
+	FROM relayed
+	ORDER BY id`
+
+	commandTag, err := tx.Exec(ctx, query, stockEventsRelayBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	query = `SELECT pg_notify($1, '')`
+
+	if _, err = tx.Exec(ctx, query, stockEventsChannel); err != nil {
+		return 0, fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+// GetStockEvents returns up to limit events with id greater than afterID in order of ids.
+func (p *Postgres) GetStockEvents(
+	ctx context.Context,
+	afterID int64,
+	filter model.StockEventsFilter,
+	limit uint,
+) (*[]model.StockEvent, error) {
+	var builder queryBuilder
+
+	builder.where("id > " + builder.arg(afterID))
+
+	if len(filter.WarehouseIDs) > 0 {
+		builder.where("warehouse_id = ANY(" + builder.arg(filter.WarehouseIDs) + "::uuid[])")
+	}
+
+	if len(filter.ProductIDs) > 0 {
+		builder.where("product_id = ANY(" + builder.arg(filter.ProductIDs) + "::text[])")
+	}
+
+	query := `
+	SELECT id, kind, warehouse_id, product_id, reservation_id, quantity, COALESCE(change_kind, ''),
+		stock_quantity, reserved_quantity, version, created_at
+	FROM stock_events` +
+		builder.whereClause() +
+		" ORDER BY id LIMIT " + builder.arg(limit)
+
+	rows, err := p.db.Query(ctx, query, builder.args...)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	events := make([]model.StockEvent, 0)
+
+	for rows.Next() {
+		var event model.StockEvent
+
+		err = rows.Scan(
+			&event.ID,
+			&event.Kind,
+			&event.WarehouseID,
+			&event.ProductID,
+			&event.ReservationID,
+			&event.Quantity,
+			&event.ChangeKind,
+			&event.StockQuantity,
+			&event.ReservedQuantity,
+			&event.Version,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		event.AvailableQuantity = model.AvailableQuantity(event.StockQuantity, event.ReservedQuantity)
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &events, nil
+}
+
+// GetLastStockEventID returns id of the latest committed event, or zero if there are no events.
+func (p *Postgres) GetLastStockEventID(ctx context.Context) (int64, error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM stock_events`
+
+	var id int64
+
+	if err := p.db.QueryRow(ctx, query).Scan(&id); err != nil {
+		return 0, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	return id, nil
+}
+
+// ListenStockEvents calls fn every time new events are committed to outbox or relayed until ctx is done.
+// Fn is also called right after listening starts, so events committed while nobody listened are picked up too.
+// Connection is held until ctx is done, it is one of listenerConns.
+func (p *Postgres) ListenStockEvents(ctx context.Context, fn func()) error {
+	conn, err := p.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Acquire(ctx): %w", err)
+	}
+
+	defer conn.Release()
+
+	for _, channel := range []string{stockEventsChannel, stockEventsOutboxChannel} {
+		if _, err = conn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return fmt.Errorf("conn.Exec(LISTEN %s): %w", channel, err)
+		}
+	}
+
+	fn()
+
+	for {
+		_, err := conn.Conn().WaitForNotification(ctx)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return fmt.Errorf("conn.Conn().WaitForNotification(ctx): %w", err)
+		}
+
+		fn()
+	}
+}
+
+// DeleteStockEvents removes events created before olderThan. Clients can't resume from removed events.
+// While there are webhook subscriptions, events after webhook cursor are kept until deliveries are created of them.
+func (p *Postgres) DeleteStockEvents(ctx context.Context, olderThan time.Time) (int64, error) {
+	query := `
+	DELETE FROM stock_events
+	WHERE created_at < $1 AND (
+		id <= (SELECT last_event_id FROM webhook_cursor WHERE id = 1) OR
+		NOT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE deleted_at IS NULL))`
+
+	commandTag, err := p.db.Exec(ctx, query, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	return commandTag.RowsAffected(), nil
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// exportFetchSize is amount of stocks fetched from cursor at once.
+const exportFetchSize = 1000
+
+// ExportStocks passes every stock matching params filters to fn in params.Sorting order. Stocks are read
+// by server side cursor in a read only repeatable read transaction, so they are a consistent snapshot
+// and aren't held in memory. Pagination params are ignored. Export stops at the first error of fn.
+func (p *Postgres) ExportStocks(ctx context.Context, params model.GetParams, fn func(stock model.Stock) error) error {
+	order, err := stocksOrder(params.Sorting)
+	if err != nil {
+		return fmt.Errorf("stocksOrder(params.Sorting): %w", err)
+	}
+
+	builder := stocksFilter(params)
+
+	direction := ""
+	if params.Descending {
+		direction = " DESC"
+	}
+
+	orderBy := make([]string, 0, len(order))
+
+	for _, column := range order {
+		orderBy = append(orderBy, column.name+direction)
+	}
+
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("p.db.BeginTx(ctx, RepeatableRead): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("ExportStocks/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `DECLARE stocks_export NO SCROLL CURSOR FOR
+	SELECT warehouse_id, product_id, quantity, reserved_quantity, created_at, modified_at, version FROM stocks` +
+		builder.whereClause() +
+		" ORDER BY " + strings.Join(orderBy, ", ")
+
+	if _, err = tx.Exec(ctx, query, builder.args...); err != nil {
+		return fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	query = fmt.Sprintf(`FETCH %d FROM stocks_export`, exportFetchSize)
+
+	for {
+		fetched, err := fetchStocks(ctx, tx, query, fn)
+		if err != nil {
+			return fmt.Errorf("fetchStocks(ctx, tx, query, fn): %w", err)
+		}
+
+		if fetched < exportFetchSize {
+			break
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return nil
+}
+
+func fetchStocks(ctx context.Context, tx pgx.Tx, query string, fn func(stock model.Stock) error) (int, error) {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("tx.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	fetched := 0
+
+	for rows.Next() {
+		var stock model.Stock
+
+		err = rows.Scan(
+			&stock.WarehouseID,
+			&stock.ProductID,
+			&stock.Quantity,
+			&stock.ReservedQuantity,
+			&stock.CreatedAt,
+			&stock.ModifiedAt,
+			&stock.Version)
+		if err != nil {
+			return 0, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		stock.AvailableQuantity = model.AvailableQuantity(stock.Quantity, stock.ReservedQuantity)
+
+		if err = fn(stock); err != nil {
+			return 0, fmt.Errorf("fn(stock): %w", err)
+		}
+
+		fetched++
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return fetched, nil
+}
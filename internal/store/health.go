@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// migrationsTable is the table where sql-migrate records applied migrations.
+const migrationsTable = "gorp_migrations"
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	if err := p.db.Ping(ctx); err != nil {
+		return fmt.Errorf("p.db.Ping(ctx): %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingMigrations returns embedded migrations which aren't applied to db yet.
+func (p *Postgres) GetPendingMigrations(ctx context.Context) ([]string, error) {
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrations.ReadDir(migrations): %w", err)
+	}
+
+	query := `SELECT id FROM ` + migrationsTable
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	applied := make(map[string]struct{})
+
+	for rows.Next() {
+		var id string
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		applied[id] = struct{}{}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	pending := make([]string, 0)
+
+	for _, entry := range entries {
+		if _, ok := applied[entry.Name()]; !ok && strings.HasSuffix(entry.Name(), ".sql") {
+			pending = append(pending, entry.Name())
+		}
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// AcquireLease takes lease with given name for holder or renews it if holder already owns it.
+// Lease held by another holder can be taken over only after it expires. Nil fence is returned
+// if lease is held by another holder. Fencing token is taken from a sequence whenever lease
+// changes hands, so it never repeats even if lease is released in between.
+func (p *Postgres) AcquireLease(
+	ctx context.Context,
+	name, holder string,
+	ttl time.Duration,
+) (*model.LeaseFence, error) {
+	query := `
+	INSERT INTO worker_leases (name, holder, expires_at)
+	VALUES ($1, $2, now() + make_interval(secs => $3))
+	ON CONFLICT (name) DO UPDATE
+	SET holder = excluded.holder,
+		acquired_at = CASE WHEN worker_leases.holder = excluded.holder THEN worker_leases.acquired_at ELSE now() END,
+		fencing_token = CASE
+			WHEN worker_leases.holder = excluded.holder THEN worker_leases.fencing_token
+			ELSE excluded.fencing_token
+		END,
+		renewed_at = now(),
+		expires_at = excluded.expires_at
+	WHERE worker_leases.holder = excluded.holder OR worker_leases.expires_at < now()
+	RETURNING fencing_token`
+
+	fence := model.LeaseFence{Name: name, Holder: holder}
+
+	err := p.db.QueryRow(
+		ctx,
+		query,
+		name,
+		holder,
+		ttl.Seconds(),
+	).Scan(
+		&fence.Token,
+	)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	return &fence, nil
+}
+
+// checkFence returns ErrLeaseLost if lease of fence has changed hands since fence was issued.
+// Otherwise lease is locked until tx ends, so it can't be taken over while tx writes.
+// Nil fence is used by writes which don't run under a lease.
+func checkFence(ctx context.Context, tx pgx.Tx, fence *model.LeaseFence) error {
+	if fence == nil {
+		return nil
+	}
+
+	query := `
+	SELECT fencing_token FROM worker_leases
+	WHERE name = $1 AND holder = $2 AND fencing_token = $3
+	FOR SHARE`
+
+	var token int64
+
+	err := tx.QueryRow(ctx, query, fence.Name, fence.Holder, fence.Token).Scan(&token)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return model.ErrLeaseLost
+	case err != nil:
+		return fmt.Errorf("tx.QueryRow(%s): %w", query, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ReleaseLease(ctx context.Context, name, holder string) error {
+	query := `DELETE FROM worker_leases WHERE name = $1 AND holder = $2`
+
+	_, err := p.db.Exec(ctx, query, name, holder)
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetLeases(ctx context.Context) (*[]model.Lease, error) {
+	query := `
+	SELECT name, holder, acquired_at, renewed_at, expires_at, expires_at < now()
+	FROM worker_leases
+	ORDER BY name`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	leases := make([]model.Lease, 0)
+
+	for rows.Next() {
+		var lease model.Lease
+
+		err = rows.Scan(
+			&lease.Name,
+			&lease.Holder,
+			&lease.AcquiredAt,
+			&lease.RenewedAt,
+			&lease.ExpiresAt,
+			&lease.IsExpired)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		leases = append(leases, lease)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &leases, nil
+}
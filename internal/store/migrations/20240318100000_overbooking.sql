@@ -0,0 +1,35 @@
+-- +migrate Up
+
+-- reservation limit is enforced by the service now, so reserved quantity may exceed quantity
+ALTER TABLE stocks DROP CONSTRAINT IF EXISTS stocks_reserved_quantity_check;
+ALTER TABLE stocks DROP CONSTRAINT IF EXISTS stocks_reserved_quantity_check1;
+ALTER TABLE stocks ADD CONSTRAINT stocks_reserved_quantity_check CHECK ( reserved_quantity >= 0 );
+
+ALTER TABLE warehouses ADD COLUMN overbooking_percent int not null default 0 check ( overbooking_percent >= 0 );
+ALTER TABLE products ADD COLUMN overbooking_percent int check ( overbooking_percent >= 0 );
+
+CREATE INDEX idx_stocks_overbooked ON stocks (warehouse_id, product_id) WHERE reserved_quantity > quantity;
+
+-- +migrate Down
+
+-- constraints of init migration can't be restored while stocks are overbooked. Reservations exceeding
+-- quantity have to be released or quantity increased before rollback, rows aren't changed silently
+-- +migrate StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM stocks WHERE reserved_quantity > quantity) THEN
+        RAISE EXCEPTION 'stocks are overbooked, release reservations exceeding quantity before rollback';
+    END IF;
+END
+$$;
+-- +migrate StatementEnd
+
+DROP INDEX idx_stocks_overbooked;
+
+ALTER TABLE products DROP COLUMN overbooking_percent;
+ALTER TABLE warehouses DROP COLUMN overbooking_percent;
+
+-- names and definitions are the ones postgres gave to column constraints of init migration
+ALTER TABLE stocks DROP CONSTRAINT stocks_reserved_quantity_check;
+ALTER TABLE stocks ADD CONSTRAINT stocks_reserved_quantity_check CHECK ( reserved_quantity <= stocks.quantity );
+ALTER TABLE stocks ADD CONSTRAINT stocks_reserved_quantity_check1 CHECK ( reserved_quantity >= 0 );
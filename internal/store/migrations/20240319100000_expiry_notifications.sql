@@ -0,0 +1,12 @@
+-- +migrate Up
+
+ALTER TABLE reservations ADD COLUMN expiry_notified_at timestamp with time zone;
+
+CREATE INDEX idx_reservations_not_notified_due_date ON reservations (due_date)
+    WHERE is_active = true AND expiry_notified_at IS NULL;
+
+-- +migrate Down
+
+DROP INDEX idx_reservations_not_notified_due_date;
+
+ALTER TABLE reservations DROP COLUMN expiry_notified_at;
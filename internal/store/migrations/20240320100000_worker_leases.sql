@@ -0,0 +1,13 @@
+-- +migrate Up
+
+CREATE TABLE worker_leases (
+    name varchar primary key,
+    holder varchar not null,
+    acquired_at timestamp with time zone not null default now(),
+    renewed_at timestamp with time zone not null default now(),
+    expires_at timestamp with time zone not null
+);
+
+-- +migrate Down
+
+DROP TABLE worker_leases;
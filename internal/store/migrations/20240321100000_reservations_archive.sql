@@ -0,0 +1,27 @@
+-- +migrate Up
+
+ALTER TABLE reservations ADD COLUMN deactivated_at timestamp with time zone;
+
+CREATE INDEX idx_reservations_inactive_since ON reservations (COALESCE(deactivated_at, due_date))
+    WHERE is_active = false;
+
+CREATE TABLE reservations_archive (
+    id uuid primary key,
+    warehouse_id uuid not null,
+    product_id varchar (12),
+    quantity int not null,
+    created_at timestamp with time zone not null,
+    due_date timestamp with time zone not null,
+    deactivated_at timestamp with time zone,
+    archived_at timestamp with time zone not null default now()
+);
+
+CREATE INDEX idx_reservations_archive_created_at ON reservations_archive (created_at);
+
+-- +migrate Down
+
+DROP TABLE reservations_archive;
+
+DROP INDEX idx_reservations_inactive_since;
+
+ALTER TABLE reservations DROP COLUMN deactivated_at;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE warehouses ADD COLUMN region varchar (64) not null default '';
+
+CREATE INDEX idx_stocks_product_id ON stocks (product_id);
+
+-- +migrate Down
+
+DROP INDEX idx_stocks_product_id;
+
+ALTER TABLE warehouses DROP COLUMN region;
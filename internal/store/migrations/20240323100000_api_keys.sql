@@ -0,0 +1,15 @@
+-- +migrate Up
+
+CREATE TABLE api_keys (
+    id uuid primary key,
+    name varchar(128) not null,
+    prefix varchar(16) not null,
+    key_hash bytea not null unique,
+    scopes text[] not null,
+    created_at timestamp with time zone not null default now(),
+    revoked_at timestamp with time zone
+);
+
+-- +migrate Down
+
+DROP TABLE api_keys;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+CREATE TABLE rate_limit_buckets (
+    key text primary key,
+    tokens double precision not null,
+    updated_at timestamp with time zone not null default now()
+);
+
+-- +migrate Down
+
+DROP TABLE rate_limit_buckets;
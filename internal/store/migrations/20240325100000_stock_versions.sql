@@ -0,0 +1,7 @@
+-- +migrate Up
+
+ALTER TABLE stocks ADD COLUMN version bigint not null default 1;
+
+-- +migrate Down
+
+ALTER TABLE stocks DROP COLUMN version;
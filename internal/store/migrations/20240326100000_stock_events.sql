@@ -0,0 +1,20 @@
+-- +migrate Up
+
+CREATE TABLE stock_events (
+    id bigserial primary key,
+    kind text not null,
+    warehouse_id uuid not null,
+    product_id varchar not null,
+    reservation_id uuid,
+    quantity bigint not null,
+    stock_quantity bigint not null,
+    reserved_quantity bigint not null,
+    version bigint not null,
+    created_at timestamp with time zone not null default now()
+);
+
+CREATE INDEX stock_events_created_at_idx ON stock_events (created_at);
+
+-- +migrate Down
+
+DROP TABLE stock_events;
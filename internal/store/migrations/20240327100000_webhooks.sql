@@ -0,0 +1,48 @@
+-- +migrate Up
+
+ALTER TABLE stock_events ADD COLUMN change_kind text;
+
+CREATE TABLE webhook_subscriptions (
+    id uuid primary key,
+    url text not null,
+    secret text not null,
+    event_types text[] not null,
+    low_stock_threshold bigint not null default 0,
+    created_at timestamp with time zone not null default now(),
+    deleted_at timestamp with time zone
+);
+
+CREATE TABLE webhook_deliveries (
+    id uuid primary key,
+    subscription_id uuid not null references webhook_subscriptions (id),
+    event_type text not null,
+    event_id bigint not null,
+    payload jsonb not null,
+    status text not null default 'pending',
+    attempts int not null default 0,
+    next_attempt_at timestamp with time zone not null default now(),
+    last_status_code int,
+    last_error text,
+    created_at timestamp with time zone not null default now(),
+    delivered_at timestamp with time zone
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, created_at);
+
+-- webhook_cursor keeps id of the last stock event turned into deliveries. Dispatching starts from events
+-- committed after migration, history isn't sent to subscribers.
+CREATE TABLE webhook_cursor (
+    id int primary key check (id = 1),
+    last_event_id bigint not null
+);
+
+INSERT INTO webhook_cursor (id, last_event_id) SELECT 1, COALESCE(MAX(id), 0) FROM stock_events;
+
+-- +migrate Down
+
+DROP TABLE webhook_cursor;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
+
+ALTER TABLE stock_events DROP COLUMN change_kind;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+CREATE SEQUENCE worker_lease_tokens;
+
+ALTER TABLE worker_leases ADD COLUMN fencing_token bigint not null default nextval('worker_lease_tokens');
+
+-- +migrate Down
+
+ALTER TABLE worker_leases DROP COLUMN fencing_token;
+
+DROP SEQUENCE worker_lease_tokens;
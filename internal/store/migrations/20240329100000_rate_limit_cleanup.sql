@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE rate_limit_buckets ADD COLUMN full_at timestamp with time zone not null default now();
+
+CREATE INDEX rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
+
+-- +migrate Down
+
+DROP INDEX rate_limit_buckets_full_at_idx;
+
+ALTER TABLE rate_limit_buckets DROP COLUMN full_at;
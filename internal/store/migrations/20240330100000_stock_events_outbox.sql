@@ -0,0 +1,20 @@
+-- +migrate Up
+
+-- writers put events into outbox, they get ids of stock_events in order of relaying, which is serialized
+CREATE TABLE stock_events_outbox (
+    id bigserial primary key,
+    kind text not null,
+    warehouse_id uuid not null,
+    product_id varchar not null,
+    reservation_id uuid,
+    quantity bigint not null,
+    change_kind text,
+    stock_quantity bigint not null,
+    reserved_quantity bigint not null,
+    version bigint not null,
+    created_at timestamp with time zone not null default now()
+);
+
+-- +migrate Down
+
+DROP TABLE stock_events_outbox;
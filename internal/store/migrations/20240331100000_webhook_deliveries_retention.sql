@@ -0,0 +1,7 @@
+-- +migrate Up
+
+CREATE INDEX webhook_deliveries_created_at_idx ON webhook_deliveries (created_at) WHERE status <> 'pending';
+
+-- +migrate Down
+
+DROP INDEX webhook_deliveries_created_at_idx;
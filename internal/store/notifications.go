@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ClaimExpiringReservations marks active reservations which are due before the given moment as notified
+// and returns them. Each reservation is returned only once, so notifications are delivered at most once.
+// If writeEvents is set, expiring events of claimed reservations are written to stock events outbox
+// in the same transaction.
+func (p *Postgres) ClaimExpiringReservations(
+	ctx context.Context,
+	before time.Time,
+	limit uint,
+	writeEvents bool,
+) (*[]model.Reservation, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("ClaimExpiringReservations/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	UPDATE reservations
+	SET expiry_notified_at = now()
+	WHERE id IN (
+		SELECT id FROM reservations
+		WHERE is_active = true AND expiry_notified_at IS NULL AND due_date > now() AND due_date <= $1
+		ORDER BY due_date
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED)
+	RETURNING id, warehouse_id, product_id, quantity, is_active, created_at, due_date`
+
+	rows, err := tx.Query(
+		ctx,
+		query,
+		before,
+		limit)
+	if err != nil {
+		return nil, fmt.Errorf("tx.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	reservations := make([]model.Reservation, 0)
+
+	for rows.Next() {
+		var reservation model.Reservation
+
+		err = rows.Scan(
+			&reservation.ID,
+			&reservation.WarehouseID,
+			&reservation.ProductID,
+			&reservation.Quantity,
+			&reservation.IsActive,
+			&reservation.CreatedAt,
+			&reservation.DueDate)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	if writeEvents {
+		if err = insertExpiringEvents(ctx, tx, reservations); err != nil {
+			return nil, fmt.Errorf("insertExpiringEvents(ctx, tx, reservations): %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return &reservations, nil
+}
+
+// insertExpiringEvents writes expiring events of reservations with current state of their stocks.
+// Stocks are locked for share, so events are relayed after events of changes committed before.
+func insertExpiringEvents(ctx context.Context, tx pgx.Tx, reservations []model.Reservation) error {
+	if len(reservations) == 0 {
+		return nil
+	}
+
+	warehouseIDs, productIDs := make([]uuid.UUID, 0), make([]string, 0)
+
+	for _, reservation := range reservations {
+		warehouseIDs = append(warehouseIDs, reservation.WarehouseID)
+		productIDs = append(productIDs, reservation.ProductID)
+	}
+
+	query := `
+	SELECT warehouse_id, product_id, quantity, reserved_quantity, version
+	FROM stocks
+	WHERE (warehouse_id, product_id) IN (SELECT * FROM unnest($1::uuid[], $2::varchar[]))
+	ORDER BY warehouse_id, product_id
+	FOR SHARE`
+
+	rows, err := tx.Query(ctx, query, warehouseIDs, productIDs)
+	if err != nil {
+		return fmt.Errorf("tx.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	stocks := make(map[string]model.Stock)
+
+	for rows.Next() {
+		var stock model.Stock
+
+		err = rows.Scan(&stock.WarehouseID, &stock.ProductID, &stock.Quantity, &stock.ReservedQuantity, &stock.Version)
+		if err != nil {
+			return fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		stocks[stock.WarehouseID.String()+"/"+stock.ProductID] = stock
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	events := make([]model.StockEvent, 0, len(reservations))
+
+	for _, reservation := range reservations {
+		stock := stocks[reservation.WarehouseID.String()+"/"+reservation.ProductID]
+
+		events = append(events, model.StockEvent{
+			Kind:             model.StockEventExpiring,
+			WarehouseID:      reservation.WarehouseID,
+			ProductID:        reservation.ProductID,
+			ReservationID:    &reservation.ID,
+			Quantity:         int64(reservation.Quantity),
+			StockQuantity:    stock.Quantity,
+			ReservedQuantity: stock.ReservedQuantity,
+			Version:          stock.Version,
+		})
+	}
+
+	if err = insertStockEvents(ctx, tx, events); err != nil {
+		return fmt.Errorf("insertStockEvents(ctx, tx, events): %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+)
+
+func (p *Postgres) SetOverbookingAllowance(ctx context.Context, allowance model.OverbookingAllowance) error {
+	if allowance.ProductID != "" {
+		query := `UPDATE products SET overbooking_percent = $1 WHERE sku = $2`
+
+		commandTag, err := p.db.Exec(ctx, query, allowance.Percent, allowance.ProductID)
+		if err != nil {
+			return fmt.Errorf("p.db.Exec(%s): %w", query, err)
+		}
+
+		if commandTag.RowsAffected() != 1 {
+			return &model.ProductNotFoundError{SKU: allowance.ProductID}
+		}
+
+		return nil
+	}
+
+	query := `UPDATE warehouses SET overbooking_percent = $1 WHERE id = $2`
+
+	commandTag, err := p.db.Exec(ctx, query, allowance.Percent, allowance.WarehouseID)
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		return &model.WarehouseNotFoundError{WarehouseID: allowance.WarehouseID}
+	}
+
+	return nil
+}
+
+// GetOverbookedStocks returns overbooked stocks ordered by params.Sorting and then by primary key.
+// Without sorting the most overbooked stocks go first.
+func (p *Postgres) GetOverbookedStocks(ctx context.Context, params model.GetParams) (*[]model.OverbookedStock, error) {
+	orderBy := []string{"reserved_quantity - quantity DESC", "warehouse_id", "product_id"}
+
+	if params.Sorting != "" {
+		order, err := stocksOrder(params.Sorting)
+		if err != nil {
+			return nil, fmt.Errorf("stocksOrder(params.Sorting): %w", err)
+		}
+
+		direction := ""
+		if params.Descending {
+			direction = " DESC"
+		}
+
+		orderBy = orderBy[:0]
+
+		for _, column := range order {
+			orderBy = append(orderBy, column.name+direction)
+		}
+	}
+
+	// stocks are selected by subquery, so sort columns aren't ambiguous with columns of joined tables
+	query := `
+	SELECT warehouse_id, product_id, quantity, reserved_quantity, created_at, modified_at, version, overbooking_percent
+	FROM (
+		SELECT s.*, COALESCE(p.overbooking_percent, w.overbooking_percent) AS overbooking_percent
+		FROM stocks s
+		JOIN warehouses w ON w.id = s.warehouse_id
+		JOIN products p ON p.sku = s.product_id
+		WHERE s.reserved_quantity > s.quantity
+			AND ($1 = '' OR s.warehouse_id::text = $1)
+			AND ($2 = '' OR s.product_id = $2)
+	) AS stocks
+	ORDER BY ` + strings.Join(orderBy, ", ") + `
+	OFFSET $3 LIMIT $4`
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		params.WarehouseFilter,
+		params.ProductFilter,
+		params.Offset,
+		params.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	stocks := make([]model.OverbookedStock, 0)
+
+	for rows.Next() {
+		var stock model.OverbookedStock
+
+		err = rows.Scan(
+			&stock.WarehouseID,
+			&stock.ProductID,
+			&stock.Quantity,
+			&stock.ReservedQuantity,
+			&stock.CreatedAt,
+			&stock.ModifiedAt,
+			&stock.Version,
+			&stock.OverbookingPercent)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		stock.OverbookedQuantity = stock.ReservedQuantity - stock.Quantity
+
+		stocks = append(stocks, stock)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &stocks, nil
+}
+
+// CountOverbookedStocks returns amount of overbooked stocks matching params filters.
+func (p *Postgres) CountOverbookedStocks(ctx context.Context, params model.GetParams, mode model.TotalMode) (int64, error) {
+	query := `
+	SELECT 1 FROM stocks
+	WHERE reserved_quantity > quantity
+		AND ($1 = '' OR warehouse_id::text = $1)
+		AND ($2 = '' OR product_id = $2)`
+
+	total, err := p.count(ctx, query, []any{params.WarehouseFilter, params.ProductFilter}, mode)
+	if err != nil {
+		return 0, fmt.Errorf("p.count(ctx, query, args, mode): %w", err)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+)
+
+// queryBuilder collects conditions of a query. Values never get into query text,
+// they are passed to database as arguments referenced by placeholders.
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// arg adds value to query arguments and returns its placeholder.
+func (b *queryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *queryBuilder) where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// sortColumn is a column which can be used in ORDER BY. Only columns known in advance
+// are put into query text.
+type sortColumn struct {
+	// name is a column or an expression over columns
+	name string
+	// cast is used for cursor values, which are passed as text
+	cast string
+}
+
+const stocksAvailableQuantity = "GREATEST(quantity - reserved_quantity, 0)"
+
+// stocksSortColumns maps sort fields of model.StocksSortFields to stocks columns.
+var stocksSortColumns = map[string]sortColumn{
+	"warehouse_id":       {name: "warehouse_id", cast: "uuid"},
+	"product_id":         {name: "product_id", cast: "text"},
+	"quantity":           {name: "quantity", cast: "int"},
+	"reserved_quantity":  {name: "reserved_quantity", cast: "int"},
+	"available_quantity": {name: stocksAvailableQuantity, cast: "int"},
+	"created_at":         {name: "created_at", cast: "timestamptz"},
+	"modified_at":        {name: "modified_at", cast: "timestamptz"},
+}
+
+// whereRange adds conditions which limit expression by inclusive bounds, if they are set.
+func (b *queryBuilder) whereRange(expression string, minValue, maxValue *uint) {
+	if minValue != nil {
+		b.where(expression + " >= " + b.arg(*minValue))
+	}
+
+	if maxValue != nil {
+		b.where(expression + " <= " + b.arg(*maxValue))
+	}
+}
+
+// count returns amount of rows returned by query. Approximate amount is taken from planner
+// estimation, so matching rows are not scanned.
+func (p *Postgres) count(ctx context.Context, query string, args []any, mode model.TotalMode) (int64, error) {
+	if mode == model.TotalApproximate {
+		query = "EXPLAIN (FORMAT JSON) " + query
+
+		var plan []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+
+		var data []byte
+
+		if err := p.db.QueryRow(ctx, query, args...).Scan(&data); err != nil {
+			return 0, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+		}
+
+		if err := json.Unmarshal(data, &plan); err != nil {
+			return 0, fmt.Errorf("json.Unmarshal(data, &plan): %w", err)
+		}
+
+		if len(plan) == 0 {
+			return 0, nil
+		}
+
+		return int64(plan[0].Plan.Rows), nil
+	}
+
+	query = "SELECT count(*) FROM (" + query + ") AS matching"
+
+	var total int64
+
+	if err := p.db.QueryRow(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// TakeRateLimitToken takes token from bucket with given key, so limit is shared by every instance.
+// It returns zero if token is taken, otherwise time after which the next token is available.
+func (p *Postgres) TakeRateLimitToken(ctx context.Context, key string, limit model.RateLimit) (time.Duration, error) {
+	// upsert locks the row, so concurrent takes of one bucket are serialized.
+	// Bucket is surely full after refill time of the whole burst, then it can be deleted.
+	query := `
+	INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+	VALUES ($1, $3::float8 - 1, now(), now() + $3::float8 / $2 * interval '1 second')
+	ON CONFLICT (key) DO UPDATE
+	SET tokens = LEAST($3::float8,
+			rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at)::float8 * $2) - 1,
+		updated_at = now(),
+		full_at = now() + $3::float8 / $2 * interval '1 second'
+	WHERE LEAST($3::float8,
+		rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at)::float8 * $2) >= 1
+	RETURNING tokens`
+
+	var tokens float64
+
+	err := p.db.QueryRow(ctx, query, key, limit.Rate, float64(limit.Burst)).Scan(&tokens)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return 0, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	default:
+		return 0, nil
+	}
+
+	query = `
+	SELECT LEAST($2::float8, tokens + EXTRACT(EPOCH FROM now() - updated_at)::float8 * $3)
+	FROM rate_limit_buckets
+	WHERE key = $1`
+
+	if err = p.db.QueryRow(ctx, query, key, float64(limit.Burst), limit.Rate).Scan(&tokens); err != nil {
+		return 0, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	// bucket could be refilled between queries, the caller is denied anyway
+	return max(time.Duration((1-tokens)/limit.Rate*float64(time.Second)), time.Millisecond), nil
+}
+
+// DeleteFullRateLimitBuckets deletes buckets which are refilled, they are the same as missing ones.
+func (p *Postgres) DeleteFullRateLimitBuckets(ctx context.Context) (int64, error) {
+	query := `DELETE FROM rate_limit_buckets WHERE full_at < now()`
+
+	commandTag, err := p.db.Exec(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	return commandTag.RowsAffected(), nil
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const dueDatesChannel = "reservations_due_dates"
+
+func (p *Postgres) GetUpcomingDueDates(ctx context.Context, limit uint) ([]time.Time, error) {
+	query := `
+	SELECT due_date FROM reservations
+	WHERE is_active = true
+	ORDER BY due_date
+	LIMIT $1`
+
+	rows, err := p.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	dueDates := make([]time.Time, 0)
+
+	for rows.Next() {
+		var dueDate time.Time
+
+		if err = rows.Scan(&dueDate); err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		dueDates = append(dueDates, dueDate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return dueDates, nil
+}
+
+// ListenDueDates calls fn with the earliest due date of every committed batch of new reservations
+// until ctx is done. Notifications are shared by all service instances connected to the db.
+// Connection is held until ctx is done, it is one of listenerConns.
+func (p *Postgres) ListenDueDates(ctx context.Context, fn func(dueDate time.Time)) error {
+	conn, err := p.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Acquire(ctx): %w", err)
+	}
+
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "LISTEN "+dueDatesChannel); err != nil {
+		return fmt.Errorf("conn.Exec(LISTEN): %w", err)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return fmt.Errorf("conn.Conn().WaitForNotification(ctx): %w", err)
+		}
+
+		dueDate, err := time.Parse(time.RFC3339Nano, notification.Payload)
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("ListenDueDates/time.Parse(time.RFC3339Nano, notification.Payload)")
+
+			continue
+		}
+
+		fn(dueDate)
+	}
+}
+
+// notifyDueDates sends the earliest due date of reservations to listeners when tx commits.
+func notifyDueDates(ctx context.Context, tx pgx.Tx, reservations []model.Reservation) error {
+	if len(reservations) == 0 {
+		return nil
+	}
+
+	earliest := reservations[0].DueDate
+
+	for _, value := range reservations[1:] {
+		if value.DueDate.Before(earliest) {
+			earliest = value.DueDate
+		}
+	}
+
+	query := `SELECT pg_notify($1, $2)`
+
+	_, err := tx.Exec(ctx, query, dueDatesChannel, earliest.Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	return nil
+}
+
+// PreviewDueReservations returns changes which DeactivateDueReservations would make without applying them.
+// Only first limit reservations are listed, while stats and stock deltas cover all of them. Both are read
+// from the same snapshot, so they agree even if reservations change in between.
+func (p *Postgres) PreviewDueReservations(ctx context.Context, asOf *time.Time, limit uint) (*model.DeactivationResult, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("p.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead}): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("PreviewDueReservations/tx.Rollback(ctx)")
+		}
+	}()
+
+	result := model.DeactivationResult{
+		DryRun:       true,
+		Stats:        &model.DeactivationStats{},
+		Reservations: make([]model.Reservation, 0),
+		StockDeltas:  make([]model.StockDelta, 0),
+	}
+
+	query := `
+	SELECT warehouse_id, product_id, count(*), sum(quantity)
+	FROM reservations
+	WHERE is_active = true AND due_date < COALESCE($1::timestamptz, now())
+	GROUP BY warehouse_id, product_id
+	ORDER BY warehouse_id, product_id`
+
+	rows, err := tx.Query(ctx, query, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("tx.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			delta        model.StockDelta
+			reservations int64
+			quantity     int64
+		)
+
+		if err = rows.Scan(&delta.WarehouseID, &delta.ProductID, &reservations, &quantity); err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		delta.ReservedQuantityDelta = -quantity
+
+		result.Stats.Reservations += reservations
+		result.Stats.Stocks++
+		result.StockDeltas = append(result.StockDeltas, delta)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	query = `
+	SELECT id, warehouse_id, product_id, quantity, is_active, created_at, due_date
+	FROM reservations
+	WHERE is_active = true AND due_date < COALESCE($1::timestamptz, now())
+	ORDER BY due_date
+	LIMIT $2`
+
+	rows, err = tx.Query(ctx, query, asOf, limit)
+	if err != nil {
+		return nil, fmt.Errorf("tx.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation model.Reservation
+
+		err = rows.Scan(
+			&reservation.ID,
+			&reservation.WarehouseID,
+			&reservation.ProductID,
+			&reservation.Quantity,
+			&reservation.IsActive,
+			&reservation.CreatedAt,
+			&reservation.DueDate)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		result.Reservations = append(result.Reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ChangeStock applies change to quantity of stock and increments its version. Stock is locked while change
+// is applied, so concurrent changes are serialized. If change.ExpectedVersion differs from version of stock,
+// nothing is changed and StockVersionConflictError with current stock is returned. Decreased quantity
+// has to cover reserved quantity with overbooking allowance, otherwise StockBelowReservedError is returned.
+func (p *Postgres) ChangeStock(ctx context.Context, change model.StockChange) (*model.Stock, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("ChangeStock/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	SELECT s.warehouse_id, s.product_id, s.quantity, s.reserved_quantity, s.created_at, s.modified_at, s.version,
+		COALESCE(p.overbooking_percent, w.overbooking_percent)
+	FROM stocks s
+	JOIN warehouses w ON w.id = s.warehouse_id
+	JOIN products p ON p.sku = s.product_id
+	WHERE s.warehouse_id = $1 AND s.product_id = $2
+	FOR UPDATE OF s`
+
+	var (
+		stock              model.Stock
+		overbookingPercent uint
+	)
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		change.WarehouseID,
+		change.ProductID,
+	).Scan(
+		&stock.WarehouseID,
+		&stock.ProductID,
+		&stock.Quantity,
+		&stock.ReservedQuantity,
+		&stock.CreatedAt,
+		&stock.ModifiedAt,
+		&stock.Version,
+		&overbookingPercent,
+	)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, &model.StockNotFoundError{
+			SKU:         change.ProductID,
+			WarehouseID: change.WarehouseID,
+		}
+	case err != nil:
+		return nil, fmt.Errorf("tx.QueryRow(%s): %w", query, err)
+	}
+
+	stock.AvailableQuantity = model.AvailableQuantity(stock.Quantity, stock.ReservedQuantity)
+
+	if change.ExpectedVersion != nil && *change.ExpectedVersion != stock.Version {
+		return nil, &model.StockVersionConflictError{
+			ExpectedVersion: *change.ExpectedVersion,
+			Current:         stock,
+		}
+	}
+
+	quantity, err := change.Apply(stock.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("change.Apply(stock.Quantity): %w", err)
+	}
+
+	// stock which is already overbooked can still be increased
+	minQuantity := model.MinStockQuantity(stock.ReservedQuantity, overbookingPercent)
+
+	if quantity < stock.Quantity && quantity < minQuantity {
+		return nil, &model.StockBelowReservedError{
+			RequestedQuantity: quantity,
+			MinQuantity:       minQuantity,
+			Current:           stock,
+		}
+	}
+
+	event := model.StockEvent{
+		Kind:             model.StockEventQuantityChanged,
+		WarehouseID:      stock.WarehouseID,
+		ProductID:        stock.ProductID,
+		Quantity:         int64(quantity) - int64(stock.Quantity),
+		ChangeKind:       change.Kind,
+		ReservedQuantity: stock.ReservedQuantity,
+	}
+
+	query = `
+	UPDATE stocks
+	SET quantity = $3, version = version + 1, modified_at = now()
+	WHERE warehouse_id = $1 AND product_id = $2
+	RETURNING quantity, modified_at, version`
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		change.WarehouseID,
+		change.ProductID,
+		quantity,
+	).Scan(
+		&stock.Quantity,
+		&stock.ModifiedAt,
+		&stock.Version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tx.QueryRow(%s): %w", query, err)
+	}
+
+	event.StockQuantity, event.Version = stock.Quantity, stock.Version
+
+	if err = insertStockEvents(ctx, tx, []model.StockEvent{event}); err != nil {
+		return nil, fmt.Errorf("insertStockEvents(ctx, tx, event): %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	stock.AvailableQuantity = model.AvailableQuantity(stock.Quantity, stock.ReservedQuantity)
+
+	return &stock, nil
+}
@@ -14,6 +14,11 @@ import (
 	"go.uber.org/zap"
 )
 
+// listenerConns is the number of pool connections held by LISTEN for the whole lifetime of service:
+// one for due dates of reservations and one for stock events. Pool is extended by them,
+// so they don't take capacity of queries.
+const listenerConns = 2
+
 type Postgres struct {
 	db  *pgxpool.Pool
 	dsn string
@@ -33,9 +38,16 @@ func New(ctx context.Context, cfg *config.Config) (*Postgres, error) {
 
 	dsn := urlScheme.String()
 
-	db, err := pgxpool.New(ctx, dsn)
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.ParseConfig(dsn): %w", err)
+	}
+
+	poolConfig.MaxConns += listenerConns
+
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		return nil, fmt.Errorf("pgxpool.New(ctx, dsn): %w", err)
+		return nil, fmt.Errorf("pgxpool.NewWithConfig(ctx, poolConfig): %w", err)
 	}
 
 	err = db.Ping(ctx)
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -22,6 +24,15 @@ func (p *Postgres) DeleteRow(ctx context.Context, object any) error {
 		if err != nil {
 			return fmt.Errorf("p.db.Exec(ctx, query, v.ProductID, v.WarehouseID): %w", err)
 		}
+
+		for _, table := range []string{"stock_events", "stock_events_outbox"} {
+			query = `DELETE FROM ` + table + ` WHERE product_id = $1 and warehouse_id = $2`
+
+			_, err = p.db.Exec(ctx, query, v.ProductID, v.WarehouseID)
+			if err != nil {
+				return fmt.Errorf("p.db.Exec(ctx, query, v.ProductID, v.WarehouseID): %w", err)
+			}
+		}
 	case model.Reservation:
 		query := `DELETE FROM reservations WHERE id = $1`
 
@@ -29,6 +40,13 @@ func (p *Postgres) DeleteRow(ctx context.Context, object any) error {
 		if err != nil {
 			return fmt.Errorf("p.db.Exec(ctx, query, v.ID): %w", err)
 		}
+
+		query = `DELETE FROM reservations_archive WHERE id = $1`
+
+		_, err = p.db.Exec(ctx, query, v.ID)
+		if err != nil {
+			return fmt.Errorf("p.db.Exec(ctx, query, v.ID): %w", err)
+		}
 	case model.Product:
 		query := `DELETE FROM products WHERE sku = $1`
 
@@ -43,6 +61,27 @@ func (p *Postgres) DeleteRow(ctx context.Context, object any) error {
 		if err != nil {
 			return fmt.Errorf("p.db.Exec(ctx, query, v.ID): %w", err)
 		}
+	case model.APIKey:
+		query := `DELETE FROM api_keys WHERE id = $1`
+
+		_, err := p.db.Exec(ctx, query, v.ID)
+		if err != nil {
+			return fmt.Errorf("p.db.Exec(ctx, query, v.ID): %w", err)
+		}
+	case model.WebhookSubscription:
+		query := `DELETE FROM webhook_deliveries WHERE subscription_id = $1`
+
+		_, err := p.db.Exec(ctx, query, v.ID)
+		if err != nil {
+			return fmt.Errorf("p.db.Exec(ctx, query, v.ID): %w", err)
+		}
+
+		query = `DELETE FROM webhook_subscriptions WHERE id = $1`
+
+		_, err = p.db.Exec(ctx, query, v.ID)
+		if err != nil {
+			return fmt.Errorf("p.db.Exec(ctx, query, v.ID): %w", err)
+		}
 	default:
 		return errors.ErrUnsupported
 	}
@@ -52,8 +91,8 @@ func (p *Postgres) DeleteRow(ctx context.Context, object any) error {
 
 func (p *Postgres) CreateWarehouse(ctx context.Context, warehouse model.Warehouse) (*model.Warehouse, error) {
 	query := `
-	INSERT INTO warehouses (id, name, is_active) 
-	VALUES ($1, $2, $3)
+	INSERT INTO warehouses (id, name, is_active, region) 
+	VALUES ($1, $2, $3, $4)
 	RETURNING created_at`
 
 	err := p.db.QueryRow(
@@ -62,6 +101,7 @@ func (p *Postgres) CreateWarehouse(ctx context.Context, warehouse model.Warehous
 		warehouse.ID,
 		warehouse.Name,
 		warehouse.IsActive,
+		warehouse.Region,
 	).Scan(
 		&warehouse.CreatedAt,
 	)
@@ -78,6 +118,21 @@ func (p *Postgres) CreateWarehouse(ctx context.Context, warehouse model.Warehous
 	return &warehouse, nil
 }
 
+func (p *Postgres) SetWarehouseRegion(ctx context.Context, region model.WarehouseRegion) error {
+	query := `UPDATE warehouses SET region = $1 WHERE id = $2`
+
+	commandTag, err := p.db.Exec(ctx, query, region.Region, region.WarehouseID)
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		return &model.WarehouseNotFoundError{WarehouseID: region.WarehouseID}
+	}
+
+	return nil
+}
+
 func (p *Postgres) CreateProduct(ctx context.Context, product model.Product) (*model.Product, error) {
 	query := `
 	INSERT INTO products (sku, size, name)
@@ -104,7 +159,7 @@ func (p *Postgres) CreateStock(ctx context.Context, stock model.Stock) (*model.S
 	query := `
 	INSERT INTO stocks (warehouse_id, product_id, quantity, reserved_quantity) 
 	VALUES ($1, $2, $3, 0)
-	RETURNING created_at, modified_at`
+	RETURNING created_at, modified_at, version`
 
 	err := p.db.QueryRow(
 		ctx,
@@ -115,11 +170,14 @@ func (p *Postgres) CreateStock(ctx context.Context, stock model.Stock) (*model.S
 	).Scan(
 		&stock.CreatedAt,
 		&stock.ModifiedAt,
+		&stock.Version,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
 	}
 
+	stock.AvailableQuantity = stock.Quantity
+
 	return &stock, nil
 }
 
@@ -136,48 +194,89 @@ func (p *Postgres) CreateReservations(ctx context.Context, reservations []model.
 		}
 	}()
 
+	events := make([]model.StockEvent, 0, len(reservations))
+
 	for i, value := range reservations {
 		query := `
-		UPDATE stocks 
-		SET reserved_quantity = reserved_quantity + $1, modified_at = now() 
-		WHERE warehouse_id = $2 AND product_id = $3
-		RETURNING modified_at, quantity`
-
-		var freeStock model.Stock
+		SELECT s.quantity, s.reserved_quantity, COALESCE(p.overbooking_percent, w.overbooking_percent)
+		FROM stocks s
+		JOIN warehouses w ON w.id = s.warehouse_id
+		JOIN products p ON p.sku = s.product_id
+		WHERE s.warehouse_id = $1 AND s.product_id = $2
+		FOR UPDATE OF s`
+
+		var (
+			stock              model.Stock
+			overbookingPercent uint
+		)
 
 		err = tx.QueryRow(
 			ctx,
 			query,
-			value.Quantity,
 			value.WarehouseID,
 			value.ProductID,
 		).Scan(
-			&freeStock.ModifiedAt,
-			&freeStock.Quantity,
+			&stock.Quantity,
+			&stock.ReservedQuantity,
+			&overbookingPercent,
 		)
 
-		var pgErr *pgconn.PgError
-
 		switch {
-		case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation:
-			return nil, &model.NotEnoughQuantityError{
-				SKU:              value.ProductID,
-				RequiredQuantity: value.Quantity,
-				WarehouseID:      value.WarehouseID,
-			}
 		case errors.Is(err, pgx.ErrNoRows):
-			return nil, &model.StockNotFoundError{
+			return nil, &model.ItemError{Index: i, Err: &model.StockNotFoundError{
 				SKU:         value.ProductID,
 				WarehouseID: value.WarehouseID,
-			}
+			}}
 		case err != nil:
 			return nil, fmt.Errorf("tx.QueryRow(%s): %w", query, err)
 		}
 
+		limit := model.ReservationLimit(stock.Quantity, overbookingPercent)
+
+		if stock.ReservedQuantity+value.Quantity > limit {
+			return nil, &model.ItemError{Index: i, Err: &model.NotEnoughQuantityError{
+				SKU:               value.ProductID,
+				RequiredQuantity:  value.Quantity,
+				WarehouseID:       value.WarehouseID,
+				AvailableQuantity: model.AvailableQuantity(limit, stock.ReservedQuantity),
+			}}
+		}
+
+		query = `
+		UPDATE stocks 
+		SET reserved_quantity = reserved_quantity + $1, version = version + 1, modified_at = now() 
+		WHERE warehouse_id = $2 AND product_id = $3
+		RETURNING quantity, reserved_quantity, version`
+
+		event := model.StockEvent{
+			Kind:          model.StockEventReserved,
+			WarehouseID:   value.WarehouseID,
+			ProductID:     value.ProductID,
+			ReservationID: &reservations[i].ID,
+			Quantity:      int64(value.Quantity),
+		}
+
+		err = tx.QueryRow(
+			ctx,
+			query,
+			value.Quantity,
+			value.WarehouseID,
+			value.ProductID,
+		).Scan(
+			&event.StockQuantity,
+			&event.ReservedQuantity,
+			&event.Version,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("tx.QueryRow(%s): %w", query, err)
+		}
+
+		events = append(events, event)
+
 		query = `
 		INSERT INTO reservations (id, warehouse_id, product_id, quantity, due_date) 
 		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, warehouse_id, product_id, quantity, created_at, due_date`
+		RETURNING id, warehouse_id, product_id, quantity, is_active, created_at, due_date`
 
 		err = tx.QueryRow(
 			ctx,
@@ -192,16 +291,41 @@ func (p *Postgres) CreateReservations(ctx context.Context, reservations []model.
 			&reservations[i].WarehouseID,
 			&reservations[i].ProductID,
 			&reservations[i].Quantity,
+			&reservations[i].IsActive,
 			&reservations[i].CreatedAt,
 			&reservations[i].DueDate,
 		)
 
+		var pgErr *pgconn.PgError
+
 		switch {
 		case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
-			return nil, &model.DuplicateReservationError{ReservationID: value.ID}
+			return nil, &model.ItemError{Index: i, Err: &model.DuplicateReservationError{ReservationID: value.ID}}
 		case err != nil:
 			return nil, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
 		}
+
+		// archive is checked by a separate statement, which takes a new snapshot. If archiver was moving
+		// reservation with the same id, insert has waited for it on primary key and its row is seen here
+		query = `SELECT EXISTS (SELECT 1 FROM reservations_archive WHERE id = $1)`
+
+		var isArchived bool
+
+		if err = tx.QueryRow(ctx, query, value.ID).Scan(&isArchived); err != nil {
+			return nil, fmt.Errorf("tx.QueryRow(%s): %w", query, err)
+		}
+
+		if isArchived {
+			return nil, &model.ItemError{Index: i, Err: &model.DuplicateReservationError{ReservationID: value.ID}}
+		}
+	}
+
+	if err = notifyDueDates(ctx, tx, reservations); err != nil {
+		return nil, fmt.Errorf("notifyDueDates(ctx, tx, reservations): %w", err)
+	}
+
+	if err = insertStockEvents(ctx, tx, events); err != nil {
+		return nil, fmt.Errorf("insertStockEvents(ctx, tx, events): %w", err)
 	}
 
 	err = tx.Commit(ctx)
@@ -212,84 +336,161 @@ func (p *Postgres) CreateReservations(ctx context.Context, reservations []model.
 	return &reservations, nil
 }
 
-//nolint:cyclop
-func (p *Postgres) DeactivateDueReservations(ctx context.Context) error {
+// DeactivateDueReservations releases reservations due before asOf, or before now if asOf is nil, in batches
+// of batchSize. Every batch is committed separately, skips rows locked by concurrent transactions
+// and decrements every affected stock once.
+func (p *Postgres) DeactivateDueReservations(
+	ctx context.Context,
+	fence *model.LeaseFence,
+	asOf *time.Time,
+	batchSize uint,
+) (*model.DeactivationStats, error) {
+	startedAt := time.Now()
+
+	var stats model.DeactivationStats
+
+	// stock released by several batches is counted once
+	stocks := make(map[string]struct{})
+
+	for {
+		reservations, batchStocks, err := p.deactivateDueBatch(ctx, fence, asOf, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("p.deactivateDueBatch(ctx, fence, asOf, batchSize): %w", err)
+		}
+
+		if reservations == 0 {
+			break
+		}
+
+		stats.Batches++
+		stats.Reservations += reservations
+
+		for key := range batchStocks {
+			stocks[key] = struct{}{}
+		}
+
+		if reservations < int64(batchSize) {
+			break
+		}
+	}
+
+	stats.Stocks = int64(len(stocks))
+	stats.Duration = time.Since(startedAt)
+
+	return &stats, nil
+}
+
+// deactivateDueBatch releases single batch of due reservations and writes expired event for every one of them.
+// It returns amount of released reservations and keys of their stocks.
+func (p *Postgres) deactivateDueBatch(
+	ctx context.Context,
+	fence *model.LeaseFence,
+	asOf *time.Time,
+	batchSize uint,
+) (int64, map[string]struct{}, error) {
 	tx, err := p.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("p.db.Begin(ctx): %w", err)
+		return 0, nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
 	}
 
 	defer func() {
 		err := tx.Rollback(ctx)
 		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
-			zap.L().With(zap.Error(err)).Warn("DeactivateDueReservations/tx.Rollback(ctx)")
+			zap.L().With(zap.Error(err)).Warn("deactivateDueBatch/tx.Rollback(ctx)")
 		}
 	}()
 
-	query := `
-	UPDATE reservations 
-	SET is_active = false 
-	WHERE due_date < now() AND is_active = true 
-	RETURNING product_id, warehouse_id, quantity`
+	if err = checkFence(ctx, tx, fence); err != nil {
+		return 0, nil, fmt.Errorf("checkFence(ctx, tx, fence): %w", err)
+	}
 
-	rows, err := tx.Query(
-		ctx,
-		query)
+	query := `
+	WITH due AS (
+		SELECT id FROM reservations
+		WHERE is_active = true AND due_date < COALESCE($2::timestamptz, now())
+		ORDER BY due_date
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	), released AS (
+		UPDATE reservations r
+		SET is_active = false, deactivated_at = now()
+		FROM due
+		WHERE r.id = due.id
+		RETURNING r.id, r.warehouse_id, r.product_id, r.quantity, r.due_date
+	), released_stocks AS (
+		SELECT warehouse_id, product_id, sum(quantity) AS quantity, count(*) AS reservations
+		FROM released
+		GROUP BY warehouse_id, product_id
+	), updated_stocks AS (
+		UPDATE stocks s
+		SET reserved_quantity = s.reserved_quantity - rs.quantity, version = s.version + rs.reservations,
+			modified_at = now()
+		FROM released_stocks rs
+		WHERE s.warehouse_id = rs.warehouse_id AND s.product_id = rs.product_id
+		RETURNING s.warehouse_id, s.product_id, s.quantity, s.reserved_quantity, s.version
+	)
+	SELECT r.id, r.warehouse_id, r.product_id, r.quantity, us.quantity,
+		us.reserved_quantity + COALESCE(sum(r.quantity) OVER (
+			PARTITION BY r.warehouse_id, r.product_id
+			ORDER BY r.due_date, r.id
+			ROWS BETWEEN 1 FOLLOWING AND UNBOUNDED FOLLOWING), 0),
+		us.version - count(*) OVER (
+			PARTITION BY r.warehouse_id, r.product_id
+			ORDER BY r.due_date, r.id
+			ROWS BETWEEN 1 FOLLOWING AND UNBOUNDED FOLLOWING)
+	FROM released r
+	JOIN updated_stocks us ON us.warehouse_id = r.warehouse_id AND us.product_id = r.product_id
+	ORDER BY r.due_date, r.id`
+
+	rows, err := tx.Query(ctx, query, batchSize, asOf)
 	if err != nil {
-		return fmt.Errorf("tx.Query(%s): %w", query, err)
+		return 0, nil, fmt.Errorf("tx.Query(%s): %w", query, err)
 	}
 
 	defer rows.Close()
 
-	releasedReservations := make([]model.Reservation, 0)
+	// reservations of a stock are released one by one in order of due dates, so every event describes
+	// the stock right after its reservation is released, as if they were released separately
+	events := make([]model.StockEvent, 0)
 
 	for rows.Next() {
-		var releasedReservation model.Reservation
+		event := model.StockEvent{Kind: model.StockEventExpired}
 
 		err = rows.Scan(
-			&releasedReservation.ProductID,
-			&releasedReservation.WarehouseID,
-			&releasedReservation.Quantity,
+			&event.ReservationID,
+			&event.WarehouseID,
+			&event.ProductID,
+			&event.Quantity,
+			&event.StockQuantity,
+			&event.ReservedQuantity,
+			&event.Version,
 		)
 		if err != nil {
-			return fmt.Errorf("rows.Scan(&reservationQuantity): %w", err)
+			return 0, nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
 		}
 
-		releasedReservations = append(releasedReservations, releasedReservation)
+		events = append(events, event)
 	}
 
-	if len(releasedReservations) == 0 {
-		return nil
+	if err = rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("rows.Err(): %w", err)
 	}
 
-	for _, value := range releasedReservations {
-		query = `
-		UPDATE stocks 
-		SET reserved_quantity = reserved_quantity - $1, modified_at = now() 
-		WHERE product_id = $2 and warehouse_id = $3
-		RETURNING modified_at, reserved_quantity`
+	stocks := make(map[string]struct{}, len(events))
 
-		commandTag, err := tx.Exec(
-			ctx,
-			query,
-			value.Quantity,
-			value.ProductID,
-			value.WarehouseID,
-		)
-		if err != nil {
-			return fmt.Errorf("tx.Exec(%s): %w", query, err)
-		}
+	for _, event := range events {
+		stocks[event.WarehouseID.String()+"/"+event.ProductID] = struct{}{}
+	}
 
-		if commandTag.RowsAffected() != 1 {
-			return fmt.Errorf("tx.Exec(%s): %w", query, model.ErrNoRowsAffected)
-		}
+	if err = insertStockEvents(ctx, tx, events); err != nil {
+		return 0, nil, fmt.Errorf("insertStockEvents(ctx, tx, events): %w", err)
 	}
 
 	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("tx.Commit(ctx): %w", err)
+		return 0, nil, fmt.Errorf("tx.Commit(ctx): %w", err)
 	}
 
-	return nil
+	return int64(len(events)), stocks, nil
 }
 
 func (p *Postgres) DeleteReservations(ctx context.Context, reservations []model.Reservation) error {
@@ -305,11 +506,13 @@ func (p *Postgres) DeleteReservations(ctx context.Context, reservations []model.
 		}
 	}()
 
-	for _, value := range reservations {
+	events := make([]model.StockEvent, 0, len(reservations))
+
+	for i, value := range reservations {
 		reservation := value
 		query := `
 			UPDATE reservations 
-			SET is_active = false 
+			SET is_active = false, deactivated_at = now()
 			WHERE is_active = true AND id = $1
 			RETURNING product_id, warehouse_id, quantity`
 
@@ -325,31 +528,49 @@ func (p *Postgres) DeleteReservations(ctx context.Context, reservations []model.
 
 		switch {
 		case errors.Is(err, pgx.ErrNoRows):
-			return &model.ReservationNotFoundError{ReservationID: reservation.ID}
+			return &model.ItemError{Index: i, Err: &model.ReservationNotFoundError{ReservationID: reservation.ID}}
 		case err != nil:
 			return fmt.Errorf("tx.QueryRow(%s): %w", query, err)
 		}
 
 		query = `
 		UPDATE stocks 
-		SET reserved_quantity = reserved_quantity - $1, modified_at = now() 
+		SET reserved_quantity = reserved_quantity - $1, version = version + 1, modified_at = now() 
 		WHERE warehouse_id = $2 AND product_id = $3
-		RETURNING modified_at`
+		RETURNING quantity, reserved_quantity, version`
+
+		event := model.StockEvent{
+			Kind:          model.StockEventReleased,
+			WarehouseID:   reservation.WarehouseID,
+			ProductID:     reservation.ProductID,
+			ReservationID: &reservations[i].ID,
+			Quantity:      int64(reservation.Quantity),
+		}
 
-		commandTag, err := tx.Exec(
+		err = tx.QueryRow(
 			ctx,
 			query,
 			reservation.Quantity,
 			reservation.WarehouseID,
 			reservation.ProductID,
+		).Scan(
+			&event.StockQuantity,
+			&event.ReservedQuantity,
+			&event.Version,
 		)
-		if err != nil {
+
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("tx.QueryRow(%s): %w", query, model.ErrNoRowsAffected)
+		case err != nil:
 			return fmt.Errorf("tx.QueryRow(%s): %w", query, err)
 		}
 
-		if commandTag.RowsAffected() != 1 {
-			return fmt.Errorf("\"tx.QueryRow(%s): %w", query, model.ErrNoRowsAffected)
-		}
+		events = append(events, event)
+	}
+
+	if err = insertStockEvents(ctx, tx, events); err != nil {
+		return fmt.Errorf("insertStockEvents(ctx, tx, events): %w", err)
 	}
 
 	if err = tx.Commit(ctx); err != nil {
@@ -359,35 +580,139 @@ func (p *Postgres) DeleteReservations(ctx context.Context, reservations []model.
 	return nil
 }
 
-func (p *Postgres) GetStocks(ctx context.Context, params model.GetParams) (*[]model.Stock, error) {
-	query := `SELECT warehouse_id, product_id, quantity, reserved_quantity, created_at, modified_at FROM stocks `
+// stocksOrder returns columns which define order of stocks. Primary key columns are always
+// included, so the order is strict and can be used with cursor.
+func stocksOrder(sorting string) ([]sortColumn, error) {
+	warehouseID, productID := stocksSortColumns["warehouse_id"], stocksSortColumns["product_id"]
+
+	switch sorting {
+	case "", "warehouse_id":
+		return []sortColumn{warehouseID, productID}, nil
+	case "product_id":
+		return []sortColumn{productID, warehouseID}, nil
+	}
+
+	column, ok := stocksSortColumns[sorting]
+	if !ok {
+		return nil, &model.InvalidSortFieldError{Field: sorting, Allowed: model.StocksSortFields}
+	}
+
+	return []sortColumn{column, warehouseID, productID}, nil
+}
 
-	var conditions []string
+// stocksFilter adds conditions of params to a query over stocks.
+func stocksFilter(params model.GetParams) *queryBuilder {
+	var builder queryBuilder
 
 	if params.WarehouseFilter != "" {
-		conditions = append(conditions, fmt.Sprintf("warehouse_id = '%s'", params.WarehouseFilter))
+		builder.where("warehouse_id = " + builder.arg(params.WarehouseFilter) + "::uuid")
 	}
 
 	if params.ProductFilter != "" {
-		conditions = append(conditions, fmt.Sprintf("product_id = '%s'", params.ProductFilter))
+		builder.where("product_id = " + builder.arg(params.ProductFilter))
+	}
+
+	if len(params.WarehouseIDs) > 0 {
+		builder.where("warehouse_id = ANY(" + builder.arg(params.WarehouseIDs) + "::uuid[])")
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	if len(params.ProductIDs) > 0 {
+		builder.where("product_id = ANY(" + builder.arg(params.ProductIDs) + "::text[])")
+	}
+
+	builder.whereRange("quantity", params.MinQuantity, params.MaxQuantity)
+	builder.whereRange("reserved_quantity", params.MinReservedQuantity, params.MaxReservedQuantity)
+	builder.whereRange(stocksAvailableQuantity, params.MinAvailableQuantity, params.MaxAvailableQuantity)
+
+	if params.ModifiedSince != nil {
+		builder.where("modified_at >= " + builder.arg(*params.ModifiedSince))
 	}
 
-	if params.Sorting != "" {
-		query += " ORDER BY " + params.Sorting
-		if params.Descending {
-			query += " DESC"
+	if params.InStockOnly {
+		builder.where("quantity > reserved_quantity")
+	}
+
+	return &builder
+}
+
+// CountStocks returns amount of stocks matching params filters.
+func (p *Postgres) CountStocks(ctx context.Context, params model.GetParams, mode model.TotalMode) (int64, error) {
+	builder := stocksFilter(params)
+
+	total, err := p.count(ctx, `SELECT 1 FROM stocks`+builder.whereClause(), builder.args, mode)
+	if err != nil {
+		return 0, fmt.Errorf("p.count(ctx, query, builder.args, mode): %w", err)
+	}
+
+	return total, nil
+}
+
+// GetStocks returns stocks ordered by params.Sorting and then by primary key. If after is not nil,
+// only stocks placed after it are returned and params.Offset is ignored.
+func (p *Postgres) GetStocks(
+	ctx context.Context,
+	params model.GetParams,
+	after *model.StocksCursor,
+) (*[]model.Stock, error) {
+	order, err := stocksOrder(params.Sorting)
+	if err != nil {
+		return nil, fmt.Errorf("stocksOrder(params.Sorting): %w", err)
+	}
+
+	builder := stocksFilter(params)
+
+	comparison := ">"
+	direction := ""
+
+	if params.Descending {
+		comparison = "<"
+		direction = " DESC"
+	}
+
+	columns := make([]string, 0, len(order))
+	orderBy := make([]string, 0, len(order))
+
+	for _, column := range order {
+		columns = append(columns, column.name)
+		orderBy = append(orderBy, column.name+direction)
+	}
+
+	if after != nil {
+		values := make([]string, 0, len(order))
+
+		for _, column := range order {
+			var value any
+
+			switch column.name {
+			case "warehouse_id":
+				value = after.WarehouseID
+			case "product_id":
+				value = after.ProductID
+			default:
+				value = after.Value
+			}
+
+			values = append(values, builder.arg(value)+"::"+column.cast)
 		}
+
+		builder.where(fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(columns, ", "), comparison, strings.Join(values, ", ")))
+	}
+
+	query := `SELECT warehouse_id, product_id, quantity, reserved_quantity, created_at, modified_at, version FROM stocks` +
+		builder.whereClause() +
+		" ORDER BY " + strings.Join(orderBy, ", ")
+
+	if after == nil {
+		query += " OFFSET " + builder.arg(params.Offset)
 	}
 
-	query += fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+	query += " LIMIT " + builder.arg(params.Limit)
 
 	rows, err := p.db.Query(
 		ctx,
-		query)
+		query,
+		builder.args...)
 	if err != nil {
 		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
 	}
@@ -405,13 +730,113 @@ func (p *Postgres) GetStocks(ctx context.Context, params model.GetParams) (*[]mo
 			&stock.Quantity,
 			&stock.ReservedQuantity,
 			&stock.CreatedAt,
-			&stock.ModifiedAt)
+			&stock.ModifiedAt,
+			&stock.Version)
 		if err != nil {
 			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
 		}
 
+		stock.AvailableQuantity = model.AvailableQuantity(stock.Quantity, stock.ReservedQuantity)
+
 		stocks = append(stocks, stock)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
 	return &stocks, nil
 }
+
+func (p *Postgres) GetStock(ctx context.Context, warehouseID uuid.UUID, productID string) (*model.Stock, error) {
+	query := `
+	SELECT warehouse_id, product_id, quantity, reserved_quantity, created_at, modified_at, version
+	FROM stocks
+	WHERE warehouse_id = $1 AND product_id = $2`
+
+	var stock model.Stock
+
+	err := p.db.QueryRow(
+		ctx,
+		query,
+		warehouseID,
+		productID,
+	).Scan(
+		&stock.WarehouseID,
+		&stock.ProductID,
+		&stock.Quantity,
+		&stock.ReservedQuantity,
+		&stock.CreatedAt,
+		&stock.ModifiedAt,
+		&stock.Version,
+	)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, &model.StockNotFoundError{
+			SKU:         productID,
+			WarehouseID: warehouseID,
+		}
+	case err != nil:
+		return nil, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	stock.AvailableQuantity = model.AvailableQuantity(stock.Quantity, stock.ReservedQuantity)
+
+	return &stock, nil
+}
+
+// UpdateReservationDueDate extends or shortens active reservation. Expiry notification will be sent again.
+func (p *Postgres) UpdateReservationDueDate(ctx context.Context, id uuid.UUID, dueDate time.Time) (*model.Reservation, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("UpdateReservationDueDate/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	UPDATE reservations 
+	SET due_date = $2, expiry_notified_at = NULL
+	WHERE is_active = true AND id = $1
+	RETURNING id, warehouse_id, product_id, quantity, is_active, created_at, due_date`
+
+	var reservation model.Reservation
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		id,
+		dueDate,
+	).Scan(
+		&reservation.ID,
+		&reservation.WarehouseID,
+		&reservation.ProductID,
+		&reservation.Quantity,
+		&reservation.IsActive,
+		&reservation.CreatedAt,
+		&reservation.DueDate,
+	)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, &model.ReservationNotFoundError{ReservationID: id}
+	case err != nil:
+		return nil, fmt.Errorf("tx.QueryRow(%s): %w", query, err)
+	}
+
+	if err = notifyDueDates(ctx, tx, []model.Reservation{reservation}); err != nil {
+		return nil, fmt.Errorf("notifyDueDates(ctx, tx, reservation): %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return &reservation, nil
+}
@@ -0,0 +1,388 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const webhookDeliveryColumns = `
+	d.id, d.subscription_id, d.event_type, d.event_id, d.payload, d.status, d.attempts,
+	CASE WHEN d.status = 'pending' THEN d.next_attempt_at END,
+	d.last_status_code, COALESCE(d.last_error, ''), d.created_at, d.delivered_at`
+
+func (p *Postgres) CreateWebhookSubscription(
+	ctx context.Context,
+	subscription model.WebhookSubscription,
+) (*model.WebhookSubscription, error) {
+	query := `
+	INSERT INTO webhook_subscriptions (id, url, secret, event_types, low_stock_threshold)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING created_at`
+
+	err := p.db.QueryRow(
+		ctx,
+		query,
+		subscription.ID,
+		subscription.URL,
+		subscription.Secret,
+		eventTypesToStrings(subscription.EventTypes),
+		subscription.LowStockThreshold,
+	).Scan(
+		&subscription.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	return &subscription, nil
+}
+
+// GetWebhookSubscriptions returns subscriptions which aren't deleted. Secrets aren't returned.
+func (p *Postgres) GetWebhookSubscriptions(ctx context.Context) (*[]model.WebhookSubscription, error) {
+	query := `
+	SELECT id, url, event_types, low_stock_threshold, created_at
+	FROM webhook_subscriptions
+	WHERE deleted_at IS NULL
+	ORDER BY created_at`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	subscriptions := make([]model.WebhookSubscription, 0)
+
+	for rows.Next() {
+		var (
+			subscription model.WebhookSubscription
+			eventTypes   []string
+		)
+
+		err = rows.Scan(
+			&subscription.ID,
+			&subscription.URL,
+			&eventTypes,
+			&subscription.LowStockThreshold,
+			&subscription.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		subscription.EventTypes = make([]model.WebhookEventType, 0, len(eventTypes))
+
+		for _, eventType := range eventTypes {
+			subscription.EventTypes = append(subscription.EventTypes, model.WebhookEventType(eventType))
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &subscriptions, nil
+}
+
+// DeleteWebhookSubscription stops deliveries to subscription. Pending deliveries become dead,
+// the delivery log is kept.
+func (p *Postgres) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("DeleteWebhookSubscription/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `UPDATE webhook_subscriptions SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := tx.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return model.ErrWebhookNotFound
+	}
+
+	query = `
+	UPDATE webhook_deliveries
+	SET status = $2, last_error = 'subscription deleted'
+	WHERE subscription_id = $1 AND status = $3`
+
+	if _, err = tx.Exec(ctx, query, id, model.WebhookDeliveryDead, model.WebhookDeliveryPending); err != nil {
+		return fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhookCursor returns id of the last stock event turned into deliveries.
+func (p *Postgres) GetWebhookCursor(ctx context.Context) (int64, error) {
+	query := `SELECT last_event_id FROM webhook_cursor WHERE id = 1`
+
+	var id int64
+
+	if err := p.db.QueryRow(ctx, query).Scan(&id); err != nil {
+		return 0, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	return id, nil
+}
+
+// CreateWebhookDeliveries writes deliveries of events up to lastEventID and moves cursor to it in one transaction,
+// so every event is turned into deliveries exactly once. Cursor is moved only from prevEventID, otherwise
+// deliveries are rolled back and ErrWebhookCursorMoved is returned.
+func (p *Postgres) CreateWebhookDeliveries(
+	ctx context.Context,
+	deliveries []model.WebhookDelivery,
+	prevEventID int64,
+	lastEventID int64,
+) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("CreateWebhookDeliveries/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	INSERT INTO webhook_deliveries (id, subscription_id, event_type, event_id, payload)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	batch := &pgx.Batch{}
+
+	for _, delivery := range deliveries {
+		batch.Queue(query, delivery.ID, delivery.SubscriptionID, delivery.EventType, delivery.EventID, delivery.Payload)
+	}
+
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("tx.SendBatch(ctx, batch).Close(): %w", err)
+	}
+
+	query = `UPDATE webhook_cursor SET last_event_id = $2 WHERE id = 1 AND last_event_id = $1`
+
+	commandTag, err := tx.Exec(ctx, query, prevEventID, lastEventID)
+	if err != nil {
+		return fmt.Errorf("tx.Exec(%s): %w", query, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return model.ErrWebhookCursorMoved
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries which are due, together with url and secret
+// of their subscriptions. Claimed deliveries are postponed until leaseUntil, so they aren't sent twice
+// while being sent, but are sent again if sender stops before updating them.
+func (p *Postgres) ClaimWebhookDeliveries(
+	ctx context.Context,
+	limit uint,
+	leaseUntil time.Time,
+) (*[]model.WebhookDelivery, error) {
+	query := `
+	UPDATE webhook_deliveries d
+	SET next_attempt_at = $2
+	FROM webhook_subscriptions s
+	WHERE s.id = d.subscription_id AND d.id IN (
+		SELECT id
+		FROM webhook_deliveries
+		WHERE status = $3 AND next_attempt_at <= now()
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED)
+	RETURNING` + webhookDeliveryColumns + `, s.url, s.secret`
+
+	rows, err := p.db.Query(ctx, query, limit, leaseUntil, model.WebhookDeliveryPending)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]model.WebhookDelivery, 0)
+
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+
+		if err = rows.Scan(append(webhookDeliveryFields(&delivery), &delivery.URL, &delivery.Secret)...); err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &deliveries, nil
+}
+
+// UpdateWebhookDelivery saves result of delivery attempt. Deliveries which stopped being pending while they were
+// sent, like ones of deleted subscription, are left as they are.
+func (p *Postgres) UpdateWebhookDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $2, attempts = $3, next_attempt_at = COALESCE($4, next_attempt_at), last_status_code = $5,
+		last_error = NULLIF($6, ''), delivered_at = $7
+	WHERE id = $1 AND status = 'pending'`
+
+	_, err := p.db.Exec(
+		ctx,
+		query,
+		delivery.ID,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.DeliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries returns delivery log of subscription, the latest deliveries first.
+func (p *Postgres) GetWebhookDeliveries(
+	ctx context.Context,
+	params model.GetWebhookDeliveriesParams,
+) (*[]model.WebhookDelivery, error) {
+	var builder queryBuilder
+
+	builder.where("d.subscription_id = " + builder.arg(params.SubscriptionID))
+
+	if params.Status != "" {
+		builder.where("d.status = " + builder.arg(params.Status))
+	}
+
+	query := `SELECT` + webhookDeliveryColumns + `
+	FROM webhook_deliveries d` +
+		builder.whereClause() +
+		" ORDER BY d.created_at DESC, d.id" +
+		" OFFSET " + builder.arg(params.Offset) +
+		" LIMIT " + builder.arg(params.Limit)
+
+	rows, err := p.db.Query(ctx, query, builder.args...)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(%s): %w", query, err)
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]model.WebhookDelivery, 0)
+
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+
+		if err = rows.Scan(webhookDeliveryFields(&delivery)...); err != nil {
+			return nil, fmt.Errorf("rows.Scan(%s): %w", query, err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return &deliveries, nil
+}
+
+// RetryWebhookDelivery sends dead delivery again with a fresh set of attempts.
+// Deliveries of deleted subscriptions can't be retried.
+func (p *Postgres) RetryWebhookDelivery(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	query := `
+	UPDATE webhook_deliveries d
+	SET status = $2, attempts = 0, next_attempt_at = now()
+	FROM webhook_subscriptions s
+	WHERE d.id = $1 AND d.status = $3 AND s.id = d.subscription_id AND s.deleted_at IS NULL
+	RETURNING` + webhookDeliveryColumns
+
+	var delivery model.WebhookDelivery
+
+	err := p.db.QueryRow(ctx, query, id, model.WebhookDeliveryPending, model.WebhookDeliveryDead).
+		Scan(webhookDeliveryFields(&delivery)...)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, model.ErrDeliveryNotFound
+	case err != nil:
+		return nil, fmt.Errorf("p.db.QueryRow(%s): %w", query, err)
+	}
+
+	return &delivery, nil
+}
+
+// DeleteWebhookDeliveries removes delivered and dead deliveries created before olderThan.
+// Pending deliveries are kept until they are sent or become dead.
+func (p *Postgres) DeleteWebhookDeliveries(ctx context.Context, olderThan time.Time) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE created_at < $1 AND status <> $2`
+
+	commandTag, err := p.db.Exec(ctx, query, olderThan, model.WebhookDeliveryPending)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.Exec(%s): %w", query, err)
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+func webhookDeliveryFields(delivery *model.WebhookDelivery) []any {
+	return []any{
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventType,
+		&delivery.EventID,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	}
+}
+
+func eventTypesToStrings(eventTypes []model.WebhookEventType) []string {
+	result := make([]string, 0, len(eventTypes))
+
+	for _, eventType := range eventTypes {
+		result = append(result, string(eventType))
+	}
+
+	return result
+}
@@ -1,33 +1,72 @@
 package tests
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	reservationsv1 "github.com/Saaghh/lamoda-hr/api/proto/reservations/v1"
 	"github.com/Saaghh/lamoda-hr/internal/apiserver"
+	"github.com/Saaghh/lamoda-hr/internal/auth"
 	"github.com/Saaghh/lamoda-hr/internal/config"
+	"github.com/Saaghh/lamoda-hr/internal/grpcserver"
 	"github.com/Saaghh/lamoda-hr/internal/logger"
 	"github.com/Saaghh/lamoda-hr/internal/model"
+	"github.com/Saaghh/lamoda-hr/internal/notifier"
+	"github.com/Saaghh/lamoda-hr/internal/ratelimit"
 	"github.com/Saaghh/lamoda-hr/internal/service"
 	"github.com/Saaghh/lamoda-hr/internal/store"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	migrate "github.com/rubenv/sql-migrate"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
 	bindAddr                   = "http://localhost:8081/api/v1"
+	bindAddrV2                 = "http://localhost:8081/api/v2"
+	healthAddr                 = "http://localhost:8081"
 	createReservationsEndpoint = "/createReservations"
 	deleteReservationsEndpoint = "/deleteReservations"
 	getStocksEndpoint          = "/getStocks"
+	getAvailabilityEndpoint    = "/getAvailability"
+	grpcBindAddr               = "localhost:9091"
+	setOverbookingEndpoint     = "/setOverbookingAllowance"
+	getOverbookedEndpoint      = "/getOverbookedStocks"
+	getWorkerLeasesEndpoint    = "/getWorkerLeases"
+	deactivateEndpoint         = "/admin/deactivateDueReservations"
+	getReservationsEndpoint    = "/getReservations"
+	jwtSecret                  = "integration-tests-secret"
+	archiverRetentionAge       = 2 * time.Second
 )
 
 type IntegrationTestSuite struct {
@@ -40,9 +79,26 @@ type IntegrationTestSuite struct {
 	products     []model.Product
 	stocks       []model.Stock
 	reservations []model.Reservation
+
+	expiryNotifications chan notifier.Message
+
+	grpcClient reservationsv1.ReservationServiceClient
+
+	service *service.Service
+	apiKeys []model.APIKey
+	// apiKey is an admin key sent with every request
+	apiKey string
+	// rsaKey and ecKey sign bearer tokens verified by jwks
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
 }
 
 func (s *IntegrationTestSuite) TearDownSuite() {
+	for _, value := range s.apiKeys {
+		err := s.str.DeleteRow(context.Background(), value)
+		s.Require().NoError(err)
+	}
+
 	for _, value := range s.reservations {
 		err := s.str.DeleteRow(context.Background(), value)
 		s.Require().NoError(err)
@@ -99,19 +155,170 @@ func (s *IntegrationTestSuite) SetupSuite() {
 
 	serviceLayer := service.New(pgStore)
 
-	server := apiserver.New(apiserver.Config{BindAddress: ":8081"}, serviceLayer)
+	s.service = serviceLayer
+	s.apiKey = s.createAPIKey(model.ScopeAdmin)
+
+	verifier, err := auth.NewJWTVerifier(auth.JWTConfig{Secret: jwtSecret, JWKSFile: s.createJWKS()})
+	s.Require().NoError(err)
+
+	serviceLayer.SetTokenVerifier(verifier)
+	// webhook receivers of tests listen on loopback
+	serviceLayer.AllowPrivateWebhookHosts(true)
+
+	server := apiserver.New(
+		apiserver.Config{BindAddress: ":8081", ValidateResponses: true, AuthEnabled: true},
+		serviceLayer)
 
 	go func() {
 		err := server.Run(ctx)
 		s.Require().NoError(err)
 	}()
 
+	grpcServer := grpcserver.New(grpcserver.Config{BindAddress: ":9091", AuthEnabled: true}, serviceLayer)
+
+	go func() {
+		err := grpcServer.Run(ctx)
+		s.Require().NoError(err)
+	}()
+
+	grpcConn, err := grpc.NewClient(
+		grpcBindAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(
+			ctx context.Context,
+			method string,
+			req, reply any,
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			return invoker(metadata.AppendToOutgoingContext(ctx, "x-api-key", s.apiKey), method, req, reply, cc, opts...)
+		}))
+	s.Require().NoError(err)
+
+	s.grpcClient = reservationsv1.NewReservationServiceClient(grpcConn)
+
+	go func() {
+		err := serviceLayer.RunReservationsDeactivations(ctx, service.DeactivatorConfig{
+			Period:     time.Second / 10,
+			BatchSize:  2,
+			InstanceID: "integration-tests",
+		})
+		s.Require().NoError(err)
+	}()
+
+	go func() {
+		err := serviceLayer.RunStockEvents(ctx)
+		s.Require().NoError(err)
+	}()
+
+	go func() {
+		// retention keeps reservations which tests have just released in reservations table
+		err := serviceLayer.RunReservationsArchivation(ctx, service.ArchiverConfig{
+			Period:       time.Second / 10,
+			RetentionAge: archiverRetentionAge,
+			BatchSize:    2,
+			InstanceID:   "integration-tests",
+		})
+		s.Require().NoError(err)
+	}()
+
+	go func() {
+		err := serviceLayer.RunWebhooks(ctx, service.WebhooksConfig{
+			Period:      time.Second / 10,
+			MaxAttempts: 3,
+			Backoff:     time.Second / 100,
+			MaxBackoff:  time.Second / 10,
+			InstanceID:  "integration-tests",
+		}, notifier.NewDeliverySender(time.Second, true))
+		s.Require().NoError(err)
+	}()
+
+	s.expiryNotifications = make(chan notifier.Message, 100)
+
+	webhookStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message notifier.Message
+
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		select {
+		case s.expiryNotifications <- message:
+		default:
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	go func() {
+		<-ctx.Done()
+		webhookStub.Close()
+	}()
+
 	go func() {
-		err := serviceLayer.RunReservationsDeactivations(ctx, time.Second/10)
+		err := serviceLayer.RunExpiryNotifications(
+			ctx,
+			time.Second/10,
+			time.Hour,
+			notifier.NewWebhook(webhookStub.URL, time.Second))
 		s.Require().NoError(err)
 	}()
 }
 
+func (s *IntegrationTestSuite) createAPIKey(scopes ...model.Scope) string {
+	s.T().Helper()
+
+	key, secret, err := s.service.CreateAPIKey(s.ctx, "integration-tests", scopes)
+	s.Require().NoError(err)
+
+	s.apiKeys = append(s.apiKeys, *key)
+
+	return secret
+}
+
+// createJWKS generates RS256 and ES256 signing keys and writes their public parts to json web key set.
+func (s *IntegrationTestSuite) createJWKS() string {
+	s.T().Helper()
+
+	var err error
+
+	s.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	s.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	encode := func(value *big.Int, size int) string {
+		return base64.RawURLEncoding.EncodeToString(value.FillBytes(make([]byte, size)))
+	}
+
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{
+			"kid": "rsa",
+			"kty": "RSA",
+			"n":   encode(s.rsaKey.N, s.rsaKey.Size()),
+			"e":   encode(big.NewInt(int64(s.rsaKey.E)), 3),
+		},
+		{
+			"kid": "ec",
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   encode(s.ecKey.X, 32),
+			"y":   encode(s.ecKey.Y, 32),
+		},
+	}})
+	s.Require().NoError(err)
+
+	path := filepath.Join(s.T().TempDir(), "jwks.json")
+
+	s.Require().NoError(os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
 func (s *IntegrationTestSuite) createTestData() {
 	s.T().Helper()
 
@@ -121,6 +328,7 @@ func (s *IntegrationTestSuite) createTestData() {
 			ID:       uuid.New(),
 			Name:     "warehouse #" + strconv.Itoa(i),
 			IsActive: true,
+			Region:   "region #" + strconv.Itoa(i%2),
 		})
 
 		s.Require().NoError(err)
@@ -195,7 +403,7 @@ func (s *IntegrationTestSuite) TestReservations() {
 			s.Require().Equal(http.StatusCreated, resp.StatusCode)
 			s.Require().Equal(3, len(s.reservations))
 
-			s.Run("429", func() {
+			s.Run("409", func() {
 				resp = s.sendRequest(
 					context.Background(),
 					http.MethodPost,
@@ -203,7 +411,7 @@ func (s *IntegrationTestSuite) TestReservations() {
 					requestReservations,
 					nil)
 
-				s.Require().Equal(http.StatusTooManyRequests, resp.StatusCode)
+				s.Require().Equal(http.StatusConflict, resp.StatusCode)
 			})
 		})
 
@@ -218,14 +426,22 @@ func (s *IntegrationTestSuite) TestReservations() {
 				},
 			}
 
+			var response apiserver.HTTPResponse
+
 			resp := s.sendRequest(
 				context.Background(),
 				http.MethodPost,
 				createReservationsEndpoint,
 				requestReservations,
-				nil)
+				&response)
 
 			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			s.Require().Equal(apiserver.CodeNotEnoughQuantity, response.Error.Code)
+			s.Require().Equal(0, *response.Error.Details.ItemIndex)
+			s.Require().Equal(s.products[0].SKU, response.Error.Details.ProductID)
+			s.Require().Equal(s.warehouses[0].ID, *response.Error.Details.WarehouseID)
+			s.Require().Equal(uint(90), *response.Error.Details.RequestedQuantity)
+			s.Require().Equal(uint(50), *response.Error.Details.AvailableQuantity)
 		})
 
 		s.Run("404", func() {
@@ -292,6 +508,38 @@ func (s *IntegrationTestSuite) TestReservations() {
 				s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
 			})
 
+			s.Run("itemIndex", func() {
+				requestReservations := []model.Reservation{
+					{
+						ID:          uuid.New(),
+						WarehouseID: s.warehouses[0].ID,
+						ProductID:   s.products[0].SKU,
+						Quantity:    1,
+						DueDate:     time.Now().Add(time.Hour * 24 * 30),
+					},
+					{
+						ID:          uuid.New(),
+						WarehouseID: s.warehouses[0].ID,
+						ProductID:   s.products[0].SKU,
+						Quantity:    0,
+						DueDate:     time.Now().Add(time.Hour * 24 * 30),
+					},
+				}
+
+				var response apiserver.HTTPResponse
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodPost,
+					createReservationsEndpoint,
+					requestReservations,
+					&response)
+
+				s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+				s.Require().Equal(apiserver.CodeInvalidQuantity, response.Error.Code)
+				s.Require().Equal(1, *response.Error.Details.ItemIndex)
+			})
+
 			s.Run("invalidQuantity", func() {
 				requestReservations := []model.Reservation{
 					{
@@ -333,6 +581,36 @@ func (s *IntegrationTestSuite) TestReservations() {
 
 				s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
 			})
+
+			s.Run("unknownField", func() {
+				var response apiserver.HTTPResponse
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodPost,
+					createReservationsEndpoint,
+					json.RawMessage(`[{"id": "`+uuid.NewString()+`", "warehouseId": "`+s.warehouses[0].ID.String()+
+						`", "productId": "`+s.products[0].SKU+`", "quantity": 1, "dueDate": "2030-01-01T00:00:00Z", "qty": 1}]`),
+					&response)
+
+				s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+				s.Require().Equal(apiserver.CodeInvalidBody, response.Error.Code)
+				s.Require().Equal("/0/qty", response.Error.Details.Field)
+			})
+
+			s.Run("nullBody", func() {
+				var response apiserver.HTTPResponse
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodPost,
+					createReservationsEndpoint,
+					json.RawMessage(`null`),
+					&response)
+
+				s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+				s.Require().Equal(apiserver.CodeInvalidBody, response.Error.Code)
+			})
 		})
 
 		s.Run("200/outdated transaction", func() {
@@ -521,6 +799,201 @@ func (s *IntegrationTestSuite) TestReservations() {
 			s.Require().Equal(uint(50), stocks[2].ReservedQuantity)
 		})
 
+		s.Run("200/cursor", func() {
+			var stocks []model.Stock
+
+			params := model.GetParams{
+				Limit:           2,
+				WarehouseFilter: s.warehouses[0].ID.String(),
+				Sorting:         "reserved_quantity",
+				Descending:      true,
+			}
+
+			response := apiserver.HTTPResponse{Data: &stocks}
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				&response)
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(2, len(stocks))
+			s.Require().Equal(uint(50), stocks[0].ReservedQuantity)
+			s.Require().NotNil(response.Meta)
+			s.Require().NotEmpty(response.Meta.NextCursor)
+
+			firstPage := stocks
+			params.Cursor = response.Meta.NextCursor
+			stocks = nil
+			response = apiserver.HTTPResponse{Data: &stocks}
+
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				&response)
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(1, len(stocks))
+			s.Require().Empty(response.Meta.NextCursor)
+			s.Require().NotContains(firstPage, stocks[0])
+
+			s.Run("400/sorting-changed", func() {
+				params.Descending = false
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodPost,
+					getStocksEndpoint,
+					params,
+					nil)
+
+				s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+			})
+		})
+
+		s.Run("200/meta", func() {
+			var stocks []model.Stock
+
+			params := model.GetParams{
+				Limit:           2,
+				WarehouseFilter: s.warehouses[0].ID.String(),
+				Total:           model.TotalExact,
+			}
+
+			response := apiserver.HTTPResponse{Data: &stocks}
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				&response)
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(uint(2), response.Meta.Limit)
+			s.Require().True(response.Meta.HasMore)
+			s.Require().NotNil(response.Meta.Total)
+			s.Require().Equal(int64(3), *response.Meta.Total)
+			s.Require().False(response.Meta.TotalIsApproximate)
+			s.Require().Nil(response.Meta.Links)
+
+			response = apiserver.HTTPResponse{Data: &stocks}
+
+			resp = s.sendRequestTo(
+				context.Background(),
+				http.MethodGet,
+				bindAddrV2+"/stocks?limit=2&offset=1&total=approximate&warehouseId="+s.warehouses[0].ID.String(),
+				nil,
+				&response)
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(uint(1), response.Meta.Offset)
+			s.Require().False(response.Meta.HasMore)
+			s.Require().True(response.Meta.TotalIsApproximate)
+			s.Require().NotNil(response.Meta.Links)
+			s.Require().Empty(response.Meta.Links.Next)
+			s.Require().Contains(response.Meta.Links.Prev, "/api/v2/stocks?")
+			s.Require().NotContains(response.Meta.Links.Prev, "offset")
+		})
+
+		s.Run("200/extended-filters", func() {
+			var stocks []model.Stock
+
+			params := model.GetParams{
+				Limit:        10,
+				WarehouseIDs: []uuid.UUID{s.warehouses[0].ID, s.warehouses[2].ID},
+				ProductIDs:   []string{s.products[0].SKU},
+			}
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				&apiserver.HTTPResponse{Data: &stocks})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(2, len(stocks))
+
+			minReservedQuantity := uint(1)
+
+			params = model.GetParams{
+				Limit:               10,
+				WarehouseIDs:        []uuid.UUID{s.warehouses[0].ID},
+				MinReservedQuantity: &minReservedQuantity,
+				InStockOnly:         true,
+			}
+
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				&apiserver.HTTPResponse{Data: &stocks})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(1, len(stocks))
+			s.Require().Equal(uint(50), stocks[0].AvailableQuantity)
+		})
+
+		s.Run("400/invalid-range", func() {
+			minQuantity, maxQuantity := uint(10), uint(1)
+
+			params := model.GetParams{
+				MinQuantity: &minQuantity,
+				MaxQuantity: &maxQuantity,
+			}
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+
+		s.Run("400/unknown-sort-field", func() {
+			var response apiserver.HTTPResponse
+
+			params := model.GetParams{
+				Sorting: "quantity;DROP",
+			}
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				&response)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+			s.Require().Equal(apiserver.CodeInvalidSortField, response.Error.Code)
+			s.Require().Contains(response.Error.Message, strings.Join(model.StocksSortFields, ", "))
+			s.Require().Equal(model.StocksSortFields, response.Error.Details.AllowedValues)
+		})
+
+		s.Run("400/cursor-with-offset", func() {
+			params := model.GetParams{
+				Offset: 1,
+				Cursor: "eyJ3IjoiYTQ1MjJhNTAtMTU1YS00MDQ0LWE0MzUtNjNmNjk3MmY2MzRmIiwicCI6InByb2R1Y3QwIn0",
+			}
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getStocksEndpoint,
+				params,
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+
 		s.Run("400", func() {
 			params := model.GetParams{
 				WarehouseFilter: "this is a sql injection",
@@ -553,16 +1026,2489 @@ func (s *IntegrationTestSuite) TestReservations() {
 	})
 }
 
-func (s *IntegrationTestSuite) sendRequest(ctx context.Context, method, endpoint string, body interface{}, dest interface{}) *http.Response {
+func (s *IntegrationTestSuite) TestOverbooking() {
+	reservations := make([]model.Reservation, 0)
+
+	// allowance is reset and reservations are released before removal,
+	// so they don't change quantities expected by other tests
+	defer func() {
+		percent := uint(0)
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			setOverbookingEndpoint,
+			model.OverbookingAllowance{WarehouseID: s.warehouses[1].ID, Percent: &percent},
+			nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		if len(reservations) == 0 {
+			return
+		}
+
+		resp = s.sendRequest(context.Background(), http.MethodPost, deleteReservationsEndpoint, reservations, nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		for _, value := range reservations {
+			err := s.str.DeleteRow(context.Background(), value)
+			s.Require().NoError(err)
+		}
+	}()
+
+	s.Run("POST:/setOverbookingAllowance", func() {
+		s.Run("204", func() {
+			percent := uint(10)
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				setOverbookingEndpoint,
+				model.OverbookingAllowance{WarehouseID: s.warehouses[1].ID, Percent: &percent},
+				nil)
+
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+		})
+
+		s.Run("400", func() {
+			percent := uint(10)
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				setOverbookingEndpoint,
+				model.OverbookingAllowance{
+					WarehouseID: s.warehouses[1].ID,
+					ProductID:   s.products[0].SKU,
+					Percent:     &percent,
+				},
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+
+		s.Run("404", func() {
+			percent := uint(10)
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				setOverbookingEndpoint,
+				model.OverbookingAllowance{WarehouseID: uuid.New(), Percent: &percent},
+				nil)
+
+			s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+		})
+	})
+
+	s.Run("POST:/createReservations", func() {
+		s.Run("201/overbooked", func() {
+			var created []model.Reservation
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				createReservationsEndpoint,
+				[]model.Reservation{
+					{
+						ID:          uuid.New(),
+						WarehouseID: s.warehouses[1].ID,
+						ProductID:   s.products[0].SKU,
+						Quantity:    105,
+						DueDate:     time.Now().Add(time.Hour * 24 * 30),
+					},
+					{
+						ID:          uuid.New(),
+						WarehouseID: s.warehouses[1].ID,
+						ProductID:   s.products[1].SKU,
+						Quantity:    102,
+						DueDate:     time.Now().Add(time.Hour * 24 * 30),
+					},
+				},
+				&apiserver.HTTPResponse{Data: &created})
+
+			s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+			reservations = append(reservations, created...)
+		})
+
+		s.Run("422/over-allowance", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				createReservationsEndpoint,
+				[]model.Reservation{
+					{
+						ID:          uuid.New(),
+						WarehouseID: s.warehouses[1].ID,
+						ProductID:   s.products[0].SKU,
+						Quantity:    6,
+						DueDate:     time.Now().Add(time.Hour * 24 * 30),
+					},
+				},
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+	})
+
+	s.Run("POST:/getOverbookedStocks", func() {
+		s.Run("200", func() {
+			var stocks []model.OverbookedStock
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getOverbookedEndpoint,
+				model.GetParams{WarehouseFilter: s.warehouses[1].ID.String()},
+				&apiserver.HTTPResponse{Data: &stocks})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(2, len(stocks))
+			s.Require().Equal(s.products[0].SKU, stocks[0].ProductID)
+			s.Require().Equal(uint(10), stocks[0].OverbookingPercent)
+			s.Require().Equal(uint(5), stocks[0].OverbookedQuantity)
+			s.Require().Equal(uint(2), stocks[1].OverbookedQuantity)
+		})
+
+		s.Run("200/sorted", func() {
+			var stocks []model.OverbookedStock
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getOverbookedEndpoint,
+				model.GetParams{WarehouseFilter: s.warehouses[1].ID.String(), Sorting: "product_id", Descending: true},
+				&apiserver.HTTPResponse{Data: &stocks})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(2, len(stocks))
+			s.Require().Equal(s.products[1].SKU, stocks[0].ProductID)
+			s.Require().Equal(s.products[0].SKU, stocks[1].ProductID)
+
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getOverbookedEndpoint,
+				model.GetParams{WarehouseFilter: s.warehouses[1].ID.String(), Sorting: "reserved_quantity"},
+				&apiserver.HTTPResponse{Data: &stocks})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(2, len(stocks))
+			s.Require().Equal(uint(102), stocks[0].ReservedQuantity)
+			s.Require().Equal(uint(105), stocks[1].ReservedQuantity)
+		})
+	})
+}
+
+func (s *IntegrationTestSuite) TestExportStocks() {
+	export := func(query string) (*http.Response, []byte) {
+		req, err := http.NewRequestWithContext(
+			context.Background(), http.MethodGet, bindAddrV2+"/stocks/export?"+query, nil)
+		s.Require().NoError(err)
+
+		req.Header.Set("X-API-Key", s.apiKey)
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+
+		defer func() {
+			s.Require().NoError(resp.Body.Close())
+		}()
+
+		body, err := io.ReadAll(resp.Body)
+		s.Require().NoError(err)
+
+		return resp, body
+	}
+
+	s.Run("csv", func() {
+		resp, body := export("format=csv&sort=product_id&warehouseId=" + s.warehouses[1].ID.String())
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal("text/csv", resp.Header.Get("Content-Type"))
+
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		s.Require().NoError(err)
+		s.Require().Len(records, len(s.products)+1)
+		s.Require().Equal("warehouseId", records[0][0])
+
+		for i, record := range records[1:] {
+			s.Require().Equal(s.warehouses[1].ID.String(), record[0])
+			s.Require().Equal(s.products[i].SKU, record[1])
+		}
+	})
+
+	s.Run("ndjson", func() {
+		resp, body := export("productId=" + s.products[0].SKU)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal("application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		count := 0
+
+		for decoder.More() {
+			var stock model.Stock
+
+			s.Require().NoError(decoder.Decode(&stock))
+			s.Require().Equal(s.products[0].SKU, stock.ProductID)
+
+			count++
+		}
+
+		s.Require().GreaterOrEqual(count, len(s.warehouses))
+	})
+
+	s.Run("400", func() {
+		resp, _ := export("format=xml")
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func (s *IntegrationTestSuite) TestConditionalGet() {
+	get := func(url, header, value string) *http.Response {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		s.Require().NoError(err)
+
+		req.Header.Set("X-API-Key", s.apiKey)
+
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		s.Require().NoError(resp.Body.Close())
+
+		return resp
+	}
+
+	stockURL := bindAddrV2 + "/stocks/" + s.warehouses[2].ID.String() + "/" + s.products[2].SKU
+	availabilityURL := bindAddrV2 + "/availability?productId=" + s.products[2].SKU
+
+	stockResp := get(stockURL, "", "")
+	s.Require().Equal(http.StatusOK, stockResp.StatusCode)
+	s.Require().NotEmpty(stockResp.Header.Get("ETag"))
+	s.Require().NotEmpty(stockResp.Header.Get("Last-Modified"))
+
+	availabilityResp := get(availabilityURL, "", "")
+	s.Require().Equal(http.StatusOK, availabilityResp.StatusCode)
+	s.Require().NotEmpty(availabilityResp.Header.Get("ETag"))
+
+	s.Run("304", func() {
+		resp := get(stockURL, "If-None-Match", stockResp.Header.Get("ETag"))
+		s.Require().Equal(http.StatusNotModified, resp.StatusCode)
+
+		resp = get(stockURL, "If-Modified-Since", stockResp.Header.Get("Last-Modified"))
+		s.Require().Equal(http.StatusNotModified, resp.StatusCode)
+
+		resp = get(availabilityURL, "If-None-Match", availabilityResp.Header.Get("ETag"))
+		s.Require().Equal(http.StatusNotModified, resp.StatusCode)
+	})
+
+	s.Run("200/modified", func() {
+		reservation := model.Reservation{
+			ID:          uuid.New(),
+			WarehouseID: s.warehouses[2].ID,
+			ProductID:   s.products[2].SKU,
+			Quantity:    1,
+			DueDate:     time.Now().Add(time.Hour),
+		}
+
+		resp := s.sendRequest(
+			context.Background(), http.MethodPost, createReservationsEndpoint, []model.Reservation{reservation}, nil)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		defer func() {
+			resp := s.sendRequest(
+				context.Background(), http.MethodPost, deleteReservationsEndpoint, []model.Reservation{reservation}, nil)
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+			s.Require().NoError(s.str.DeleteRow(context.Background(), reservation))
+		}()
+
+		resp = get(stockURL, "If-None-Match", stockResp.Header.Get("ETag"))
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().NotEqual(stockResp.Header.Get("ETag"), resp.Header.Get("ETag"))
+
+		resp = get(availabilityURL, "If-None-Match", availabilityResp.Header.Get("ETag"))
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	})
+}
+
+func (s *IntegrationTestSuite) TestStockChanges() {
+	url := bindAddrV2 + "/stocks/" + s.warehouses[1].ID.String() + "/" + s.products[1].SKU + "/changes"
+
+	change := func(body map[string]any, ifMatch string) (*http.Response, model.Stock, *apiserver.APIError) {
+		reqBody, err := json.Marshal(body)
+		s.Require().NoError(err)
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(reqBody))
+		s.Require().NoError(err)
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", s.apiKey)
+
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+
+		var stock model.Stock
+
+		response := apiserver.HTTPResponse{Data: &stock}
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&response))
+		s.Require().NoError(resp.Body.Close())
+
+		return resp, stock, response.Error
+	}
+
+	resp, stock, _ := change(map[string]any{"kind": "receive", "quantity": 20}, "")
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal(uint(120), stock.Quantity)
+
+	version := stock.Version
+
+	defer func() {
+		resp, stock, _ := change(map[string]any{"kind": "count", "quantity": 100}, "")
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(uint(100), stock.Quantity)
+	}()
+
+	s.Run("200/if-match", func() {
+		resp, stock, _ := change(map[string]any{"kind": "adjust", "quantity": -5}, `"`+strconv.Itoa(int(version))+`"`)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(uint(115), stock.Quantity)
+		s.Require().Equal(version+1, stock.Version)
+
+		version = stock.Version
+	})
+
+	s.Run("200/if-match-etag", func() {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, strings.TrimSuffix(url, "/changes"), nil)
+		s.Require().NoError(err)
+
+		req.Header.Set("X-API-Key", s.apiKey)
+
+		getResp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		s.Require().NoError(getResp.Body.Close())
+		s.Require().Equal(http.StatusOK, getResp.StatusCode)
+		s.Require().Equal(`"`+strconv.Itoa(int(version))+`"`, getResp.Header.Get("ETag"))
+
+		resp, stock, _ := change(map[string]any{"kind": "adjust", "quantity": 5}, getResp.Header.Get("ETag"))
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(uint(120), stock.Quantity)
+
+		resp, _, apiError := change(map[string]any{"kind": "adjust", "quantity": -5}, getResp.Header.Get("ETag"))
+		s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeVersionConflict, apiError.Code)
+
+		resp, stock, _ = change(map[string]any{"kind": "adjust", "quantity": -5}, `"`+strconv.Itoa(int(stock.Version))+`"`)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(uint(115), stock.Quantity)
+
+		version = stock.Version
+	})
+
+	s.Run("409/reserved", func() {
+		reservation := model.Reservation{
+			ID:          uuid.New(),
+			WarehouseID: s.warehouses[1].ID,
+			ProductID:   s.products[1].SKU,
+			Quantity:    1,
+			DueDate:     time.Now().Add(time.Hour),
+		}
+
+		resp := s.sendRequest(
+			context.Background(), http.MethodPost, createReservationsEndpoint, []model.Reservation{reservation}, nil)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		defer func() {
+			resp := s.sendRequest(
+				context.Background(), http.MethodPost, deleteReservationsEndpoint, []model.Reservation{reservation}, nil)
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+			s.Require().NoError(s.str.DeleteRow(context.Background(), reservation))
+		}()
+
+		// reservation changes stock, so etag taken before it is stale
+		resp, stock, apiError := change(map[string]any{"kind": "count", "quantity": 50}, `"`+strconv.Itoa(int(version))+`"`)
+		s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeVersionConflict, apiError.Code)
+		s.Require().Equal(version+1, stock.Version)
+	})
+
+	// reservation and its removal change version twice
+	version += 2
+
+	s.Run("409/stale-version", func() {
+		resp, stock, apiError := change(map[string]any{"kind": "count", "quantity": 50}, `"`+strconv.Itoa(int(version-1))+`"`)
+		s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeVersionConflict, apiError.Code)
+		s.Require().Equal(version, stock.Version)
+		s.Require().Equal(uint(115), stock.Quantity)
+
+		resp, _, apiError = change(map[string]any{"kind": "count", "quantity": 50, "expectedVersion": version - 1}, "")
+		s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeVersionConflict, apiError.Code)
+	})
+
+	s.Run("409/below-reserved", func() {
+		reservation := model.Reservation{
+			ID:          uuid.New(),
+			WarehouseID: s.warehouses[1].ID,
+			ProductID:   s.products[1].SKU,
+			Quantity:    10,
+			DueDate:     time.Now().Add(time.Hour),
+		}
+
+		resp := s.sendRequest(
+			context.Background(), http.MethodPost, createReservationsEndpoint, []model.Reservation{reservation}, nil)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		defer func() {
+			resp := s.sendRequest(
+				context.Background(), http.MethodPost, deleteReservationsEndpoint, []model.Reservation{reservation}, nil)
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+			s.Require().NoError(s.str.DeleteRow(context.Background(), reservation))
+		}()
+
+		resp, stock, apiError := change(map[string]any{"kind": "count", "quantity": 0}, "")
+		s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeBelowReserved, apiError.Code)
+		s.Require().Equal(uint(115), stock.Quantity)
+		s.Require().NotNil(apiError.Details.MinQuantity)
+		s.Require().NotZero(*apiError.Details.MinQuantity)
+		s.Require().LessOrEqual(*apiError.Details.MinQuantity, stock.ReservedQuantity)
+
+		resp, _, apiError = change(map[string]any{"kind": "adjust", "quantity": -int64(stock.Quantity)}, "")
+		s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeBelowReserved, apiError.Code)
+
+		// quantity equal to reserved one is allowed
+		resp, stock, _ = change(map[string]any{"kind": "count", "quantity": stock.ReservedQuantity}, "")
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(stock.ReservedQuantity, stock.Quantity)
+
+		resp, stock, _ = change(map[string]any{"kind": "count", "quantity": 115}, "")
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(uint(115), stock.Quantity)
+	})
+
+	s.Run("409/negative", func() {
+		resp, _, apiError := change(map[string]any{"kind": "adjust", "quantity": -1000}, "")
+		s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeNegativeStock, apiError.Code)
+	})
+
+	s.Run("400", func() {
+		resp, _, _ := change(map[string]any{"kind": "receive", "quantity": 0}, "")
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+
+		resp, _, _ = change(map[string]any{"kind": "receive", "quantity": 1}, "W/abc")
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+
+		resp, _, apiError := change(map[string]any{"kind": "receive", "quantity": model.MaxStockQuantity + 1}, "")
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeInvalidQuantity, apiError.Code)
+
+		// stock quantity would overflow
+		resp, _, apiError = change(map[string]any{"kind": "receive", "quantity": model.MaxStockQuantity}, "")
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeInvalidQuantity, apiError.Code)
+	})
+}
+
+func (s *IntegrationTestSuite) TestStockEvents() {
+	url := bindAddrV2 + "/stocks/events?warehouseId=" + s.warehouses[2].ID.String() + "&productId=" + s.products[2].SKU
+
+	// readStream reads events of stream until stop returns true. It doesn't fail the test,
+	// so it can be called from another goroutine.
+	readStream := func(lastEventID int64, stop func(event model.StockEvent) bool) ([]model.StockEvent, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("X-API-Key", s.apiKey)
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			return nil, fmt.Errorf("unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		var (
+			events []model.StockEvent
+			id     string
+		)
+
+		scanner := bufio.NewScanner(resp.Body)
+
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				var event model.StockEvent
+
+				if err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+					return nil, err
+				}
+
+				if id != strconv.FormatInt(event.ID, 10) {
+					return nil, fmt.Errorf("id %s of event %d", id, event.ID)
+				}
+
+				events = append(events, event)
+
+				if stop(event) {
+					return events, nil
+				}
+			}
+		}
+
+		return nil, fmt.Errorf("stream ended before expected event: %w", scanner.Err())
+	}
+
+	stream := func(lastEventID int64, stop func(event model.StockEvent) bool) []model.StockEvent {
+		events, err := readStream(lastEventID, stop)
+		s.Require().NoError(err)
+
+		return events
+	}
+
+	reservation := model.Reservation{
+		ID:          uuid.New(),
+		WarehouseID: s.warehouses[2].ID,
+		ProductID:   s.products[2].SKU,
+		Quantity:    3,
+		DueDate:     time.Now().Add(time.Hour),
+	}
+
+	resp := s.sendRequest(
+		context.Background(), http.MethodPost, createReservationsEndpoint, []model.Reservation{reservation}, nil)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	resp = s.sendRequest(
+		context.Background(), http.MethodPost, deleteReservationsEndpoint, []model.Reservation{reservation}, nil)
+	s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+	s.Require().NoError(s.str.DeleteRow(context.Background(), reservation))
+
+	isReleased := func(event model.StockEvent) bool {
+		return event.Kind == model.StockEventReleased && *event.ReservationID == reservation.ID
+	}
+
+	var reserved model.StockEvent
+
+	s.Run("replay", func() {
+		events := stream(0, isReleased)
+
+		for _, event := range events {
+			s.Require().Equal(s.warehouses[2].ID, event.WarehouseID)
+			s.Require().Equal(s.products[2].SKU, event.ProductID)
+
+			if event.Kind == model.StockEventReserved && *event.ReservationID == reservation.ID {
+				reserved = event
+			}
+		}
+
+		s.Require().NotZero(reserved.ID)
+		s.Require().Equal(int64(3), reserved.Quantity)
+		s.Require().Equal(uint(3), reserved.ReservedQuantity)
+	})
+
+	s.Run("resume", func() {
+		events := stream(reserved.ID, isReleased)
+
+		s.Require().Greater(events[0].ID, reserved.ID)
+		s.Require().Equal(uint(0), events[len(events)-1].ReservedQuantity)
+	})
+
+	s.Run("live", func() {
+		released := stream(reserved.ID, isReleased)
+
+		type streamResult struct {
+			events []model.StockEvent
+			err    error
+		}
+
+		changed := make(chan streamResult)
+
+		go func() {
+			events, err := readStream(released[len(released)-1].ID, func(event model.StockEvent) bool {
+				return event.Kind == model.StockEventQuantityChanged
+			})
+
+			changed <- streamResult{events: events, err: err}
+		}()
+
+		stockURL := bindAddrV2 + "/stocks/" + s.warehouses[2].ID.String() + "/" + s.products[2].SKU + "/changes"
+
+		resp := s.sendRequestTo(
+			context.Background(), http.MethodPost, stockURL, map[string]any{"kind": "adjust", "quantity": 1}, nil)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		result := <-changed
+		s.Require().NoError(result.err)
+
+		events := result.events
+		s.Require().Equal(int64(1), events[len(events)-1].Quantity)
+		s.Require().Equal(uint(101), events[len(events)-1].StockQuantity)
+
+		resp = s.sendRequestTo(
+			context.Background(), http.MethodPost, stockURL, map[string]any{"kind": "adjust", "quantity": -1}, nil)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	s.Run("expired", func() {
+		// reservations with the same due date are likely released by one batch of deactivator
+		dueDate := time.Now().Add(time.Second)
+
+		expiring := []model.Reservation{
+			{ID: uuid.New(), WarehouseID: s.warehouses[2].ID, ProductID: s.products[2].SKU, Quantity: 2, DueDate: dueDate},
+			{ID: uuid.New(), WarehouseID: s.warehouses[2].ID, ProductID: s.products[2].SKU, Quantity: 5, DueDate: dueDate},
+		}
+
+		resp := s.sendRequest(context.Background(), http.MethodPost, createReservationsEndpoint, expiring, nil)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		defer func() {
+			for _, reservation := range expiring {
+				s.Require().NoError(s.str.DeleteRow(context.Background(), reservation))
+			}
+		}()
+
+		expired := make([]model.StockEvent, 0, len(expiring))
+
+		stream(reserved.ID, func(event model.StockEvent) bool {
+			if event.Kind == model.StockEventExpired &&
+				(*event.ReservationID == expiring[0].ID || *event.ReservationID == expiring[1].ID) {
+				expired = append(expired, event)
+			}
+
+			return len(expired) == len(expiring)
+		})
+
+		// every event describes stock right after its reservation is released
+		first, second := expired[0], expired[1]
+		s.Require().Less(first.ID, second.ID)
+		s.Require().Equal(second.ReservedQuantity+uint(second.Quantity), first.ReservedQuantity)
+		s.Require().Equal(first.Version+1, second.Version)
+		s.Require().Equal(first.StockQuantity-first.ReservedQuantity, first.AvailableQuantity)
+	})
+
+	s.Run("shutdown", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := apiserver.New(apiserver.Config{BindAddress: ":8083"}, s.service)
+
+		stopped := make(chan error, 1)
+
+		go func() {
+			stopped <- server.Run(ctx)
+		}()
+
+		streamCtx, streamCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer streamCancel()
+
+		var resp *http.Response
+
+		s.eventually(func() bool {
+			req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, "http://localhost:8083/api/v2/stocks/events", nil)
+			s.Require().NoError(err)
+
+			resp, err = http.DefaultClient.Do(req)
+
+			return err == nil
+		}, time.Second, time.Second/100, "server isn't started")
+
+		defer resp.Body.Close()
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		cancel()
+
+		// server ends stream on shutdown, otherwise reading fails when client gives up
+		_, err := io.Copy(io.Discard, resp.Body)
+		s.Require().NoError(err)
+		s.Require().NoError(<-stopped)
+	})
+}
+
+func (s *IntegrationTestSuite) TestWebhooks() {
+	var (
+		secret   string
+		requests atomic.Int32
+	)
+
+	messages := make(chan model.WebhookMessage, 10)
+
+	// receiver checks signature and fails the first request, so delivery is retried
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		signature := notifier.Sign(secret, r.Header.Get(notifier.HeaderWebhookTimestamp), body)
+		if r.Header.Get(notifier.HeaderWebhookSignature) != signature {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		var message model.WebhookMessage
+
+		if err = json.Unmarshal(body, &message); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		messages <- message
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	create := func(url string, lowStockThreshold uint, eventTypes ...model.WebhookEventType) model.WebhookSubscription {
+		var subscription model.WebhookSubscription
+
+		resp := s.sendRequestTo(
+			context.Background(),
+			http.MethodPost,
+			bindAddrV2+"/webhooks",
+			map[string]any{"url": url, "eventTypes": eventTypes, "lowStockThreshold": lowStockThreshold},
+			&apiserver.HTTPResponse{Data: &subscription})
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+		s.Require().NotEmpty(subscription.Secret)
+
+		return subscription
+	}
+
+	deliveries := func(subscriptionID uuid.UUID, status model.WebhookDeliveryStatus) []model.WebhookDelivery {
+		var result []model.WebhookDelivery
+
+		resp := s.sendRequestTo(
+			context.Background(),
+			http.MethodGet,
+			bindAddrV2+"/webhooks/"+subscriptionID.String()+"/deliveries?status="+string(status),
+			nil,
+			&apiserver.HTTPResponse{Data: &result})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		return result
+	}
+
+	subscription := create(receiver.URL, 0, model.WebhookStockReceived)
+	secret = subscription.Secret
+
+	deadSubscription := create(failing.URL, 0, model.WebhookStockReceived)
+
+	defer func() {
+		for _, value := range []model.WebhookSubscription{subscription, deadSubscription} {
+			err := s.str.DeleteRow(context.Background(), value)
+			s.Require().NoError(err)
+		}
+	}()
+
+	stockURL := bindAddrV2 + "/stocks/" + s.warehouses[1].ID.String() + "/" + s.products[1].SKU + "/changes"
+
+	resp := s.sendRequestTo(
+		context.Background(), http.MethodPost, stockURL, map[string]any{"kind": "receive", "quantity": 5}, nil)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	defer func() {
+		resp := s.sendRequestTo(
+			context.Background(), http.MethodPost, stockURL, map[string]any{"kind": "count", "quantity": 100}, nil)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	}()
+
+	s.Run("delivered", func() {
+		select {
+		case message := <-messages:
+			s.Require().Equal(model.WebhookStockReceived, message.Type)
+			s.Require().Equal(model.StockReceive, message.Data.ChangeKind)
+			s.Require().Equal(int64(5), message.Data.Quantity)
+			s.Require().Equal(s.products[1].SKU, message.Data.ProductID)
+		case <-time.After(5 * time.Second):
+			s.Fail("webhook was not delivered")
+
+			return
+		}
+
+		var delivered []model.WebhookDelivery
+
+		s.Require().Eventually(func() bool {
+			delivered = deliveries(subscription.ID, model.WebhookDeliveryDelivered)
+
+			return len(delivered) == 1
+		}, 5*time.Second, time.Second/20)
+
+		s.Require().Equal(uint(2), delivered[0].Attempts)
+		s.Require().Equal(http.StatusOK, *delivered[0].LastStatusCode)
+		s.Require().NotNil(delivered[0].DeliveredAt)
+	})
+
+	s.Run("dead", func() {
+		var dead []model.WebhookDelivery
+
+		s.Require().Eventually(func() bool {
+			dead = deliveries(deadSubscription.ID, model.WebhookDeliveryDead)
+
+			return len(dead) == 1
+		}, 5*time.Second, time.Second/20)
+
+		s.Require().Equal(uint(3), dead[0].Attempts)
+		s.Require().Equal(http.StatusInternalServerError, *dead[0].LastStatusCode)
+		s.Require().NotEmpty(dead[0].LastError)
+
+		var retried model.WebhookDelivery
+
+		resp := s.sendRequestTo(
+			context.Background(),
+			http.MethodPost,
+			bindAddrV2+"/webhooks/deliveries/"+dead[0].ID.String()+"/retry",
+			nil,
+			&apiserver.HTTPResponse{Data: &retried})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(model.WebhookDeliveryPending, retried.Status)
+		s.Require().Equal(uint(0), retried.Attempts)
+
+		resp = s.sendRequestTo(
+			context.Background(), http.MethodPost, bindAddrV2+"/webhooks/deliveries/"+uuid.NewString()+"/retry", nil, nil)
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	s.Run("stock.low and reservation.expired", func() {
+		received := make(chan model.WebhookMessage, 10)
+
+		lowReceiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var message model.WebhookMessage
+
+			if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+
+				return
+			}
+
+			received <- message
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer lowReceiver.Close()
+
+		var stock model.Stock
+
+		resp := s.sendRequestTo(
+			context.Background(),
+			http.MethodGet,
+			bindAddrV2+"/stocks/"+s.warehouses[2].ID.String()+"/"+s.products[0].SKU,
+			nil,
+			&apiserver.HTTPResponse{Data: &stock})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().NotZero(stock.AvailableQuantity)
+
+		// reservation of a single item makes available quantity fall to threshold
+		lowSubscription := create(
+			lowReceiver.URL, stock.AvailableQuantity-1, model.WebhookStockLow, model.WebhookReservationExpired)
+
+		defer func() {
+			s.Require().NoError(s.str.DeleteRow(context.Background(), lowSubscription))
+		}()
+
+		reservation := model.Reservation{
+			ID:          uuid.New(),
+			WarehouseID: s.warehouses[2].ID,
+			ProductID:   s.products[0].SKU,
+			Quantity:    1,
+			DueDate:     time.Now().Add(time.Second),
+		}
+
+		resp = s.sendRequest(
+			context.Background(), http.MethodPost, createReservationsEndpoint, []model.Reservation{reservation}, nil)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		defer func() {
+			s.Require().NoError(s.str.DeleteRow(context.Background(), reservation))
+		}()
+
+		messages := make(map[model.WebhookEventType]model.WebhookMessage)
+
+		for len(messages) < 2 {
+			select {
+			case message := <-received:
+				s.Require().NotContains(messages, message.Type)
+
+				messages[message.Type] = message
+			case <-time.After(5 * time.Second):
+				s.FailNow("webhooks were not delivered", "received %d", len(messages))
+			}
+		}
+
+		low := messages[model.WebhookStockLow].Data
+		s.Require().Equal(model.StockEventReserved, low.Kind)
+		s.Require().Equal(reservation.ID, *low.ReservationID)
+		s.Require().Equal(stock.AvailableQuantity-1, low.AvailableQuantity)
+
+		expired := messages[model.WebhookReservationExpired].Data
+		s.Require().Equal(model.StockEventExpired, expired.Kind)
+		s.Require().Equal(reservation.ID, *expired.ReservationID)
+		s.Require().Equal(stock.AvailableQuantity, expired.AvailableQuantity)
+
+		// available quantity rising back doesn't send stock.low again
+		select {
+		case message := <-received:
+			s.Failf("unexpected webhook", "type %s", message.Type)
+		case <-time.After(time.Second / 2):
+		}
+	})
+
+	s.Run("list and delete", func() {
+		var subscriptions []model.WebhookSubscription
+
+		resp := s.sendRequestTo(
+			context.Background(), http.MethodGet, bindAddrV2+"/webhooks", nil, &apiserver.HTTPResponse{Data: &subscriptions})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		var found bool
+
+		for _, value := range subscriptions {
+			if value.ID == deadSubscription.ID {
+				found = true
+
+				s.Require().Empty(value.Secret)
+			}
+		}
+
+		s.Require().True(found)
+
+		resp = s.sendRequestTo(
+			context.Background(), http.MethodDelete, bindAddrV2+"/webhooks/"+deadSubscription.ID.String(), nil, nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		resp = s.sendRequestTo(
+			context.Background(), http.MethodDelete, bindAddrV2+"/webhooks/"+deadSubscription.ID.String(), nil, nil)
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	s.Run("400", func() {
+		resp := s.sendRequestTo(
+			context.Background(),
+			http.MethodPost,
+			bindAddrV2+"/webhooks",
+			map[string]any{"url": "ftp://example.com", "eventTypes": []string{"stock.low"}},
+			nil)
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func (s *IntegrationTestSuite) TestExpiryNotifications() {
+	create := func(dueDate time.Time) model.Reservation {
+		var created []model.Reservation
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			createReservationsEndpoint,
+			[]model.Reservation{
+				{
+					ID:          uuid.New(),
+					WarehouseID: s.warehouses[2].ID,
+					ProductID:   s.products[2].SKU,
+					Quantity:    1,
+					DueDate:     dueDate,
+				},
+			},
+			&apiserver.HTTPResponse{Data: &created})
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		return created[0]
+	}
+
+	// release deletes reservation through api, so reserved quantity of stock is restored, and removes its row
+	release := func(reservation model.Reservation) {
+		resp := s.sendRequest(
+			context.Background(), http.MethodPost, deleteReservationsEndpoint, []model.Reservation{reservation}, nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		s.Require().NoError(s.str.DeleteRow(context.Background(), reservation))
+	}
+
+	claimedIDs := func(reservations *[]model.Reservation) []uuid.UUID {
+		ids := make([]uuid.UUID, 0, len(*reservations))
+
+		for _, value := range *reservations {
+			ids = append(ids, value.ID)
+		}
+
+		return ids
+	}
+
+	s.Run("webhook", func() {
+		reservation := create(time.Now().Add(time.Minute * 30))
+		defer release(reservation)
+
+		// notified tells if message mentions the reservation
+		notified := func(message notifier.Message) bool {
+			s.Require().Equal(notifier.EventReservationsExpiring, message.Event)
+
+			for _, value := range message.Reservations {
+				if value.ID == reservation.ID {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		timeout := time.After(time.Second * 2)
+
+		for delivered := false; !delivered; {
+			select {
+			case message := <-s.expiryNotifications:
+				delivered = notified(message)
+			case <-timeout:
+				s.FailNow("expiry notification was not delivered")
+			}
+		}
+
+		// notifier runs every 100ms, reservation is claimed once and isn't sent again
+		timeout = time.After(time.Second / 2)
+
+		for {
+			select {
+			case message := <-s.expiryNotifications:
+				s.Require().False(notified(message), "expiry notification is delivered twice")
+			case <-timeout:
+				return
+			}
+		}
+	})
+
+	s.Run("outbox", func() {
+		// reservation is beyond lead time of notifier of the suite, so only claim of the test takes it
+		reservation := create(time.Now().Add(time.Hour * 2))
+		defer release(reservation)
+
+		claimed, err := s.str.ClaimExpiringReservations(context.Background(), time.Now().Add(time.Hour*3), 100, true)
+		s.Require().NoError(err)
+		s.Require().Contains(claimedIDs(claimed), reservation.ID)
+
+		filter := model.StockEventsFilter{
+			WarehouseIDs: []uuid.UUID{s.warehouses[2].ID},
+			ProductIDs:   []string{s.products[2].SKU},
+		}
+
+		var expiring *model.StockEvent
+
+		s.eventually(func() bool {
+			events, err := s.str.GetStockEvents(context.Background(), 0, filter, 100_000)
+			s.Require().NoError(err)
+
+			for i, event := range *events {
+				if event.Kind == model.StockEventExpiring && *event.ReservationID == reservation.ID {
+					expiring = &(*events)[i]
+				}
+			}
+
+			return expiring != nil
+		}, 2*time.Second, time.Second/20, "expiring event was not relayed")
+
+		s.Require().Equal(int64(reservation.Quantity), expiring.Quantity)
+		s.Require().NotZero(expiring.ReservedQuantity)
+
+		claimed, err = s.str.ClaimExpiringReservations(context.Background(), time.Now().Add(time.Hour*3), 100, true)
+		s.Require().NoError(err)
+		s.Require().NotContains(claimedIDs(claimed), reservation.ID)
+	})
+}
+
+func (s *IntegrationTestSuite) TestListenDueDates() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dueDates := make(chan time.Time, 100)
+	stopped := make(chan error, 1)
+
+	go func() {
+		stopped <- s.str.ListenDueDates(ctx, func(dueDate time.Time) {
+			select {
+			case dueDates <- dueDate:
+			default:
+			}
+		})
+	}()
+
+	created := make([]model.Reservation, 0)
+
+	defer func() {
+		resp := s.sendRequest(context.Background(), http.MethodPost, deleteReservationsEndpoint, created, nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		for _, value := range created {
+			s.Require().NoError(s.str.DeleteRow(context.Background(), value))
+		}
+	}()
+
+	// listening starts in background, so batches are created until notification of one of them arrives.
+	// Condition of Eventually would run in another goroutine, where requests can't fail the test
+	earliest := make([]time.Time, 0)
+
+	notified := func() bool {
+		var batch []model.Reservation
+
+		now := time.Now()
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			createReservationsEndpoint,
+			[]model.Reservation{
+				{
+					ID:          uuid.New(),
+					WarehouseID: s.warehouses[2].ID,
+					ProductID:   s.products[0].SKU,
+					Quantity:    1,
+					DueDate:     now.Add(2 * time.Hour),
+				},
+				{
+					ID:          uuid.New(),
+					WarehouseID: s.warehouses[2].ID,
+					ProductID:   s.products[0].SKU,
+					Quantity:    1,
+					DueDate:     now.Add(time.Hour),
+				},
+			},
+			&apiserver.HTTPResponse{Data: &batch})
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		created = append(created, batch...)
+		earliest = append(earliest, now.Add(time.Hour))
+
+		select {
+		case dueDate := <-dueDates:
+			// only the earliest due date of batch is sent
+			for _, value := range earliest {
+				if value.Sub(dueDate).Abs() < time.Millisecond {
+					return true
+				}
+			}
+
+			return false
+		case <-time.After(time.Second / 5):
+			return false
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !notified() {
+		s.Require().True(time.Now().Before(deadline), "due date notification was not delivered")
+	}
+
+	cancel()
+	s.Require().NoError(<-stopped)
+}
+
+func (s *IntegrationTestSuite) TestHealth() {
+	s.Run("GET:/healthz", func() {
+		var health model.Readiness
+
+		resp := s.sendRequestTo(context.Background(), http.MethodGet, healthAddr+"/healthz", nil, &health)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(model.HealthOK, health.Status)
+	})
+
+	s.Run("GET:/readyz", func() {
+		var readiness model.Readiness
+
+		resp := s.sendRequestTo(context.Background(), http.MethodGet, healthAddr+"/readyz", nil, &readiness)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(model.HealthOK, readiness.Status)
+		s.Require().Len(readiness.Checks, 3)
+
+		for _, check := range readiness.Checks {
+			s.Require().Equal(model.HealthOK, check.Status, check.Name)
+		}
+	})
+
+	s.Run("503/shutdown", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := apiserver.New(apiserver.Config{BindAddress: ":8082", ShutdownDelay: time.Second}, s.service)
+
+		stopped := make(chan error, 1)
+
+		go func() {
+			stopped <- server.Run(ctx)
+		}()
+
+		readyz := func() int {
+			resp, err := http.Get("http://localhost:8082/readyz") //nolint:noctx
+			if err != nil {
+				return 0
+			}
+
+			s.Require().NoError(resp.Body.Close())
+
+			return resp.StatusCode
+		}
+
+		s.eventually(func() bool { return readyz() == http.StatusOK }, time.Second, time.Second/100, "server isn't ready")
+
+		// request in flight sends the rest of its body only after server stops accepting connections
+		body, bodyWriter := io.Pipe()
+
+		req, err := http.NewRequestWithContext(
+			context.Background(), http.MethodPost, "http://localhost:8082/api/v1"+getStocksEndpoint, body)
+		s.Require().NoError(err)
+
+		req.Header.Set("Content-Type", "application/json")
+
+		inFlight := make(chan *http.Response, 1)
+
+		go func() {
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				inFlight <- nil
+
+				return
+			}
+
+			inFlight <- resp
+		}()
+
+		_, err = bodyWriter.Write([]byte("{"))
+		s.Require().NoError(err)
+
+		cancel()
+
+		// server keeps answering during shutdown delay, but isn't ready anymore
+		s.eventually(func() bool { return readyz() == http.StatusServiceUnavailable },
+			time.Second, time.Second/100, "readiness doesn't fail on shutdown")
+		s.eventually(func() bool { return readyz() == 0 }, 2*time.Second, time.Second/100, "server isn't closed")
+
+		select {
+		case err = <-stopped:
+			s.FailNow("server stopped before request in flight completed", "error: %v", err)
+		default:
+		}
+
+		_, err = bodyWriter.Write([]byte("}"))
+		s.Require().NoError(err)
+		s.Require().NoError(bodyWriter.Close())
+
+		resp := <-inFlight
+		s.Require().NotNil(resp)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().NoError(resp.Body.Close())
+
+		s.Require().NoError(<-stopped)
+	})
+}
+
+func (s *IntegrationTestSuite) TestWorkerLeases() {
+	s.Run("POST:/getWorkerLeases", func() {
+		s.Run("200", func() {
+			var leases []model.Lease
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getWorkerLeasesEndpoint,
+				nil,
+				&apiserver.HTTPResponse{Data: &leases})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			var deactivatorLease *model.Lease
+
+			for i, value := range leases {
+				if value.Name == service.DeactivatorLeaseName {
+					deactivatorLease = &leases[i]
+				}
+			}
+
+			s.Require().NotNil(deactivatorLease)
+			s.Require().NotEmpty(deactivatorLease.Holder)
+			s.Require().False(deactivatorLease.IsExpired)
+		})
+	})
+
+	s.Run("failover", func() {
+		const name = "integration-tests-failover"
+
+		ctx := context.Background()
+		ttl := time.Second / 2
+
+		defer func() {
+			s.Require().NoError(s.str.ReleaseLease(ctx, name, "first"))
+			s.Require().NoError(s.str.ReleaseLease(ctx, name, "second"))
+		}()
+
+		first, err := s.str.AcquireLease(ctx, name, "first", ttl)
+		s.Require().NoError(err)
+		s.Require().NotNil(first)
+
+		second, err := s.str.AcquireLease(ctx, name, "second", ttl)
+		s.Require().NoError(err)
+		s.Require().Nil(second)
+
+		// renewal keeps the token
+		renewed, err := s.str.AcquireLease(ctx, name, "first", ttl)
+		s.Require().NoError(err)
+		s.Require().Equal(first.Token, renewed.Token)
+
+		// first holder stalls in the middle of a sweep and its lease expires
+		s.Require().Eventually(func() bool {
+			second, err = s.str.AcquireLease(ctx, name, "second", ttl)
+
+			return err == nil && second != nil
+		}, 2*time.Second, ttl/5)
+		s.Require().Greater(second.Token, first.Token)
+
+		// writes of stale leader are rejected, writes of the new one are not
+		nothingIsDue := time.Now().Add(-time.Hour * 24 * 365)
+
+		_, err = s.str.DeactivateDueReservations(ctx, first, &nothingIsDue, 2)
+		s.Require().ErrorIs(err, model.ErrLeaseLost)
+
+		_, err = s.str.ArchiveInactiveReservations(ctx, first, nothingIsDue, 2)
+		s.Require().ErrorIs(err, model.ErrLeaseLost)
+
+		_, err = s.str.DeactivateDueReservations(ctx, second, &nothingIsDue, 2)
+		s.Require().NoError(err)
+
+		_, err = s.str.ArchiveInactiveReservations(ctx, second, nothingIsDue, 2)
+		s.Require().NoError(err)
+
+		// stale leader can't take lease back while it is held
+		first, err = s.str.AcquireLease(ctx, name, "first", ttl)
+		s.Require().NoError(err)
+		s.Require().Nil(first)
+	})
+}
+
+func (s *IntegrationTestSuite) TestDeactivationBatches() {
+	s.Run("zero batch size", func() {
+		err := s.service.RunReservationsDeactivations(context.Background(), service.DeactivatorConfig{
+			Period:     time.Second,
+			InstanceID: "integration-tests",
+		})
+		s.Require().ErrorIs(err, model.ErrInvalidBatchSize)
+	})
+
+	s.Run("several batches", func() {
+		requestReservations := make([]model.Reservation, 0, 5)
+
+		for i := 0; i < 5; i++ {
+			requestReservations = append(requestReservations, model.Reservation{
+				ID:          uuid.New(),
+				WarehouseID: s.warehouses[0].ID,
+				ProductID:   s.products[1].SKU,
+				Quantity:    1,
+				DueDate:     time.Now().Add(time.Hour),
+			})
+		}
+
+		before, err := s.str.GetStock(context.Background(), s.warehouses[0].ID, s.products[1].SKU)
+		s.Require().NoError(err)
+
+		var created []model.Reservation
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			createReservationsEndpoint,
+			requestReservations,
+			&apiserver.HTTPResponse{Data: &created})
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		defer func() {
+			for _, value := range created {
+				s.Require().NoError(s.str.DeleteRow(context.Background(), value))
+			}
+		}()
+
+		// reservations are due only as of an hour later, so background deactivator doesn't take them
+		asOf := time.Now().Add(2 * time.Hour)
+
+		stats, err := s.str.DeactivateDueReservations(context.Background(), nil, &asOf, 2)
+		s.Require().NoError(err)
+		s.Require().Equal(uint(3), stats.Batches)
+		s.Require().Equal(int64(5), stats.Reservations)
+		// every batch releases the same stock, it is counted once per run
+		s.Require().Equal(int64(1), stats.Stocks)
+
+		after, err := s.str.GetStock(context.Background(), s.warehouses[0].ID, s.products[1].SKU)
+		s.Require().NoError(err)
+		s.Require().Equal(before.ReservedQuantity, after.ReservedQuantity)
+
+		var reservations []model.Reservation
+
+		ids := make([]uuid.UUID, 0, len(created))
+
+		for _, value := range created {
+			ids = append(ids, value.ID)
+		}
+
+		resp = s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			getReservationsEndpoint,
+			model.GetReservationsParams{IDs: ids, Limit: uint(len(ids))},
+			&apiserver.HTTPResponse{Data: &reservations})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Len(reservations, len(created))
+
+		for _, value := range reservations {
+			s.Require().False(value.IsActive)
+		}
+	})
+}
+
+func (s *IntegrationTestSuite) TestForceDeactivation() {
+	var created []model.Reservation
+
+	resp := s.sendRequest(
+		context.Background(),
+		http.MethodPost,
+		createReservationsEndpoint,
+		[]model.Reservation{
+			{
+				ID:          uuid.New(),
+				WarehouseID: s.warehouses[2].ID,
+				ProductID:   s.products[1].SKU,
+				Quantity:    3,
+				DueDate:     time.Now().Add(time.Hour * 24),
+			},
+		},
+		&apiserver.HTTPResponse{Data: &created})
+
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	defer func() {
+		// the first reservation isn't due yet, it is released before removal
+		resp := s.sendRequest(context.Background(), http.MethodPost, deleteReservationsEndpoint, created[:1], nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		for _, value := range created {
+			err := s.str.DeleteRow(context.Background(), value)
+			s.Require().NoError(err)
+		}
+	}()
+
+	s.Run("POST:/admin/deactivateDueReservations", func() {
+		s.Run("200/dry-run", func() {
+			var result model.DeactivationResult
+
+			asOf := time.Now().Add(time.Hour * 48)
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				deactivateEndpoint,
+				model.DeactivationRequest{AsOf: &asOf, DryRun: true, Limit: model.MaxPreviewLimit},
+				&apiserver.HTTPResponse{Data: &result})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().True(result.DryRun)
+
+			found := false
+
+			for _, value := range result.Reservations {
+				if value.ID == created[0].ID {
+					found = true
+				}
+			}
+
+			s.Require().True(found)
+
+			for _, value := range result.StockDeltas {
+				if value.WarehouseID == s.warehouses[2].ID && value.ProductID == s.products[1].SKU {
+					s.Require().LessOrEqual(value.ReservedQuantityDelta, int64(-3))
+				}
+			}
+		})
+
+		s.Run("200", func() {
+			before, err := s.str.GetStock(context.Background(), s.warehouses[2].ID, s.products[1].SKU)
+			s.Require().NoError(err)
+
+			// api doesn't accept due dates in the past
+			due, err := s.str.CreateReservations(context.Background(), []model.Reservation{{
+				ID:          uuid.New(),
+				WarehouseID: s.warehouses[2].ID,
+				ProductID:   s.products[1].SKU,
+				Quantity:    2,
+				DueDate:     time.Now().Add(-time.Minute),
+			}})
+			s.Require().NoError(err)
+
+			created = append(created, *due...)
+
+			var result model.DeactivationResult
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				deactivateEndpoint,
+				model.DeactivationRequest{BatchSize: 1},
+				&apiserver.HTTPResponse{Data: &result})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().False(result.DryRun)
+			s.Require().NotNil(result.Stats)
+			s.Require().Equal(result.Stats.Reservations, int64(result.Stats.Batches))
+
+			// background deactivator may have locked the reservation first, then it is done by it
+			// right after, so state is polled for a short while
+			var reservations []model.Reservation
+
+			for deadline := time.Now().Add(time.Second); ; {
+				resp = s.sendRequest(
+					context.Background(),
+					http.MethodPost,
+					getReservationsEndpoint,
+					model.GetReservationsParams{IDs: []uuid.UUID{(*due)[0].ID}, Limit: 1},
+					&apiserver.HTTPResponse{Data: &reservations})
+				s.Require().Equal(http.StatusOK, resp.StatusCode)
+				s.Require().Len(reservations, 1)
+
+				if !reservations[0].IsActive || time.Now().After(deadline) {
+					break
+				}
+
+				time.Sleep(time.Second / 20)
+			}
+
+			s.Require().False(reservations[0].IsActive)
+			s.Require().NotNil(reservations[0].DeactivatedAt)
+
+			after, err := s.str.GetStock(context.Background(), s.warehouses[2].ID, s.products[1].SKU)
+			s.Require().NoError(err)
+			s.Require().Equal(before.ReservedQuantity, after.ReservedQuantity)
+
+			// reservation due tomorrow isn't touched
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getReservationsEndpoint,
+				model.GetReservationsParams{IDs: []uuid.UUID{created[0].ID}, Limit: 1},
+				&apiserver.HTTPResponse{Data: &reservations})
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().True(reservations[0].IsActive)
+		})
+
+		s.Run("400", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				deactivateEndpoint,
+				model.DeactivationRequest{DryRun: true, Limit: model.MaxPreviewLimit + 1},
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+
+		s.Run("400/future", func() {
+			var response apiserver.HTTPResponse
+
+			asOf := time.Now().Add(time.Hour * 48)
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				deactivateEndpoint,
+				model.DeactivationRequest{AsOf: &asOf},
+				&response)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+			s.Require().Equal(apiserver.CodeInvalidAsOf, response.Error.Code)
+		})
+	})
+}
+
+func (s *IntegrationTestSuite) TestArchivedReservations() {
+	requestReservations := []model.Reservation{
+		{
+			ID:          uuid.New(),
+			WarehouseID: s.warehouses[2].ID,
+			ProductID:   s.products[0].SKU,
+			Quantity:    1,
+			DueDate:     time.Now().Add(time.Hour),
+		},
+	}
+
+	resp := s.sendRequest(
+		context.Background(),
+		http.MethodPost,
+		createReservationsEndpoint,
+		requestReservations,
+		nil)
+
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	defer func() {
+		err := s.str.DeleteRow(context.Background(), requestReservations[0])
+		s.Require().NoError(err)
+	}()
+
+	resp = s.sendRequest(
+		context.Background(),
+		http.MethodPost,
+		deleteReservationsEndpoint,
+		requestReservations,
+		nil)
+
+	s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+	s.eventually(func() bool {
+		var reservations []model.Reservation
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			getReservationsEndpoint,
+			model.GetReservationsParams{IDs: []uuid.UUID{requestReservations[0].ID}, IncludeArchived: true},
+			&apiserver.HTTPResponse{Data: &reservations})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Len(reservations, 1)
+
+		return reservations[0].ArchivedAt != nil
+	}, archiverRetentionAge+5*time.Second, time.Second/10, "reservation was not archived")
+
+	s.Run("POST:/getReservations", func() {
+		s.Run("200/without-archived", func() {
+			var reservations []model.Reservation
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getReservationsEndpoint,
+				model.GetReservationsParams{IDs: []uuid.UUID{requestReservations[0].ID}},
+				&apiserver.HTTPResponse{Data: &reservations})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(0, len(reservations))
+		})
+
+		s.Run("200/include-archived", func() {
+			var reservations []model.Reservation
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				getReservationsEndpoint,
+				model.GetReservationsParams{
+					IDs:             []uuid.UUID{requestReservations[0].ID},
+					IncludeArchived: true,
+				},
+				&apiserver.HTTPResponse{Data: &reservations})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(1, len(reservations))
+			s.Require().False(reservations[0].IsActive)
+			s.Require().NotNil(reservations[0].ArchivedAt)
+		})
+	})
+
+	s.Run("POST:/createReservations", func() {
+		s.Run("409/archived-duplicate", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				createReservationsEndpoint,
+				requestReservations,
+				nil)
+
+			s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		})
+	})
+}
+
+func (s *IntegrationTestSuite) TestReservationsV2() {
+	reservation := model.Reservation{
+		ID:          uuid.New(),
+		WarehouseID: s.warehouses[2].ID,
+		ProductID:   s.products[2].SKU,
+		Quantity:    2,
+		DueDate:     time.Now().Add(time.Hour * 24),
+	}
+
+	defer func() {
+		err := s.str.DeleteRow(context.Background(), reservation)
+		s.Require().NoError(err)
+	}()
+
+	s.Run("POST:/reservations", func() {
+		s.Run("201", func() {
+			var created model.Reservation
+
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodPost,
+				bindAddrV2+"/reservations",
+				reservation,
+				&apiserver.HTTPResponse{Data: &created})
+
+			s.Require().Equal(http.StatusCreated, resp.StatusCode)
+			s.Require().Equal("/api/v2/reservations/"+reservation.ID.String(), resp.Header.Get("Location"))
+			s.Require().Equal(reservation.ID, created.ID)
+			s.Require().True(created.IsActive)
+		})
+
+		s.Run("409", func() {
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodPost,
+				bindAddrV2+"/reservations",
+				reservation,
+				nil)
+
+			s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		})
+	})
+
+	s.Run("PATCH:/reservations/{id}", func() {
+		s.Run("200", func() {
+			var updated model.Reservation
+
+			dueDate := time.Now().Add(time.Hour * 48).UTC().Truncate(time.Second)
+
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodPatch,
+				bindAddrV2+"/reservations/"+reservation.ID.String(),
+				model.ReservationUpdate{DueDate: dueDate},
+				&apiserver.HTTPResponse{Data: &updated})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().True(dueDate.Equal(updated.DueDate))
+		})
+	})
+
+	s.Run("GET:/reservations", func() {
+		s.Run("200", func() {
+			var reservations []model.Reservation
+
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodGet,
+				bindAddrV2+"/reservations?id="+reservation.ID.String(),
+				nil,
+				&apiserver.HTTPResponse{Data: &reservations})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(1, len(reservations))
+		})
+
+		s.Run("400", func() {
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodGet,
+				bindAddrV2+"/reservations?limit=ten",
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+
+	s.Run("DELETE:/reservations/{id}", func() {
+		s.Run("204", func() {
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodDelete,
+				bindAddrV2+"/reservations/"+reservation.ID.String(),
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+		})
+
+		s.Run("404", func() {
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodDelete,
+				bindAddrV2+"/reservations/"+reservation.ID.String(),
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+		})
+	})
+
+	s.Run("GET:/stocks/{warehouseId}/{productId}", func() {
+		s.Run("200", func() {
+			var stock model.Stock
+
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodGet,
+				bindAddrV2+"/stocks/"+s.warehouses[2].ID.String()+"/"+s.products[2].SKU,
+				nil,
+				&apiserver.HTTPResponse{Data: &stock})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(uint(100), stock.Quantity)
+		})
+
+		s.Run("404", func() {
+			resp := s.sendRequestTo(
+				context.Background(),
+				http.MethodGet,
+				bindAddrV2+"/stocks/"+uuid.NewString()+"/"+s.products[2].SKU,
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+		})
+	})
+}
+
+func (s *IntegrationTestSuite) TestAvailability() {
+	s.Run("200/by-warehouse", func() {
+		var availability []model.ProductAvailability
+
+		params := model.AvailabilityParams{
+			ProductIDs: []string{s.products[0].SKU, "unknown"},
+		}
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			getAvailabilityEndpoint,
+			params,
+			&apiserver.HTTPResponse{Data: &availability})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(2, len(availability))
+		s.Require().Equal(s.products[0].SKU, availability[0].ProductID)
+		s.Require().Equal(uint(300), availability[0].Quantity)
+		s.Require().Equal(3, len(availability[0].Breakdown))
+		s.Require().NotNil(availability[0].Breakdown[0].WarehouseID)
+		s.Require().Equal("unknown", availability[1].ProductID)
+		s.Require().Equal(uint(0), availability[1].Quantity)
+	})
+
+	s.Run("200/by-region", func() {
+		var availability []model.ProductAvailability
+
+		resp := s.sendRequestTo(
+			context.Background(),
+			http.MethodGet,
+			bindAddrV2+"/availability?groupBy=region&activeWarehousesOnly=true&productId="+s.products[0].SKU,
+			nil,
+			&apiserver.HTTPResponse{Data: &availability})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(1, len(availability))
+		s.Require().Equal(2, len(availability[0].Breakdown))
+		s.Require().Nil(availability[0].Breakdown[0].WarehouseID)
+		s.Require().Equal("region #0", availability[0].Breakdown[0].Region)
+		s.Require().Equal(uint(200), availability[0].Breakdown[0].Quantity)
+	})
+
+	s.Run("set region", func() {
+		regionEndpoint := bindAddrV2 + "/warehouses/" + s.warehouses[1].ID.String() + "/region"
+
+		resp := s.sendRequestTo(
+			context.Background(), http.MethodPut, regionEndpoint, model.WarehouseRegion{Region: "region #0"}, nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		defer func() {
+			resp := s.sendRequestTo(
+				context.Background(), http.MethodPut, regionEndpoint, model.WarehouseRegion{Region: "region #1"}, nil)
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+		}()
+
+		var availability []model.ProductAvailability
+
+		resp = s.sendRequestTo(
+			context.Background(),
+			http.MethodGet,
+			bindAddrV2+"/availability?groupBy=region&productId="+s.products[0].SKU,
+			nil,
+			&apiserver.HTTPResponse{Data: &availability})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(1, len(availability))
+		s.Require().Equal(1, len(availability[0].Breakdown))
+		s.Require().Equal("region #0", availability[0].Breakdown[0].Region)
+		s.Require().Equal(uint(300), availability[0].Breakdown[0].Quantity)
+
+		resp = s.sendRequestTo(
+			context.Background(),
+			http.MethodPut,
+			regionEndpoint,
+			model.WarehouseRegion{Region: strings.Repeat("r", model.RegionMaxLength+1)},
+			nil)
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+
+		resp = s.sendRequestTo(
+			context.Background(),
+			http.MethodPut,
+			bindAddrV2+"/warehouses/"+uuid.NewString()+"/region",
+			model.WarehouseRegion{Region: "region #0"},
+			nil)
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	s.Run("400", func() {
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			getAvailabilityEndpoint,
+			model.AvailabilityParams{},
+			nil)
+
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func (s *IntegrationTestSuite) TestGRPC() {
+	s.Run("GetStocks", func() {
+		s.Run("OK", func() {
+			resp, err := s.grpcClient.GetStocks(context.Background(), &reservationsv1.GetStocksRequest{
+				Limit:           10,
+				WarehouseFilter: s.warehouses[0].ID.String(),
+			})
+
+			s.Require().NoError(err)
+			s.Require().Equal(3, len(resp.GetStocks()))
+		})
+	})
+
+	s.Run("CreateReservations", func() {
+		s.Run("InvalidArgument", func() {
+			_, err := s.grpcClient.CreateReservations(context.Background(), &reservationsv1.CreateReservationsRequest{
+				Reservations: []*reservationsv1.Reservation{{
+					Id:          uuid.NewString(),
+					WarehouseId: s.warehouses[1].ID.String(),
+					ProductId:   s.products[1].SKU,
+					Quantity:    0,
+					DueDate:     timestamppb.New(time.Now().Add(time.Hour)),
+				}},
+			})
+
+			st := status.Convert(err)
+			s.Require().Equal(codes.InvalidArgument, st.Code())
+
+			var badRequest *errdetails.BadRequest
+
+			for _, detail := range st.Details() {
+				if value, ok := detail.(*errdetails.BadRequest); ok {
+					badRequest = value
+				}
+			}
+
+			s.Require().NotNil(badRequest)
+			s.Require().Equal("quantity", badRequest.GetFieldViolations()[0].GetField())
+		})
+
+		s.Run("FailedPrecondition", func() {
+			_, err := s.grpcClient.CreateReservations(context.Background(), &reservationsv1.CreateReservationsRequest{
+				Reservations: []*reservationsv1.Reservation{{
+					Id:          uuid.NewString(),
+					WarehouseId: s.warehouses[1].ID.String(),
+					ProductId:   s.products[1].SKU,
+					Quantity:    1000,
+					DueDate:     timestamppb.New(time.Now().Add(time.Hour)),
+				}},
+			})
+
+			st := status.Convert(err)
+			s.Require().Equal(codes.FailedPrecondition, st.Code())
+
+			var errorInfo *errdetails.ErrorInfo
+
+			for _, detail := range st.Details() {
+				if value, ok := detail.(*errdetails.ErrorInfo); ok {
+					errorInfo = value
+				}
+			}
+
+			s.Require().NotNil(errorInfo)
+			s.Require().Equal(reservationsv1.ErrorReason_ERROR_REASON_NOT_ENOUGH_QUANTITY.String(), errorInfo.GetReason())
+		})
+	})
+
+	s.Run("DeleteReservations", func() {
+		s.Run("NotFound", func() {
+			_, err := s.grpcClient.DeleteReservations(context.Background(), &reservationsv1.DeleteReservationsRequest{
+				Ids: []string{uuid.NewString()},
+			})
+
+			s.Require().Equal(codes.NotFound, status.Code(err))
+		})
+	})
+}
+
+func (s *IntegrationTestSuite) TestAuth() {
+	send := func(key, method, url string, body any) *http.Response {
+		reqBody, err := json.Marshal(body)
+		s.Require().NoError(err)
+
+		req, err := http.NewRequestWithContext(context.Background(), method, url, bytes.NewReader(reqBody))
+		s.Require().NoError(err)
+
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		s.Require().NoError(resp.Body.Close())
+
+		return resp
+	}
+
+	readOnlyKey := s.createAPIKey(model.ScopeStocksRead)
+
+	s.Run("401", func() {
+		resp := send("", http.MethodPost, bindAddr+getStocksEndpoint, model.GetParams{})
+		s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
+
+		resp = send("lhr_unknown", http.MethodGet, bindAddrV2+"/stocks", nil)
+		s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	s.Run("200", func() {
+		resp := send(readOnlyKey, http.MethodPost, bindAddr+getStocksEndpoint, model.GetParams{})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	s.Run("403", func() {
+		resp := send(readOnlyKey, http.MethodPost, bindAddr+deleteReservationsEndpoint, []model.Reservation{{ID: uuid.New()}})
+		s.Require().Equal(http.StatusForbidden, resp.StatusCode)
+
+		resp = send(readOnlyKey, http.MethodGet, bindAddrV2+"/workers/leases", nil)
+		s.Require().Equal(http.StatusForbidden, resp.StatusCode)
+	})
+
+	s.Run("revoked", func() {
+		s.Require().NoError(s.service.RevokeAPIKey(context.Background(), s.apiKeys[len(s.apiKeys)-1].ID))
+
+		resp := send(readOnlyKey, http.MethodPost, bindAddr+getStocksEndpoint, model.GetParams{})
+		s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	s.Run("grpc", func() {
+		_, err := s.grpcClient.GetStocks(
+			metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "lhr_unknown"),
+			&reservationsv1.GetStocksRequest{})
+
+		s.Require().Equal(codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func (s *IntegrationTestSuite) TestBearerToken() {
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jwtSecret))
+		s.Require().NoError(err)
+
+		return token
+	}
+
+	signWith := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+
+		signed, err := token.SignedString(key)
+		s.Require().NoError(err)
+
+		return signed
+	}
+
+	sendTo := func(token, method, url string, body any, dest any) *http.Response {
+		reqBody, err := json.Marshal(body)
+		s.Require().NoError(err)
+
+		req, err := http.NewRequestWithContext(context.Background(), method, url, bytes.NewReader(reqBody))
+		s.Require().NoError(err)
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+
+		defer func() {
+			s.Require().NoError(resp.Body.Close())
+		}()
+
+		if dest != nil {
+			s.Require().NoError(json.NewDecoder(resp.Body).Decode(dest))
+		}
+
+		return resp
+	}
+
+	send := func(token string, body any, dest any) *http.Response {
+		return sendTo(token, http.MethodPost, bindAddr+createReservationsEndpoint, body, dest)
+	}
+
+	reservation := func(warehouseID uuid.UUID) []model.Reservation {
+		return []model.Reservation{{
+			ID:          uuid.New(),
+			WarehouseID: warehouseID,
+			ProductID:   s.products[2].SKU,
+			Quantity:    1,
+			DueDate:     time.Now().Add(time.Hour),
+		}}
+	}
+
+	operator := sign(jwt.MapClaims{
+		"sub":        "operator",
+		"exp":        time.Now().Add(time.Hour).Unix(),
+		"roles":      []string{string(model.RoleOperator)},
+		"warehouses": []string{s.warehouses[0].ID.String()},
+	})
+
+	s.Run("201", func() {
+		var created []model.Reservation
+
+		resp := send(operator, reservation(s.warehouses[0].ID), &apiserver.HTTPResponse{Data: &created})
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+		s.Require().Len(created, 1)
+
+		// reservation is released before removal, so it doesn't hold quantity expected by other tests
+		resp = s.sendRequest(context.Background(), http.MethodPost, deleteReservationsEndpoint, created, nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		s.Require().NoError(s.str.DeleteRow(context.Background(), created[0]))
+	})
+
+	s.Run("403", func() {
+		var response apiserver.HTTPResponse
+
+		resp := send(operator, reservation(s.warehouses[1].ID), &response)
+		s.Require().Equal(http.StatusForbidden, resp.StatusCode)
+		s.Require().Equal(apiserver.CodeForbidden, response.Error.Code)
+		s.Require().Equal(s.warehouses[1].ID, *response.Error.Details.WarehouseID)
+	})
+
+	s.Run("viewer 403", func() {
+		viewer := sign(jwt.MapClaims{
+			"sub":   "viewer",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{string(model.RoleViewer)},
+		})
+
+		resp := send(viewer, reservation(s.warehouses[0].ID), nil)
+		s.Require().Equal(http.StatusForbidden, resp.StatusCode)
+	})
+
+	s.Run("401", func() {
+		expired := sign(jwt.MapClaims{
+			"sub":   "operator",
+			"exp":   time.Now().Add(-time.Hour).Unix(),
+			"roles": []string{string(model.RoleOperator)},
+		})
+
+		resp := send(expired, reservation(s.warehouses[0].ID), nil)
+		s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
+
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":   "operator",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{string(model.RoleAdmin)},
+		}).SignedString([]byte("another secret"))
+		s.Require().NoError(err)
+
+		resp = send(forged, reservation(s.warehouses[0].ID), nil)
+		s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	s.Run("jwks", func() {
+		claims := jwt.MapClaims{
+			"sub":   "viewer",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{string(model.RoleViewer)},
+		}
+
+		for _, token := range []string{
+			signWith(jwt.SigningMethodRS256, "rsa", s.rsaKey, claims),
+			signWith(jwt.SigningMethodES256, "ec", s.ecKey, claims),
+		} {
+			resp := sendTo(token, http.MethodGet, bindAddrV2+"/availability?productId="+s.products[0].SKU, nil, nil)
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+		}
+
+		// key of another kid doesn't verify token
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		s.Require().NoError(err)
+
+		for _, token := range []string{
+			signWith(jwt.SigningMethodES256, "ec", otherKey, claims),
+			signWith(jwt.SigningMethodES256, "unknown", s.ecKey, claims),
+			signWith(jwt.SigningMethodRS256, "ec", s.rsaKey, claims),
+		} {
+			resp := sendTo(token, http.MethodGet, bindAddrV2+"/availability?productId="+s.products[0].SKU, nil, nil)
+			s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
+		}
+	})
+
+	s.Run("warehouses claim", func() {
+		created := make([]model.Reservation, 0)
+
+		resp := s.sendRequest(context.Background(), http.MethodPost, createReservationsEndpoint,
+			reservation(s.warehouses[1].ID), &apiserver.HTTPResponse{Data: &created})
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+		s.Require().Len(created, 1)
+
+		defer func() {
+			resp := s.sendRequest(context.Background(), http.MethodPost, deleteReservationsEndpoint, created, nil)
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+			s.Require().NoError(s.str.DeleteRow(context.Background(), created[0]))
+		}()
+
+		// operator may act only on warehouses[0], but reservation and stock belong to warehouses[1]
+		requests := []struct {
+			method string
+			url    string
+			body   any
+		}{
+			{http.MethodPost, bindAddr + deleteReservationsEndpoint, created},
+			{http.MethodDelete, bindAddrV2 + "/reservations/" + created[0].ID.String(), nil},
+			{
+				http.MethodPatch,
+				bindAddrV2 + "/reservations/" + created[0].ID.String(),
+				model.ReservationUpdate{DueDate: time.Now().Add(2 * time.Hour)},
+			},
+			{
+				http.MethodPost,
+				bindAddrV2 + "/stocks/" + s.warehouses[1].ID.String() + "/" + s.products[2].SKU + "/changes",
+				model.StockChange{Kind: model.StockReceive, Quantity: 1},
+			},
+		}
+
+		for _, value := range requests {
+			var response apiserver.HTTPResponse
+
+			resp := sendTo(operator, value.method, value.url, value.body, &response)
+			s.Require().Equal(http.StatusForbidden, resp.StatusCode, value.method+" "+value.url)
+			s.Require().Equal(apiserver.CodeForbidden, response.Error.Code)
+			s.Require().Equal(s.warehouses[1].ID, *response.Error.Details.WarehouseID)
+		}
+
+		// nothing is changed by rejected requests
+		var reservations []model.Reservation
+
+		resp = s.sendRequest(context.Background(), http.MethodPost, getReservationsEndpoint,
+			model.GetReservationsParams{IDs: []uuid.UUID{created[0].ID}, Limit: 1},
+			&apiserver.HTTPResponse{Data: &reservations})
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Len(reservations, 1)
+		s.Require().True(reservations[0].IsActive)
+		s.Require().WithinDuration(created[0].DueDate, reservations[0].DueDate, time.Second)
+	})
+}
+
+func (s *IntegrationTestSuite) TestRateLimit() {
+	limiter, err := ratelimit.New(ratelimit.Config{
+		Backend: ratelimit.BackendMemory,
+		Limit:   model.RateLimit{Rate: 0.1, Burst: 2},
+	}, s.str)
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+
+	server := apiserver.New(apiserver.Config{BindAddress: ":8082", AuthEnabled: true}, s.service)
+	server.SetRateLimiter(limiter)
+
+	grpcServer := grpcserver.New(grpcserver.Config{BindAddress: ":9092", AuthEnabled: true}, s.service)
+	grpcServer.SetRateLimiter(limiter)
+
+	stopped := make(chan error, 2)
+
+	go func() {
+		stopped <- server.Run(ctx)
+	}()
+
+	go func() {
+		stopped <- grpcServer.Run(ctx)
+	}()
+
+	defer func() {
+		cancel()
+
+		for i := 0; i < cap(stopped); i++ {
+			s.Require().NoError(<-stopped)
+		}
+	}()
+
+	send := func(key string) *http.Response {
+		req, err := http.NewRequestWithContext(
+			context.Background(), http.MethodGet, "http://localhost:8082/api/v2/stocks", nil)
+		s.Require().NoError(err)
+
+		req.Header.Set("X-API-Key", key)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil
+		}
+
+		s.Require().NoError(resp.Body.Close())
+
+		return resp
+	}
+
+	// wait for server start, the first request uses a token of bucket
+	s.eventually(func() bool { return send(s.apiKey) != nil }, time.Second, time.Second/100, "server isn't started")
+
+	s.Run("429", func() {
+		resp := send(s.apiKey)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		resp = send(s.apiKey)
+		s.Require().Equal(http.StatusTooManyRequests, resp.StatusCode)
+		s.Require().Equal("10", resp.Header.Get("Retry-After"))
+	})
+
+	s.Run("another client", func() {
+		resp := send(s.createAPIKey(model.ScopeStocksRead))
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	s.Run("grpc", func() {
+		grpcConn, err := grpc.NewClient("localhost:9092", grpc.WithTransportCredentials(insecure.NewCredentials()))
+		s.Require().NoError(err)
+
+		defer func() { s.Require().NoError(grpcConn.Close()) }()
+
+		client := reservationsv1.NewReservationServiceClient(grpcConn)
+		callCtx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", s.apiKey)
+
+		// method has its own bucket, http requests of client don't take its tokens
+		for i := 0; i < 2; i++ {
+			_, err = client.GetStocks(callCtx, &reservationsv1.GetStocksRequest{Limit: 1}, grpc.WaitForReady(true))
+			s.Require().NoError(err)
+		}
+
+		_, err = client.GetStocks(callCtx, &reservationsv1.GetStocksRequest{Limit: 1})
+
+		st, ok := status.FromError(err)
+		s.Require().True(ok)
+		s.Require().Equal(codes.ResourceExhausted, st.Code())
+
+		var retryInfo *errdetails.RetryInfo
+
+		for _, detail := range st.Details() {
+			if value, ok := detail.(*errdetails.RetryInfo); ok {
+				retryInfo = value
+			}
+		}
+
+		s.Require().NotNil(retryInfo)
+		s.Require().Greater(retryInfo.GetRetryDelay().AsDuration(), 9*time.Second)
+	})
+
+	s.Run("postgres", func() {
+		buckets := ratelimit.NewPostgres(s.str)
+		key := "integration-tests " + uuid.NewString()
+		limit := model.RateLimit{Rate: 0.1, Burst: 2}
+
+		for i := 0; i < 2; i++ {
+			retryAfter, err := buckets.Take(context.Background(), key, limit)
+			s.Require().NoError(err)
+			s.Require().Zero(retryAfter)
+		}
+
+		retryAfter, err := buckets.Take(context.Background(), key, limit)
+		s.Require().NoError(err)
+		s.Require().Greater(retryAfter, 9*time.Second)
+	})
+
+	s.Run("postgres cleanup", func() {
+		buckets := ratelimit.NewPostgres(s.str)
+		fullKey, emptyKey := "integration-tests "+uuid.NewString(), "integration-tests "+uuid.NewString()
+		limit := model.RateLimit{Rate: 0.1, Burst: 1}
+
+		_, err := buckets.Take(context.Background(), fullKey, model.RateLimit{Rate: 100, Burst: 1})
+		s.Require().NoError(err)
+
+		retryAfter, err := buckets.Take(context.Background(), emptyKey, limit)
+		s.Require().NoError(err)
+		s.Require().Zero(retryAfter)
+
+		time.Sleep(100 * time.Millisecond)
+
+		deleted, err := s.str.DeleteFullRateLimitBuckets(context.Background())
+		s.Require().NoError(err)
+		s.Require().GreaterOrEqual(deleted, int64(1))
+
+		// bucket which isn't refilled yet is kept
+		retryAfter, err = buckets.Take(context.Background(), emptyKey, limit)
+		s.Require().NoError(err)
+		s.Require().Greater(retryAfter, 9*time.Second)
+	})
+}
+
+// eventually waits for condition like Require().Eventually, but calls it in the test goroutine,
+// so condition may fail the test itself.
+func (s *IntegrationTestSuite) eventually(condition func() bool, waitFor, tick time.Duration, msg string) {
+	s.T().Helper()
+
+	deadline := time.Now().Add(waitFor)
+
+	for !condition() {
+		s.Require().True(time.Now().Before(deadline), msg)
+
+		time.Sleep(tick)
+	}
+}
+
+func (s *IntegrationTestSuite) sendRequest(ctx context.Context, method, endpoint string, body interface{}, dest interface{}) *http.Response {
+	s.T().Helper()
+
+	return s.sendRequestTo(ctx, method, bindAddr+endpoint, body, dest)
+}
+
+func (s *IntegrationTestSuite) sendRequestTo(ctx context.Context, method, url string, body interface{}, dest interface{}) *http.Response {
 	s.T().Helper()
 
 	reqBody, err := json.Marshal(body)
 	s.Require().NoError(err)
 
-	req, err := http.NewRequestWithContext(ctx, method, bindAddr+endpoint, bytes.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(reqBody))
 	s.Require().NoError(err)
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", s.apiKey)
 
 	resp, err := http.DefaultClient.Do(req)
 	s.Require().NoError(err)